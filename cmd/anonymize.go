@@ -0,0 +1,609 @@
+package cmd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+type anonymizedKind int
+
+const (
+	anonymizedIP anonymizedKind = iota
+	anonymizedMAC
+	anonymizedName
+	anonymizedDNSName
+
+	anonymizedSuffix = "_anonymized"
+	maxAnonymizedIPs = 100000 // cached ip mappings, each one costing up to 128 aes encryptions
+)
+
+var (
+	anonymizeEnabled bool
+	anonymizeKey     string
+
+	// fields rewritten by the anonymizer, others being kept as is
+	anonymizedFields = map[string]anonymizedKind{
+		"SrcAddr":              anonymizedIP,
+		"DstAddr":              anonymizedIP,
+		"SrcK8S_HostIP":        anonymizedIP,
+		"DstK8S_HostIP":        anonymizedIP,
+		"XlatSrcAddr":          anonymizedIP,
+		"XlatDstAddr":          anonymizedIP,
+		"AgentIP":              anonymizedIP,
+		"SrcMac":               anonymizedMAC,
+		"DstMac":               anonymizedMAC,
+		"SrcK8S_Name":          anonymizedName,
+		"DstK8S_Name":          anonymizedName,
+		"SrcK8S_OwnerName":     anonymizedName,
+		"DstK8S_OwnerName":     anonymizedName,
+		"SrcK8S_Namespace":     anonymizedName,
+		"DstK8S_Namespace":     anonymizedName,
+		"SrcK8S_HostName":      anonymizedName,
+		"DstK8S_HostName":      anonymizedName,
+		"SrcK8S_NetworkName":   anonymizedName,
+		"DstK8S_NetworkName":   anonymizedName,
+		"XlatSrcK8S_Name":      anonymizedName,
+		"XlatDstK8S_Name":      anonymizedName,
+		"XlatSrcK8S_Namespace": anonymizedName,
+		"XlatDstK8S_Namespace": anonymizedName,
+		"K8S_ClusterName":      anonymizedName,
+		"SrcSubnetLabel":       anonymizedName,
+		"DstSubnetLabel":       anonymizedName,
+		"Udns":                 anonymizedName,
+		"DnsName":              anonymizedDNSName,
+	}
+
+	anonymizeCmd = &cobra.Command{
+		Use:   "anonymize <file>...",
+		Short: "Anonymize captures",
+		Long: "Rewrite flow json / ndjson files, flow databases and pcapng files with anonymized addresses and names, " +
+			"writing them next to the original files with the " + anonymizedSuffix + " suffix",
+		Args: cobra.MinimumNArgs(1),
+		Run:  runAnonymize,
+	}
+)
+
+// flowAnonymizer maps ips using prefix preserving Crypto-PAn and names using a keyed hash so the same
+// key always gives the same values
+type flowAnonymizer struct {
+	key   []byte
+	block cipher.Block
+	pad   [aes.BlockSize]byte
+	ips   map[string]string
+	mutex sync.Mutex
+}
+
+func newFlowAnonymizer(key string) (*flowAnonymizer, error) {
+	secret := make([]byte, sha256.Size)
+	if key == "" {
+		log.Warn("No anonymization key provided, values will differ from other anonymized captures")
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	} else {
+		sum := sha256.Sum256([]byte(key))
+		secret = sum[:]
+	}
+
+	// like Crypto-PAn, the first half of the secret is the aes key and the second half gives the padding
+	block, err := aes.NewCipher(secret[:aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	a := &flowAnonymizer{key: secret, block: block, ips: map[string]string{}}
+	block.Encrypt(a.pad[:], secret[aes.BlockSize:])
+	return a, nil
+}
+
+// initAnonymizer creates the anonymizer of the session collector from flags, if enabled
+func (s *CaptureSession) initAnonymizer() {
+	if !anonymizeEnabled {
+		return
+	}
+	var err error
+	s.anonymizer, err = newFlowAnonymizer(anonymizeKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("Anonymizing captured records")
+}
+
+func (a *flowAnonymizer) anonymizeIP(value string) string {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return a.anonymizeName(value)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if anonymized, ok := a.ips[value]; ok {
+		return anonymized
+	}
+
+	orig := ip.To4()
+	if orig == nil {
+		orig = ip.To16()
+	}
+	result := make(net.IP, len(orig))
+	var in, out [aes.BlockSize]byte
+	for pos := 0; pos < len(orig)*8; pos++ {
+		// encrypt the first pos bits of the address completed with the padding
+		in = a.pad
+		full := pos / 8
+		copy(in[:full], orig[:full])
+		if rem := pos % 8; rem > 0 {
+			mask := byte(0xff << (8 - rem))
+			in[full] = orig[full]&mask | a.pad[full]&^mask
+		}
+		a.block.Encrypt(out[:], in[:])
+
+		// flip the bit according to the first bit of the result
+		shift := 7 - pos%8
+		bit := (orig[full]>>shift)&1 ^ out[0]>>7
+		result[full] |= bit << shift
+	}
+
+	anonymized := result.String()
+	if len(a.ips) < maxAnonymizedIPs {
+		a.ips[value] = anonymized
+	}
+	return anonymized
+}
+
+func (a *flowAnonymizer) hash(value string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func (a *flowAnonymizer) anonymizeName(value string) string {
+	return "anon-" + hex.EncodeToString(a.hash(value))[:12]
+}
+
+// anonymizeDNSName hashes each label except the top level domain to keep names hierarchy
+func (a *flowAnonymizer) anonymizeDNSName(value string) string {
+	labels := strings.Split(strings.TrimSuffix(value, "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		if labels[i] != "" {
+			labels[i] = hex.EncodeToString(a.hash(labels[i]))[:8]
+		}
+	}
+	anonymized := strings.Join(labels, ".")
+	if strings.HasSuffix(value, ".") {
+		anonymized += "."
+	}
+	return anonymized
+}
+
+// anonymizeMAC hashes the address, keeping broadcast and multicast ones recognizable
+func (a *flowAnonymizer) anonymizeMAC(value string) string {
+	mac, err := net.ParseMAC(value)
+	if err != nil || len(mac) != 6 {
+		return a.anonymizeName(value)
+	}
+	anonymized := a.anonymizeHardwareAddr(mac)
+	return strings.ToUpper(anonymized.String())
+}
+
+func (a *flowAnonymizer) anonymizeHardwareAddr(mac net.HardwareAddr) net.HardwareAddr {
+	if slices.Equal(mac, layers.EthernetBroadcast) || slices.Equal(mac, make(net.HardwareAddr, len(mac))) {
+		return mac
+	}
+	anonymized := net.HardwareAddr(a.hash(mac.String())[:len(mac)])
+	// set locally administered bit and keep multicast one
+	anonymized[0] = anonymized[0]&0xfc | 0x02 | mac[0]&0x01
+	return anonymized
+}
+
+func (a *flowAnonymizer) anonymizeValue(kind anonymizedKind, value string) string {
+	if value == "" {
+		return value
+	}
+	switch kind {
+	case anonymizedIP:
+		return a.anonymizeIP(value)
+	case anonymizedMAC:
+		return a.anonymizeMAC(value)
+	case anonymizedDNSName:
+		return a.anonymizeDNSName(value)
+	default:
+		return a.anonymizeName(value)
+	}
+}
+
+// anonymizeFlow rewrites the anonymized fields of a flow or packet, including network events and packet data
+func (a *flowAnonymizer) anonymizeFlow(flow config.GenericMap) {
+	for field, kind := range anonymizedFields {
+		switch v := flow[field].(type) {
+		case string:
+			flow[field] = a.anonymizeValue(kind, v)
+		case []interface{}:
+			for i := range v {
+				if s, ok := v[i].(string); ok {
+					v[i] = a.anonymizeValue(kind, s)
+				}
+			}
+		case []string:
+			for i := range v {
+				v[i] = a.anonymizeValue(kind, v[i])
+			}
+		}
+	}
+
+	if events, ok := flow["NetworkEvents"].([]interface{}); ok {
+		for _, event := range events {
+			if m, ok := event.(map[string]interface{}); ok {
+				for _, field := range []string{"Name", "Namespace"} {
+					if v, ok := m[field].(string); ok {
+						m[field] = a.anonymizeName(v)
+					}
+				}
+			}
+		}
+	}
+
+	if data, ok := flow["Data"].(string); ok {
+		b, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			// never keep a payload that can't be anonymized
+			delete(flow, "Data")
+			return
+		}
+		flow["Data"] = base64.StdEncoding.EncodeToString(a.anonymizePacket(b))
+	}
+}
+
+// anonymizeRecord anonymizes a json encoded flow
+func (a *flowAnonymizer) anonymizeRecord(record []byte) ([]byte, config.GenericMap, error) {
+	flow := config.GenericMap{}
+	if err := json.Unmarshal(record, &flow); err != nil {
+		return nil, nil, err
+	}
+	a.anonymizeFlow(flow)
+	anonymized, err := json.Marshal(flow)
+	return anonymized, flow, err
+}
+
+// anonymizePacket rewrites ethernet and ip addresses and truncates the packet after its transport header,
+// whose checksum is computed again from the anonymized addresses
+func (a *flowAnonymizer) anonymizePacket(data []byte) []byte {
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	out := slices.Clone(data)
+	offset := 0
+	// anonymized source and destination of the network layer, part of transport checksums
+	var addresses []byte
+	for _, layer := range packet.Layers() {
+		contents := layer.LayerContents()
+		end := offset + len(contents)
+		switch l := layer.(type) {
+		case *layers.Ethernet:
+			copy(out[offset:], a.anonymizeHardwareAddr(l.DstMAC))
+			copy(out[offset+6:], a.anonymizeHardwareAddr(l.SrcMAC))
+		case *layers.Dot1Q:
+			// nothing to anonymize
+		case *layers.IPv4:
+			copy(out[offset+12:], net.ParseIP(a.anonymizeIP(l.SrcIP.String())).To4())
+			copy(out[offset+16:], net.ParseIP(a.anonymizeIP(l.DstIP.String())).To4())
+			setIPv4Checksum(out[offset:end])
+			addresses = out[offset+12 : offset+20]
+		case *layers.IPv6:
+			copy(out[offset+8:], net.ParseIP(a.anonymizeIP(l.SrcIP.String())).To16())
+			copy(out[offset+24:], net.ParseIP(a.anonymizeIP(l.DstIP.String())).To16())
+			addresses = out[offset+8 : offset+40]
+		// keep transport header only
+		case *layers.TCP:
+			setTransportChecksum(out[offset:end], addresses, layers.IPProtocolTCP, 16)
+			return out[:end]
+		case *layers.UDP:
+			// a zero checksum means none over ipv4
+			if l.Checksum != 0 || len(addresses) != net.IPv4len*2 {
+				setTransportChecksum(out[offset:end], addresses, layers.IPProtocolUDP, 6)
+			}
+			return out[:end]
+		case *layers.ICMPv6:
+			setTransportChecksum(out[offset:end], addresses, layers.IPProtocolICMPv6, 2)
+			return out[:end]
+		case *layers.SCTP, *layers.ICMPv4:
+			// checksums don't depend on addresses
+			return out[:end]
+		default:
+			// drop layers that may contain addresses or names
+			return out[:offset]
+		}
+		offset = end
+	}
+	return out[:offset]
+}
+
+func setIPv4Checksum(header []byte) {
+	if len(header) < 20 {
+		return
+	}
+	header[10], header[11] = 0, 0
+	checksum := foldChecksum(sumChecksum(0, header))
+	header[10], header[11] = byte(checksum>>8), byte(checksum)
+}
+
+// setTransportChecksum computes the checksum at pos of a segment, covering the ip pseudo-header
+// made of the addresses, the protocol and the segment length
+func setTransportChecksum(segment, addresses []byte, protocol layers.IPProtocol, pos int) {
+	if addresses == nil || len(segment) < pos+2 {
+		return
+	}
+	segment[pos], segment[pos+1] = 0, 0
+	length := len(segment)
+	var pseudo []byte
+	if len(addresses) == net.IPv4len*2 {
+		pseudo = []byte{0, byte(protocol), byte(length >> 8), byte(length)}
+	} else {
+		pseudo = []byte{byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length), 0, 0, 0, byte(protocol)}
+	}
+	checksum := foldChecksum(sumChecksum(sumChecksum(sumChecksum(0, addresses), pseudo), segment))
+	if checksum == 0 && protocol == layers.IPProtocolUDP {
+		checksum = 0xffff
+	}
+	segment[pos], segment[pos+1] = byte(checksum>>8), byte(checksum)
+}
+
+// sumChecksum adds data as 16 bits words, padding odd lengths with zero
+func sumChecksum(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+func foldChecksum(sum uint32) uint16 {
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+func runAnonymize(_ *cobra.Command, args []string) {
+	a, err := newFlowAnonymizer(anonymizeKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, path := range args {
+		out, err := anonymizeFile(a, path)
+		if err != nil {
+			log.Fatalf("Anonymizing %s failed: %v", path, err)
+		}
+		log.Infof("Anonymized %s to %s", path, out)
+	}
+}
+
+// anonymizeFile writes the anonymized version of a capture file and returns its path
+func anonymizeFile(a *flowAnonymizer, path string) (string, error) {
+	uncompressed, c := splitCompressionExt(path)
+	ext := filepath.Ext(uncompressed)
+	out := strings.TrimSuffix(uncompressed, ext) + anonymizedSuffix + ext
+
+	var err error
+	switch ext {
+	case ".db":
+		err = anonymizeDBFile(a, path, out)
+	case ".pcapng":
+		err = anonymizePacketsFile(a, path, out)
+	default:
+		err = anonymizeFlowsFile(a, path, out)
+	}
+	if err != nil {
+		os.Remove(out)
+		return "", err
+	}
+
+	if c != noCompression {
+		if err := compressFile(out, c); err != nil {
+			return "", err
+		}
+		out += getCompressionExt(c)
+	}
+	return out, nil
+}
+
+func anonymizeFlowsFile(a *flowAnonymizer, path, out string) error {
+	flows, err := readFlowsFile(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// json files are written as arrays, others as one flow per line
+	isArray := filepath.Ext(out) == ".json"
+	if isArray {
+		if _, err := f.WriteString("["); err != nil {
+			return err
+		}
+	}
+	for i, flow := range flows {
+		a.anonymizeFlow(flow)
+		b, err := json.Marshal(flow)
+		if err != nil {
+			return err
+		}
+		if isArray {
+			sep := ",\n"
+			if i == 0 {
+				sep = "\n"
+			}
+			b = append([]byte(sep), b...)
+		} else {
+			b = append(b, '\n')
+		}
+		if _, err := f.Write(b); err != nil {
+			return err
+		}
+	}
+	if isArray {
+		if _, err := f.WriteString(jsonArrayEnd); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func anonymizeDBFile(a *flowAnonymizer, path, out string) error {
+	flows, err := readFlowsDB(path)
+	if err != nil {
+		return err
+	}
+
+	// sqlite creates the database on open
+	if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := sql.Open("sqlite3", out)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := createFlowsDBTable(db); err != nil {
+		return err
+	}
+
+	// conversations are built again from the anonymized flows
+	writer, err := newFlowDBWriter(db, dbBatchSize, dbFlushInterval)
+	if err != nil {
+		return err
+	}
+	writer.start()
+	for _, flow := range flows {
+		a.anonymizeFlow(flow)
+		if err := writer.writeFlow(flow); err != nil {
+			writer.close()
+			return err
+		}
+	}
+	writer.close()
+	return db.Close()
+}
+
+// anonymizePacketsFile rewrites packets data and the values of their comments changed by the anonymization,
+// keeping the other comment lines as written
+func anonymizePacketsFile(a *flowAnonymizer, path, out string) error {
+	in, err := openDecompressed(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	ngr, err := pcapgo.NewNgReader(in, pcapgo.DefaultNgReaderOptions)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ngw, err := pcapgo.NewNgWriter(f, layers.LinkTypeEthernet)
+	if err != nil {
+		return err
+	}
+
+	fieldColumns := getCommentColumns()
+	columnIDs := map[string]string{}
+	for _, col := range cfg.Columns {
+		if name := toColName(col.ID, 0); columnIDs[name] == "" {
+			columnIDs[name] = col.ID
+		}
+	}
+	for count := 0; ; count++ {
+		data, ci, opts, err := ngr.ReadPacketDataWithOptions()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			// compressed capture interrupted before its end
+			log.Warnf("Capture ends unexpectedly after packet %d", count)
+			break
+		} else if err != nil {
+			return fmt.Errorf("error reading packet %d: %w", count, err)
+		}
+
+		// compare values displayed before and after anonymization
+		original := parsePacket(data, ci, opts.Comments, fieldColumns)
+		anonymized := parsePacket(data, ci, opts.Comments, fieldColumns)
+		delete(anonymized, "Data")
+		a.anonymizeFlow(anonymized)
+		comments := make([]string, len(opts.Comments))
+		for i, comment := range opts.Comments {
+			if comments[i], err = anonymizeComment(comment, original, anonymized, columnIDs); err != nil {
+				return err
+			}
+		}
+
+		data = a.anonymizePacket(data)
+		ci.Length, ci.CaptureLength = len(data), len(data)
+		if err := ngw.WritePacketWithOptions(ci, data, pcapgo.NgPacketOptions{Comments: comments}); err != nil {
+			return fmt.Errorf("error writing packet %d: %w", count, err)
+		}
+	}
+	if err := ngw.Flush(); err != nil {
+		return fmt.Errorf("error flushing packets: %w", err)
+	}
+	return f.Close()
+}
+
+// anonymizeComment rewrites the lines of a packet comment whose value changed once anonymized
+func anonymizeComment(comment string, original, anonymized config.GenericMap, columnIDs map[string]string) (string, error) {
+	if _, found := strings.CutPrefix(comment, rawCommentTitle); found {
+		raw, err := json.Marshal(anonymized)
+		if err != nil {
+			return "", err
+		}
+		return rawCommentTitle + string(raw), nil
+	}
+
+	lines := strings.Split(comment, "\n")
+	for i, line := range lines {
+		name, value, found := strings.Cut(line, ": ")
+		if !found {
+			continue
+		}
+		id, ok := columnIDs[name]
+		if !ok {
+			continue
+		}
+		before, after := toColValue(original, id, 0), toColValue(anonymized, id, 0)
+		switch {
+		case value == before && after != before:
+			lines[i] = fmt.Sprintf("%s: %s", name, after)
+		case value != before && id == "NetworkEvents":
+			// events are only parsed back from raw records, never keep their names
+			lines[i] = fmt.Sprintf("%s: %s", name, emptyText)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
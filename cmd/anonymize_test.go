@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	a, err := newFlowAnonymizer("secret")
+	assert.Nil(t, err)
+
+	first := net.ParseIP(a.anonymizeIP("10.128.0.29")).To4()
+	second := net.ParseIP(a.anonymizeIP("10.128.0.30")).To4()
+	other := net.ParseIP(a.anonymizeIP("10.129.0.29")).To4()
+	assert.NotEqual(t, "10.128.0.29", first.String())
+
+	// prefixes are preserved
+	assert.Equal(t, first[:3], second[:3])
+	assert.NotEqual(t, first[3], second[3])
+	assert.Equal(t, first[:1], other[:1])
+	assert.NotEqual(t, first[1], other[1])
+
+	// same key gives same values
+	b, err := newFlowAnonymizer("secret")
+	assert.Nil(t, err)
+	assert.Equal(t, first.String(), b.anonymizeIP("10.128.0.29"))
+	c, err := newFlowAnonymizer("other")
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.String(), c.anonymizeIP("10.128.0.29"))
+
+	v6 := net.ParseIP(a.anonymizeIP("fd00::1"))
+	assert.Len(t, v6.To16(), 16)
+	assert.Nil(t, v6.To4())
+	assert.Equal(t, v6[:15], net.ParseIP(a.anonymizeIP("fd00::2"))[:15])
+}
+
+func TestAnonymizeNames(t *testing.T) {
+	a, err := newFlowAnonymizer("secret")
+	assert.Nil(t, err)
+
+	ns := a.anonymizeName("first-namespace")
+	assert.True(t, strings.HasPrefix(ns, "anon-"))
+	assert.Equal(t, ns, a.anonymizeValue(anonymizedName, "first-namespace"))
+	assert.NotEqual(t, ns, a.anonymizeName("second-namespace"))
+	assert.Equal(t, "", a.anonymizeValue(anonymizedName, ""))
+
+	dns := strings.Split(a.anonymizeDNSName("www.example.com."), ".")
+	assert.Len(t, dns, 4)
+	assert.Equal(t, []string{"com", ""}, dns[2:])
+	assert.NotEqual(t, "www", dns[0])
+
+	mac, err := net.ParseMAC(a.anonymizeMAC("0A:58:0A:81:00:01"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, "0a:58:0a:81:00:01", mac.String())
+	assert.Equal(t, byte(0x02), mac[0]&0x03)
+	assert.Equal(t, "FF:FF:FF:FF:FF:FF", a.anonymizeMAC("FF:FF:FF:FF:FF:FF"))
+}
+
+func TestAnonymizeFlow(t *testing.T) {
+	a, err := newFlowAnonymizer("secret")
+	assert.Nil(t, err)
+
+	var flow config.GenericMap
+	assert.Nil(t, json.Unmarshal([]byte(sampleFlow), &flow))
+	flow["NetworkEvents"] = []interface{}{map[string]interface{}{"Feature": "acl", "Name": "deny-all", "Namespace": "first-namespace"}}
+	flow["Udns"] = []interface{}{"first-namespace/udn", ""}
+	flow["SrcSubnetLabel"] = "pods"
+	original := flow.Copy()
+	a.anonymizeFlow(flow)
+
+	// no address or name comes through unchanged
+	anonymized, err := json.Marshal(flow)
+	assert.Nil(t, err)
+	for field, v := range original {
+		value, ok := v.(string)
+		if !ok || value == "" {
+			continue
+		}
+		if _, anonymizedField := anonymizedFields[field]; anonymizedField || net.ParseIP(value) != nil {
+			assert.NotContains(t, string(anonymized), `"`+value+`"`, field)
+		}
+	}
+	assert.Equal(t, a.anonymizeIP("10.0.1.2"), flow["AgentIP"])
+	assert.Equal(t, []interface{}{a.anonymizeName("first-namespace/udn"), ""}, flow["Udns"])
+
+	assert.Equal(t, a.anonymizeIP("10.128.0.29"), flow["SrcAddr"])
+	assert.Equal(t, a.anonymizeName("first-namespace"), flow["SrcK8S_Namespace"])
+	assert.Equal(t, a.anonymizeName("src-pod"), flow["SrcK8S_Name"])
+	assert.Equal(t, a.anonymizeMAC("0A:58:0A:81:00:01"), flow["SrcMac"])
+	assert.Equal(t, "Pod", flow["SrcK8S_Type"])
+	assert.Equal(t, "us-east-1d", flow["SrcK8S_Zone"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"Feature": "acl", "Name": a.anonymizeName("deny-all"), "Namespace": a.anonymizeName("first-namespace"),
+	}}, flow["NetworkEvents"])
+}
+
+func TestAnonymizePacket(t *testing.T) {
+	a, err := newFlowAnonymizer("secret")
+	assert.Nil(t, err)
+
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x0a, 0x58, 0x0a, 0x81, 0x00, 0x01},
+		DstMAC:       net.HardwareAddr{0x0a, 0x58, 0x0a, 0x81, 0x00, 0x02},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP,
+		SrcIP: net.ParseIP("10.128.0.29"), DstIP: net.ParseIP("10.129.0.3")}
+	tcp := &layers.TCP{SrcPort: 1234, DstPort: 80, Window: 1024}
+	assert.Nil(t, tcp.SetNetworkLayerForChecksum(ip))
+	buf := gopacket.NewSerializeBuffer()
+	assert.Nil(t, gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		eth, ip, tcp, gopacket.Payload("GET /secret HTTP/1.1")))
+
+	// payload is truncated after transport header
+	anonymized := a.anonymizePacket(buf.Bytes())
+	assert.Len(t, anonymized, 14+20+20)
+	assert.NotContains(t, string(anonymized), "secret")
+
+	packet := gopacket.NewPacket(anonymized, layers.LayerTypeEthernet, gopacket.Default)
+	decodedEth := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	assert.Equal(t, a.anonymizeMAC("0A:58:0A:81:00:01"), strings.ToUpper(decodedEth.SrcMAC.String()))
+	decodedIP := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	assert.Equal(t, a.anonymizeIP("10.128.0.29"), decodedIP.SrcIP.String())
+	assert.Equal(t, a.anonymizeIP("10.129.0.3"), decodedIP.DstIP.String())
+
+	// ip checksum is still valid
+	checksum := decodedIP.Checksum
+	header := append([]byte{}, anonymized[14:34]...)
+	setIPv4Checksum(header)
+	assert.Equal(t, checksum, uint16(header[10])<<8|uint16(header[11]))
+	assert.Equal(t, uint16(1234), uint16(packet.Layer(layers.LayerTypeTCP).(*layers.TCP).SrcPort))
+
+	// transport checksums cover the anonymized addresses
+	decodedTCP := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+	assert.Equal(t, transportChecksum(t, decodedIP, decodedTCP), decodedTCP.Checksum)
+	udp := &layers.UDP{SrcPort: 1234, DstPort: 53}
+	ip.Protocol = layers.IPProtocolUDP
+	assert.Nil(t, udp.SetNetworkLayerForChecksum(ip))
+	buf = gopacket.NewSerializeBuffer()
+	assert.Nil(t, gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		eth, ip, udp))
+	packet = gopacket.NewPacket(a.anonymizePacket(buf.Bytes()), layers.LayerTypeEthernet, gopacket.Default)
+	decodedUDP := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	assert.Equal(t, transportChecksum(t, packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4), decodedUDP), decodedUDP.Checksum)
+}
+
+// transportChecksum computes the expected checksum of a decoded transport layer
+func transportChecksum(t *testing.T, ip *layers.IPv4, layer interface {
+	gopacket.SerializableLayer
+	SetNetworkLayerForChecksum(gopacket.NetworkLayer) error
+}) uint16 {
+	assert.Nil(t, layer.SetNetworkLayerForChecksum(ip))
+	buf := gopacket.NewSerializeBuffer()
+	assert.Nil(t, gopacket.SerializeLayers(buf, gopacket.SerializeOptions{ComputeChecksums: true}, layer))
+	packet := gopacket.NewPacket(buf.Bytes(), layer.LayerType(), gopacket.Default)
+	switch l := packet.Layers()[0].(type) {
+	case *layers.TCP:
+		return l.Checksum
+	case *layers.UDP:
+		return l.Checksum
+	}
+	return 0
+}
+
+func TestAnonymizeFiles(t *testing.T) {
+	setup(t)
+	a, err := newFlowAnonymizer("secret")
+	assert.Nil(t, err)
+	dir := t.TempDir()
+
+	// flows
+	path := filepath.Join(dir, "capture.ndjson")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"SrcAddr":"10.128.0.29","Bytes":1}`+"\n"), 0600))
+	out, err := anonymizeFile(a, path)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(dir, "capture_anonymized.ndjson"), out)
+	flows, err := readFlowsFile(out)
+	assert.Nil(t, err)
+	assert.Equal(t, []config.GenericMap{{"SrcAddr": a.anonymizeIP("10.128.0.29"), "Bytes": float64(1)}}, flows)
+
+	// database, conversations being built again from anonymized flows
+	db := createQueryTestDB(t, "capture.db", `{"SrcAddr":"10.128.0.29","SrcK8S_Name":"src-pod","Bytes":1}`)
+	out, err = anonymizeFile(a, db)
+	require.NoError(t, err)
+	flows, err = readFlowsDB(out)
+	require.NoError(t, err)
+	require.Len(t, flows, 1)
+	assert.Equal(t, a.anonymizeIP("10.128.0.29"), flows[0]["SrcAddr"])
+	assert.Equal(t, a.anonymizeName("src-pod"), flows[0]["SrcK8S_Name"])
+
+	// packets, comments lines being rewritten only where values changed
+	var packet config.GenericMap
+	require.NoError(t, json.Unmarshal([]byte(sampleFlow), &packet))
+	packet["Time"] = float64(1709742328)
+	packet["Data"] = base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	path = writeTestPackets(t, filepath.Join(dir, "capture.pcapng"), packet, -1)
+	out, err = anonymizeFile(a, path)
+	require.NoError(t, err)
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "10.128.0.29")
+	assert.NotContains(t, string(content), "src-pod")
+	packets, err := readPacketsFile(out)
+	require.NoError(t, err)
+	require.Len(t, packets, 1)
+	assert.Equal(t, a.anonymizeIP("10.128.0.29"), packets[0]["SrcAddr"])
+	assert.Equal(t, a.anonymizeName("src-pod"), packets[0]["SrcK8S_Name"])
+	// other values are kept as captured
+	for _, field := range []string{"Bytes", "TimeFlowStartMs", "TimeFlowEndMs", "Proto", "Time"} {
+		assert.Equal(t, packet[field], packets[0][field], field)
+	}
+	original := readTestComments(t, path)
+	anonymized := readTestComments(t, out)
+	require.Len(t, anonymized, len(original))
+	for i := range 3 {
+		originalLines := strings.Split(original[i], "\n")
+		anonymizedLines := strings.Split(anonymized[i], "\n")
+		require.Len(t, anonymizedLines, len(originalLines))
+		for j, line := range originalLines {
+			if strings.HasPrefix(line, toColName("Bytes", 0)+": ") || strings.HasPrefix(line, toColName("EndTime", 0)+": ") {
+				assert.Equal(t, line, anonymizedLines[j])
+			}
+		}
+	}
+
+	// comments written without raw record never keep network events names
+	packet["NetworkEvents"] = []interface{}{map[string]interface{}{
+		"Action": "drop", "Type": "NetworkPolicy", "Feature": "acl", "Direction": "Ingress", "Name": "deny-all", "Namespace": "secret-ns",
+	}}
+	path = writeTestPackets(t, filepath.Join(dir, "legacy.pcapng"), packet, 3)
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "secret-ns")
+	out, err = anonymizeFile(a, path)
+	require.NoError(t, err)
+	content, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "10.128.0.29")
+	assert.NotContains(t, string(content), "src-pod")
+	assert.NotContains(t, string(content), "secret-ns")
+}
+
+// writeTestPackets writes a single packet, keeping only the first comments when count is positive
+func writeTestPackets(t *testing.T, path string, packet config.GenericMap, count int) string {
+	buf := bytes.Buffer{}
+	ngw, err := pcapgo.NewNgWriter(&buf, layers.LinkTypeEthernet)
+	require.NoError(t, err)
+	data := packet["Data"]
+	writePacketData(ngw, &packet, &data)
+	require.NoError(t, ngw.Flush())
+
+	ngr, err := pcapgo.NewNgReader(&buf, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+	b, ci, opts, err := ngr.ReadPacketDataWithOptions()
+	require.NoError(t, err)
+	if count > 0 {
+		opts.Comments = opts.Comments[:count]
+	}
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	ngw, err = pcapgo.NewNgWriter(f, layers.LinkTypeEthernet)
+	require.NoError(t, err)
+	require.NoError(t, ngw.WritePacketWithOptions(ci, b, pcapgo.NgPacketOptions{Comments: opts.Comments}))
+	require.NoError(t, ngw.Flush())
+	return path
+}
+
+func readTestComments(t *testing.T, path string) []string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	ngr, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	require.NoError(t, err)
+	_, _, opts, err := ngr.ReadPacketDataWithOptions()
+	require.NoError(t, err)
+	return opts.Comments
+}
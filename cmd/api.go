@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	defaultAPIPort    = 9998
+	apiStatusInterval = time.Second     // time between two status events
+	apiCloseTimeout   = 5 * time.Second // max time to end the streams once the capture stopped
+	apiAddress        = "127.0.0.1"     // reachable through port-forward only since the stream is not authenticated
+)
+
+var apiPort = 0 // streaming API port set by --api-port, disabled unless set
+
+// apiEvent is a line of the /stream response: a record, the capture status, the live filters or a metric result
+type apiEvent struct {
+	Type    string            `json:"type"`
+	Seq     int64             `json:"seq,omitempty"`
+	Record  config.GenericMap `json:"record,omitempty"`
+	Status  *apiStatus        `json:"status,omitempty"`
+	Filters []string          `json:"filters,omitempty"`
+	Metrics *apiMetrics       `json:"metrics,omitempty"`
+}
+
+type apiStatus struct {
+	Capture    captureType   `json:"capture"`
+	Filename   string        `json:"filename,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Received   int64         `json:"received"`
+	Written    int64         `json:"written"`
+	TotalBytes int64         `json:"totalBytes"`
+	Text       string        `json:"text,omitempty"`
+	Viewers    int           `json:"viewers"`
+	Dropped    int64         `json:"dropped,omitempty"`
+	Stopped    string        `json:"stopped,omitempty"`
+}
+
+type apiMetrics struct {
+	Query  Query  `json:"query"`
+	Matrix Matrix `json:"matrix"`
+}
+
+// apiRecord is a published record, numbered so viewers reconnecting skip the ones already received
+type apiRecord struct {
+	seq  int64
+	flow config.GenericMap
+}
+
+// apiServer streams a capture session to any number of viewers, each one having its own bounded queue
+// so a slow viewer only drops its own events
+type apiServer struct {
+	session *CaptureSession
+	server  *http.Server
+
+	mutex   sync.Mutex
+	viewers map[*apiViewer]struct{}
+	closed  bool
+	seq     int64
+	history []apiRecord
+	filters []string
+	metrics map[string]*apiMetrics
+}
+
+type apiViewer struct {
+	events  chan apiEvent
+	filter  flowPredicate
+	dropped atomic.Int64
+}
+
+func newAPIServer(s *CaptureSession) *apiServer {
+	return &apiServer{
+		session: s,
+		viewers: map[*apiViewer]struct{}{},
+		history: []apiRecord{},
+		filters: []string{},
+		metrics: map[string]*apiMetrics{},
+	}
+}
+
+// startAPI serves the session on the loopback port, the capture running without it on error
+func (s *CaptureSession) startAPI(port int) {
+	a := newAPIServer(s)
+	listener, err := net.Listen("tcp", net.JoinHostPort(apiAddress, strconv.Itoa(port)))
+	if err != nil {
+		log.Errorf("Can't start streaming API: %v", err)
+		return
+	}
+	a.server = &http.Server{Handler: a.handler(), ReadHeaderTimeout: apiCloseTimeout}
+	go func() {
+		if err := a.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Streaming API failed: %v", err)
+		}
+	}()
+	log.Infof("Streaming API listening on %s", listener.Addr())
+	s.api = a
+}
+
+func (a *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stream", a.handleStream)
+	mux.HandleFunc("GET /status", a.handleStatus)
+	return mux
+}
+
+// handleStatus returns the current capture status
+func (a *apiServer) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.getStatus(nil)); err != nil {
+		log.Debugf("Can't write status: %v", err)
+	}
+}
+
+// handleStream sends one json event per line until the capture stops or the viewer leaves,
+// starting with the status, the live filters, the last records and metrics
+func (a *apiServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	var filter flowPredicate
+	if text := r.URL.Query().Get("filter"); text != "" {
+		predicate, err := parseFilter(text)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid filter: %v", err), http.StatusBadRequest)
+			return
+		}
+		filter = predicate
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	viewer, initial := a.addViewer(filter)
+	if viewer == nil {
+		http.Error(w, "capture stopped", http.StatusServiceUnavailable)
+		return
+	}
+	defer a.removeViewer(viewer)
+	log.Debugf("Viewer connected from %s", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	send := func(event apiEvent) bool {
+		if err := encoder.Encode(event); err != nil {
+			log.Debugf("Viewer %s left: %v", r.RemoteAddr, err)
+			return false
+		}
+		// flush once the queue is empty to batch records under load
+		if len(viewer.events) == 0 {
+			flusher.Flush()
+		}
+		return true
+	}
+	if !send(apiEvent{Type: "status", Status: a.getStatus(viewer)}) {
+		return
+	}
+	for _, event := range initial {
+		if !send(event) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(apiStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if !send(apiEvent{Type: "status", Status: a.getStatus(viewer)}) {
+				return
+			}
+		case event, ok := <-viewer.events:
+			if !ok {
+				// capture stopped, the last status tells why
+				send(apiEvent{Type: "status", Status: a.getStatus(viewer)})
+				flusher.Flush()
+				return
+			}
+			if !send(event) {
+				return
+			}
+		}
+	}
+}
+
+// addViewer registers a viewer and returns the events it missed, nil once closed
+func (a *apiServer) addViewer(filter flowPredicate) (*apiViewer, []apiEvent) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return nil, nil
+	}
+	viewer := &apiViewer{
+		events: make(chan apiEvent, displayQueueSize),
+		filter: filter,
+	}
+	a.viewers[viewer] = struct{}{}
+
+	initial := []apiEvent{{Type: "filters", Filters: a.filters}}
+	for _, record := range a.history {
+		if filter == nil || filter(record.flow) {
+			initial = append(initial, apiEvent{Type: "record", Seq: record.seq, Record: record.flow})
+		}
+	}
+	for _, metrics := range a.metrics {
+		initial = append(initial, apiEvent{Type: "metrics", Metrics: metrics})
+	}
+	return viewer, initial
+}
+
+func (a *apiServer) removeViewer(viewer *apiViewer) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.viewers, viewer)
+}
+
+// broadcast queues an event for every viewer without blocking, must be called with mutex locked
+func (a *apiServer) broadcast(event apiEvent, accept func(*apiViewer) bool) {
+	for viewer := range a.viewers {
+		if accept != nil && !accept(viewer) {
+			continue
+		}
+		select {
+		case viewer.events <- event:
+		default:
+			viewer.dropped.Add(1)
+		}
+	}
+}
+
+// publishRecord sends a copy of the record to the viewers it matches and keeps it for the next ones
+func (a *apiServer) publishRecord(flow config.GenericMap) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.closed {
+		return
+	}
+	a.seq++
+	record := apiRecord{seq: a.seq, flow: flow.Copy()}
+	a.history = append(a.history, record)
+	if len(a.history) > keepCount {
+		a.history = a.history[len(a.history)-keepCount:]
+	}
+	a.broadcast(apiEvent{Type: "record", Seq: record.seq, Record: record.flow}, func(viewer *apiViewer) bool {
+		return viewer.filter == nil || viewer.filter(record.flow)
+	})
+}
+
+// publishFilters sends the live filters of the collector display
+func (a *apiServer) publishFilters(filters []string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.filters = slices.Clone(filters)
+	a.broadcast(apiEvent{Type: "filters", Filters: a.filters}, nil)
+}
+
+// publishMetrics sends a metric query result, the last one of each query being kept for the next viewers
+func (a *apiServer) publishMetrics(query *Query, matrix *Matrix) {
+	if query == nil || matrix == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	metrics := &apiMetrics{Query: *query, Matrix: slices.Clone(*matrix)}
+	a.metrics[query.PromQL] = metrics
+	a.broadcast(apiEvent{Type: "metrics", Metrics: metrics}, nil)
+}
+
+func (a *apiServer) getViewerCount() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return len(a.viewers)
+}
+
+// getStatus describes the capture for a viewer, or for a single request if nil
+func (a *apiServer) getStatus(viewer *apiViewer) *apiStatus {
+	s := a.session
+	status := &apiStatus{
+		Capture:    s.capture,
+		Duration:   currentTime().Sub(s.startupTime),
+		Received:   s.received.Load(),
+		Written:    s.written.Load(),
+		TotalBytes: s.totalBytes.Load(),
+		Viewers:    a.getViewerCount(),
+	}
+	// outputs are set once the collector started
+	if s.collectorStarted.Load() {
+		status.Filename = s.filename
+		status.Text = strings.TrimSpace(s.getStatusText())
+	}
+	if viewer != nil {
+		status.Dropped = viewer.dropped.Load()
+	}
+	if cause := s.getStopCause(); cause != nil {
+		status.Stopped = cause.Error()
+	}
+	return status
+}
+
+// close ends every stream with the last status then stops the server
+func (a *apiServer) close() {
+	a.mutex.Lock()
+	a.closed = true
+	for viewer := range a.viewers {
+		close(viewer.events)
+		delete(a.viewers, viewer)
+	}
+	a.mutex.Unlock()
+
+	if a.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), apiCloseTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(ctx); err != nil {
+		log.Errorf("Can't stop streaming API: %v", err)
+	}
+}
+
+// onLiveFiltersChanged sends the live filters to the viewers of the current session
+func onLiveFiltersChanged() {
+	if session.api != nil {
+		session.api.publishFilters(liveFilters)
+	}
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func readEvent(t *testing.T, scanner *bufio.Scanner) apiEvent {
+	assert.True(t, scanner.Scan())
+	event := apiEvent{}
+	assert.Nil(t, json.Unmarshal(scanner.Bytes(), &event))
+	return event
+}
+
+func TestAPIStream(t *testing.T) {
+	setup(t)
+	a := newAPIServer(session)
+	session.api = a
+	server := httptest.NewServer(a.handler())
+	defer server.Close()
+
+	// records published before connecting are sent to new viewers
+	session.displayRecord(config.GenericMap{"SrcAddr": "10.0.0.1", "Bytes": float64(10)})
+	session.displayRecord(config.GenericMap{"SrcAddr": "10.0.0.2", "Bytes": float64(2000)})
+	a.publishFilters([]string{`src_namespace="netobserv"`})
+
+	resp, err := http.Get(server.URL + "/stream?filter=" + url.QueryEscape("Bytes>100"))
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	scanner := bufio.NewScanner(resp.Body)
+
+	event := readEvent(t, scanner)
+	assert.Equal(t, "status", event.Type)
+	assert.Equal(t, Flow, event.Status.Capture)
+	assert.Equal(t, 1, event.Status.Viewers)
+	event = readEvent(t, scanner)
+	assert.Equal(t, "filters", event.Type)
+	assert.Equal(t, []string{`src_namespace="netobserv"`}, event.Filters)
+	event = readEvent(t, scanner)
+	assert.Equal(t, "record", event.Type)
+	assert.Equal(t, int64(2), event.Seq)
+	assert.Equal(t, "10.0.0.2", event.Record["SrcAddr"])
+
+	// live records are filtered for each viewer
+	session.displayRecord(config.GenericMap{"SrcAddr": "10.0.0.3", "Bytes": float64(1)})
+	session.displayRecord(config.GenericMap{"SrcAddr": "10.0.0.4", "Bytes": float64(1000)})
+	event = readEvent(t, scanner)
+	assert.Equal(t, int64(4), event.Seq)
+	assert.Equal(t, "10.0.0.4", event.Record["SrcAddr"])
+	a.publishFilters([]string{})
+	event = readEvent(t, scanner)
+	assert.Equal(t, "filters", event.Type)
+	assert.Empty(t, event.Filters)
+
+	// the stream ends with the stop cause
+	session.stop(errCollectorEnded)
+	a.close()
+	event = readEvent(t, scanner)
+	for event.Status == nil || event.Status.Stopped == "" {
+		event = readEvent(t, scanner)
+	}
+	assert.Equal(t, "collector ended", event.Status.Stopped)
+	assert.False(t, scanner.Scan())
+
+	// invalid filters are rejected, as new viewers once closed
+	resp, err = http.Get(server.URL + "/stream?filter=" + url.QueryEscape("Bytes>"))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Get(server.URL + "/stream")
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestAttachClient(t *testing.T) {
+	setup(t)
+	a := newAttachClient("localhost:9998", "")
+	a.session = session
+	assert.Equal(t, "http://localhost:9998", a.address)
+	assert.Equal(t, " Reconnecting to http://localhost:9998...", a.getStatusText())
+
+	a.setConnected(true)
+	a.handle(apiEvent{Type: "status", Status: &apiStatus{Capture: Flow, TotalBytes: 2048, Viewers: 2, Dropped: 3}})
+	a.handle(apiEvent{Type: "filters", Filters: []string{"Bytes>10"}})
+	assert.Equal(t, int64(2048), session.totalBytes.Load())
+	assert.Equal(t, " Viewers: 2 Not streamed: 3 Collector filters: Bytes>10", a.getStatusText())
+
+	// records sent again after reconnecting are skipped
+	for _, seq := range []int64{1, 2, 1, 2, 3} {
+		a.handle(apiEvent{Type: "record", Seq: seq, Record: config.GenericMap{"Bytes": float64(seq)}})
+	}
+	flows := session.getLastFlows()
+	assert.Len(t, flows, 3)
+	assert.Equal(t, float64(3), flows[2]["Bytes"])
+
+	// a restarted collector numbers its records from 1 again
+	a.handle(apiEvent{Type: "status", Status: &apiStatus{Capture: Flow, Duration: time.Minute}})
+	a.handle(apiEvent{Type: "status", Status: &apiStatus{Capture: Flow, Duration: time.Second}})
+	a.handle(apiEvent{Type: "record", Seq: 1, Record: config.GenericMap{"Bytes": float64(10)}})
+	flows = session.getLastFlows()
+	assert.Len(t, flows, 4)
+	assert.Equal(t, float64(10), flows[3]["Bytes"])
+}
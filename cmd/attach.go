@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	attachRetryInterval  = 2 * time.Second  // time between two connection attempts
+	attachConnectTimeout = 30 * time.Second // max time to connect the first time, such as while port-forward starts
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach [address]",
+	Short: "Attach to a running capture",
+	Long:  "Display a running capture locally using the streaming API of its collector, such as through a port-forward",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   runAttach,
+}
+
+var (
+	attachFilter = ""
+	attached     *attachClient
+
+	errStreamRejected = errors.New("stream rejected")
+)
+
+// attachClient feeds the local display from the stream of a remote collector, reconnecting when it drops
+type attachClient struct {
+	// local session fed by the stream, set once the first status gave the capture type
+	session *CaptureSession
+	address string
+	filter  string
+	client  *http.Client
+
+	mutex     sync.Mutex
+	status    apiStatus
+	filters   []string
+	lastSeq   int64
+	connected bool
+}
+
+func runAttach(c *cobra.Command, args []string) {
+	address := fmt.Sprintf("localhost:%d", defaultAPIPort)
+	if len(args) > 0 {
+		address = args[0]
+	}
+	ctx, cancel := context.WithCancel(c.Context())
+	defer cancel()
+
+	a := newAttachClient(address, attachFilter)
+	body, status, err := a.connectWithRetry(ctx, attachConnectTimeout)
+	if err != nil {
+		log.Fatalf("Can't attach to %s: %v", address, err)
+	}
+	log.Infof("Attached to %s capture on %s", status.Capture, address)
+
+	// display the session as started by the collector
+	s := startSession(ctx, status.Capture)
+	s.startupTime = currentTime().Add(-status.Duration)
+	s.filename = status.Filename
+	s.totalBytes.Store(status.TotalBytes)
+	a.session = s
+	attached = a
+	go a.run(ctx, body)
+
+	if s.capture == Metric {
+		updateGraphs(false)
+		createMetricDisplay()
+	} else {
+		createFlowDisplay()
+	}
+	s.stop(errDisplayClosed)
+	if cause := s.getStopCause(); cause != nil {
+		log.Infof("Detached from %s: %v", address, cause)
+	}
+}
+
+func newAttachClient(address, filter string) *attachClient {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	return &attachClient{
+		address: strings.TrimSuffix(address, "/"),
+		filter:  filter,
+		client:  &http.Client{},
+	}
+}
+
+// connect opens the stream and reads its first event, giving the capture status
+func (a *attachClient) connect(ctx context.Context) (io.ReadCloser, *apiStatus, error) {
+	streamURL := a.address + "/stream"
+	if a.filter != "" {
+		streamURL += "?filter=" + url.QueryEscape(a.filter)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+		if resp.StatusCode == http.StatusBadRequest {
+			// retrying won't help
+			err = fmt.Errorf("%w: %w", errStreamRejected, err)
+		}
+		return nil, nil, err
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	event := apiEvent{}
+	if err := decoder.Decode(&event); err != nil {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("can't read status: %w", err)
+	}
+	if event.Type != "status" || event.Status == nil {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unexpected first event %s", event.Type)
+	}
+	a.handle(event)
+
+	// the decoder read ahead: keep reading the stream from its buffer
+	return &streamBody{Reader: io.MultiReader(decoder.Buffered(), resp.Body), Closer: resp.Body}, event.Status, nil
+}
+
+type streamBody struct {
+	io.Reader
+	io.Closer
+}
+
+// connectWithRetry tries to connect until timeout, forever if zero, stopping on bad requests
+func (a *attachClient) connectWithRetry(ctx context.Context, timeout time.Duration) (io.ReadCloser, *apiStatus, error) {
+	start := currentTime()
+	for {
+		body, status, err := a.connect(ctx)
+		if err == nil {
+			a.setConnected(true)
+			return body, status, nil
+		}
+		if errors.Is(err, errStreamRejected) || (timeout > 0 && currentTime().Sub(start) > timeout) {
+			return nil, nil, err
+		}
+		log.Debugf("Can't connect to %s, retrying: %v", a.address, err)
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(attachRetryInterval):
+		}
+	}
+}
+
+// run reads events until the remote capture stops, reconnecting when the stream drops
+func (a *attachClient) run(ctx context.Context, body io.ReadCloser) {
+	for {
+		err := a.read(body)
+		body.Close()
+		if ctx.Err() != nil || a.session.isStopped() {
+			return
+		}
+		if err == nil {
+			a.session.stop(fmt.Errorf("capture stopped: %s", a.getStatus().Stopped))
+			return
+		}
+		a.setConnected(false)
+		log.Debugf("Stream from %s lost: %v", a.address, err)
+		body, _, err = a.connectWithRetry(ctx, 0)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// read handles the events of a stream, returning nil once the remote capture stopped
+func (a *attachClient) read(body io.Reader) error {
+	decoder := json.NewDecoder(body)
+	for {
+		event := apiEvent{}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		a.handle(event)
+		if event.Status != nil && event.Status.Stopped != "" {
+			return nil
+		}
+	}
+}
+
+// handle updates the local session from an event, skipping records received before reconnecting
+func (a *attachClient) handle(event apiEvent) {
+	switch event.Type {
+	case "status":
+		if event.Status == nil {
+			return
+		}
+		a.mutex.Lock()
+		// a restarted collector numbers its records from 1 again
+		if a.status.Capture != "" && (event.Status.Filename != a.status.Filename || event.Status.Duration < a.status.Duration) {
+			a.lastSeq = 0
+		}
+		a.status = *event.Status
+		a.mutex.Unlock()
+		if a.session != nil {
+			a.session.totalBytes.Store(event.Status.TotalBytes)
+		}
+	case "filters":
+		a.mutex.Lock()
+		a.filters = event.Filters
+		a.mutex.Unlock()
+	case "record":
+		a.mutex.Lock()
+		skip := event.Seq <= a.lastSeq
+		if !skip {
+			a.lastSeq = event.Seq
+		}
+		a.mutex.Unlock()
+		if !skip && event.Record != nil && a.session != nil {
+			a.session.appendFlow(event.Record)
+		}
+	case "metrics":
+		if event.Metrics == nil {
+			return
+		}
+		for index := range graphs {
+			if graphs[index].Query.PromQL == event.Metrics.Query.PromQL {
+				appendMetrics(&event.Metrics.Query, &event.Metrics.Matrix, index)
+			}
+		}
+	default:
+		log.Debugf("Ignoring unknown event %s", event.Type)
+	}
+}
+
+func (a *attachClient) setConnected(connected bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.connected = connected
+}
+
+func (a *attachClient) getStatus() apiStatus {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.status
+}
+
+// getStatusText shows the remote capture health, viewers and live filters
+func (a *attachClient) getStatusText() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if !a.connected {
+		return fmt.Sprintf(" Reconnecting to %s...", a.address)
+	}
+	text := ""
+	if a.status.Text != "" {
+		text += " " + a.status.Text
+	}
+	text += fmt.Sprintf(" Viewers: %d", a.status.Viewers)
+	if a.status.Dropped > 0 {
+		text += fmt.Sprintf(" Not streamed: %d", a.status.Dropped)
+	}
+	if len(a.filters) > 0 {
+		text += fmt.Sprintf(" Collector filters: %s", strings.Join(a.filters, ", "))
+	}
+	return text
+}
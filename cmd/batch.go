@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	defaultBatchInterval = 5 * time.Second
+	defaultBatchFormat   = "table"
+	batchSnapshotColumn  = "Snapshot"
+)
+
+var (
+	batchEnabled    = false
+	batchInterval   = defaultBatchInterval
+	batchIterations = 0
+	batchColumns    = []string{}
+	batchFormat     = defaultBatchFormat
+	batchOutput     = ""
+
+	batchFormats     = []string{"table", "csv", "json", "ndjson", "markdown"}
+	errBatchFinished = errors.New("batch iterations done")
+)
+
+// isBatch tells if the table is printed periodically instead of being displayed
+func (s *CaptureSession) isBatch() bool {
+	return s.capture != Metric && batchEnabled
+}
+
+// validateBatch checks batch flags before the capture starts
+func (s *CaptureSession) validateBatch() error {
+	if !slices.Contains(batchFormats, batchFormat) {
+		return fmt.Errorf("unknown batch format %s, expected one of: %s", batchFormat, strings.Join(batchFormats, ", "))
+	}
+	if batchInterval <= 0 {
+		return fmt.Errorf("batch interval must be positive, got %s", batchInterval)
+	}
+	if batchOutput == "" && s.isHeadless() {
+		return errors.New("batch table can't be printed on stdout used by the stdout sink, use --batch-output")
+	}
+	for _, col := range batchColumns {
+		if !isColumn(col) {
+			return fmt.Errorf("unknown batch column %s", col)
+		}
+	}
+	return nil
+}
+
+// isColumn tells if id is a column of the config or a pseudo column
+func isColumn(id string) bool {
+	return id == rawDisplay ||
+		slices.ContainsFunc(cfg.Columns, func(c *ColumnConfig) bool { return c.ID == id }) ||
+		getPseudoColumn(id) != nil
+}
+
+// isHumanReadable tells if a batch format is meant to be read rather than parsed
+func isHumanReadable(format string) bool {
+	return format == "table" || format == "markdown"
+}
+
+// createBatchOutput creates the batch output file, relative paths going to the output directory
+// so the file is copied back with the capture files when running in the collector pod
+func createBatchOutput(path string) (*os.File, error) {
+	if filepath.IsAbs(path) {
+		return os.Create(path)
+	}
+	return createOutputFile("batch", path)
+}
+
+// runBatch prints the table at each interval until the iterations are done or the session stops
+func (s *CaptureSession) runBatch() {
+	w := io.Writer(os.Stdout)
+	if batchOutput != "" {
+		f, err := createBatchOutput(batchOutput)
+		if err != nil {
+			log.Fatalf("Can't create batch output: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if len(batchColumns) > 0 {
+		s.selectedColumns = batchColumns
+	}
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+	for iteration := 1; batchIterations <= 0 || iteration <= batchIterations; iteration++ {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.updateTableAndSuggestions()
+		if err := s.writeBatch(w, batchFormat, iteration == 1); err != nil {
+			log.Errorf("Can't write batch table: %v", err)
+		}
+	}
+	s.stop(errBatchFinished)
+}
+
+// writeBatch writes the current table, preceded by the capture status in human readable formats
+// machine readable formats are a single stream of rows, the csv header being written first only
+// and json writing one array per snapshot
+func (s *CaptureSession) writeBatch(w io.Writer, format string, first bool) error {
+	snapshot := currentTime().Format(time.RFC3339)
+	cols, rows := s.getBatchRecords(format, snapshot)
+	switch {
+	case isHumanReadable(format):
+		status := fmt.Sprintf("%s %s%s", snapshot, getDurationText(), getSizeText())
+		if _, err := fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(status)); err != nil {
+			return err
+		}
+		if err := writeRecords(w, format, cols, rows); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	case format == "csv" && !first:
+		return writeCSVRows(w, rows)
+	default:
+		return writeRecords(w, format, cols, rows)
+	}
+}
+
+// getBatchRecords returns the table cells as displayed, named by column ids in machine readable formats
+// where each row starts with the snapshot time
+func (s *CaptureSession) getBatchRecords(format, snapshot string) ([]string, [][]interface{}) {
+	names := []string{}
+	if !isHumanReadable(format) {
+		names = append(names, batchSnapshotColumn)
+	}
+	for _, id := range s.tableData.cols {
+		if isHumanReadable(format) {
+			names = append(names, toColName(id, 0))
+		} else {
+			names = append(names, id)
+		}
+	}
+	rows := [][]interface{}{}
+	for _, flow := range s.tableData.flows {
+		row := getBatchRow(flow, s.tableData.cols)
+		if !isHumanReadable(format) {
+			row = append([]interface{}{snapshot}, row...)
+		}
+		rows = append(rows, row)
+	}
+	return names, rows
+}
+
+func getBatchRow(flow config.GenericMap, cols []string) []interface{} {
+	row := []interface{}{}
+	for _, id := range cols {
+		row = append(row, strings.TrimSpace(toColValue(flow, id, 0)))
+	}
+	return row
+}
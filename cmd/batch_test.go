@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatch(t *testing.T) {
+	setup(t)
+	defer func() {
+		batchInterval = defaultBatchInterval
+		batchIterations = 0
+		batchColumns = []string{}
+		batchFormat = defaultBatchFormat
+		batchOutput = ""
+	}()
+	session.appendFlow(config.GenericMap{"SrcAddr": "10.0.0.1", "Bytes": float64(2048), "TimeFlowEndMs": float64(1)})
+	session.appendFlow(config.GenericMap{"SrcAddr": "10.0.0.2", "Bytes": float64(20), "TimeFlowEndMs": float64(2)})
+
+	// flags are checked before the capture starts
+	batchFormat = "xml"
+	assert.ErrorContains(t, session.validateBatch(), "unknown batch format xml, expected one of: table, csv, json, ndjson, markdown")
+	batchFormat = "csv"
+	batchColumns = []string{"SrcAddr", "Unknown"}
+	assert.ErrorContains(t, session.validateBatch(), "unknown batch column Unknown")
+	batchColumns = []string{"SrcAddr", "Bytes"}
+	assert.Nil(t, session.validateBatch())
+
+	currentTime = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	// rows are written on each iteration with their snapshot time, then the capture ends
+	batchOutput = filepath.Join(t.TempDir(), "batch.csv")
+	batchInterval = 10 * time.Millisecond
+	batchIterations = 2
+	session.runBatch()
+	assert.ErrorIs(t, session.getStopCause(), errBatchFinished)
+	out, err := os.ReadFile(batchOutput)
+	assert.Nil(t, err)
+	rows := "2026-01-02T03:04:05Z,10.0.0.1,2.05KB\n2026-01-02T03:04:05Z,10.0.0.2,20B\n"
+	assert.Equal(t, "Snapshot,SrcAddr,Bytes\n"+rows+rows, string(out))
+
+	// ndjson rows carry their snapshot time
+	buf := bytes.Buffer{}
+	assert.Nil(t, session.writeBatch(&buf, "ndjson", false))
+	assert.Equal(t, `{"Snapshot":"2026-01-02T03:04:05Z","SrcAddr":"10.0.0.1","Bytes":"2.05KB"}`+"\n"+
+		`{"Snapshot":"2026-01-02T03:04:05Z","SrcAddr":"10.0.0.2","Bytes":"20B"}`+"\n", buf.String())
+
+	// json prints an array per snapshot
+	buf.Reset()
+	assert.Nil(t, session.writeBatch(&buf, "json", false))
+	assert.Equal(t, "[\n"+`{"Snapshot":"2026-01-02T03:04:05Z","SrcAddr":"10.0.0.1","Bytes":"2.05KB"},`+"\n"+
+		`{"Snapshot":"2026-01-02T03:04:05Z","SrcAddr":"10.0.0.2","Bytes":"20B"}`+"\n]\n", buf.String())
+
+	// relative outputs go to the output directory copied back from the collector pod
+	f, err := createBatchOutput("batch_test.csv")
+	assert.Nil(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+	assert.Equal(t, "./output/batch/batch_test.csv", f.Name())
+
+	// human readable formats start with the capture status
+	buf.Reset()
+	assert.Nil(t, session.writeBatch(&buf, "markdown", true))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Contains(t, lines[0], "Duration:")
+	assert.Equal(t, []string{"", "| Src IP | Bytes |", "| --- | --- |", "| 10.0.0.1 | 2.05KB |", "| 10.0.0.2 | 20B |", "", ""}, lines[1:])
+}
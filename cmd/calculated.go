@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+// calcExpr is a compiled ColumnConfig.Calculated expression
+type calcExpr interface {
+	eval(flow config.GenericMap) interface{}
+}
+
+type calcLiteral struct{ value interface{} }
+
+type calcField struct{ name string }
+
+type calcColumn struct{ id string }
+
+type calcArray struct{ items []calcExpr }
+
+type calcOr struct{ exprs []calcExpr }
+
+type calcCall struct {
+	name string
+	args []calcExpr
+}
+
+var (
+	compiledCalculated      = map[string]calcExpr{}
+	compiledCalculatedMutex = sync.Mutex{}
+
+	calcFunctions = map[string]func(args []interface{}) interface{}{
+		"kubeObject": kubeObject,
+		"concat":     concat,
+		"substract": func(args []interface{}) interface{} {
+			return arithmetic(args, func(a, b float64) float64 { return a - b })
+		},
+		"multiply": func(args []interface{}) interface{} {
+			return arithmetic(args, func(a, b float64) float64 { return a * b })
+		},
+	}
+)
+
+func (e calcLiteral) eval(config.GenericMap) interface{} {
+	return e.value
+}
+
+func (e calcField) eval(flow config.GenericMap) interface{} {
+	return flow[e.name]
+}
+
+func (e calcColumn) eval(flow config.GenericMap) interface{} {
+	return evalColumn(flow, e.id)
+}
+
+func (e calcArray) eval(flow config.GenericMap) interface{} {
+	arr := []interface{}{}
+	for _, item := range e.items {
+		arr = append(arr, item.eval(flow))
+	}
+	return arr
+}
+
+// eval returns the first non empty value
+func (e calcOr) eval(flow config.GenericMap) interface{} {
+	for _, expr := range e.exprs {
+		if v := expr.eval(flow); !isEmptyValue(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e calcCall) eval(flow config.GenericMap) interface{} {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		args[i] = arg.eval(flow)
+	}
+	return calcFunctions[e.name](args)
+}
+
+func isEmptyValue(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// kubeObject(kind, namespace, name, showNamespace) returns the object name, prefixed by its namespace if asked
+func kubeObject(args []interface{}) interface{} {
+	if len(args) != 4 || isEmptyValue(args[2]) {
+		return nil
+	}
+	name := fmt.Sprintf("%v", args[2])
+	if show, ok := args[3].(float64); ok && show != 0 && !isEmptyValue(args[1]) {
+		return fmt.Sprintf("%v.%s", args[1], name)
+	}
+	return name
+}
+
+// concat returns the concatenated values as text, or nothing if one of them is missing
+func concat(args []interface{}) interface{} {
+	var sb strings.Builder
+	for _, arg := range args {
+		if isEmptyValue(arg) {
+			return nil
+		}
+		sb.WriteString(fmt.Sprintf("%v", arg))
+	}
+	return sb.String()
+}
+
+func arithmetic(args []interface{}, op func(a, b float64) float64) interface{} {
+	if len(args) != 2 {
+		return nil
+	}
+	a, okA := args[0].(float64)
+	b, okB := args[1].(float64)
+	if !okA || !okB {
+		return nil
+	}
+	return op(a, b)
+}
+
+// evalColumn returns the calculated value of a column or its field value
+func evalColumn(flow config.GenericMap, id string) interface{} {
+	colIndex := slices.IndexFunc(cfg.Columns, func(c *ColumnConfig) bool { return c.ID == id })
+	if colIndex == -1 {
+		return nil
+	}
+	col := cfg.Columns[colIndex]
+	if col.Calculated != "" {
+		expr, err := getCalculatedExpr(col.Calculated)
+		if err != nil {
+			log.Debugf("Can't evaluate %s: %v", id, err)
+			return nil
+		}
+		return expr.eval(flow)
+	}
+	return flow[col.Field]
+}
+
+// getCalculatedValues returns the values of the calculated columns missing from the flow,
+// restricted to ids when not empty, so they can be exported like fields
+func getCalculatedValues(flow config.GenericMap, ids []string) config.GenericMap {
+	values := config.GenericMap{}
+	for _, col := range cfg.Columns {
+		if col.Calculated == "" || (len(ids) > 0 && !slices.Contains(ids, col.ID)) {
+			continue
+		}
+		if _, found := flow[col.ID]; found {
+			continue
+		}
+		if v := evalColumn(flow, col.ID); !isEmptyValue(v) {
+			values[col.ID] = v
+		}
+	}
+	return values
+}
+
+// getCalculatedExpr compiles an expression once
+func getCalculatedExpr(text string) (calcExpr, error) {
+	compiledCalculatedMutex.Lock()
+	defer compiledCalculatedMutex.Unlock()
+
+	if expr, ok := compiledCalculated[text]; ok {
+		return expr, nil
+	}
+	p := calcParser{text: []rune(text)}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %s: %w", text, err)
+	}
+	p.skipSpaces()
+	if p.position < len(p.text) {
+		return nil, fmt.Errorf("invalid expression %s: unexpected %s", text, string(p.text[p.position:]))
+	}
+	compiledCalculated[text] = expr
+	return expr, nil
+}
+
+type calcParser struct {
+	text     []rune
+	position int
+}
+
+func (p *calcParser) skipSpaces() {
+	for p.position < len(p.text) && unicode.IsSpace(p.text[p.position]) {
+		p.position++
+	}
+}
+
+func (p *calcParser) consume(r rune) bool {
+	p.skipSpaces()
+	if p.position < len(p.text) && p.text[p.position] == r {
+		p.position++
+		return true
+	}
+	return false
+}
+
+func (p *calcParser) readIdentifier() string {
+	p.skipSpaces()
+	start := p.position
+	for p.position < len(p.text) {
+		r := p.text[p.position]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+			break
+		}
+		p.position++
+	}
+	return string(p.text[start:p.position])
+}
+
+func (p *calcParser) parseOr() (calcExpr, error) {
+	exprs := []calcExpr{}
+	for {
+		expr, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+
+		p.skipSpaces()
+		if !strings.HasPrefix(string(p.text[p.position:]), "or ") {
+			break
+		}
+		p.position += len("or ")
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return calcOr{exprs: exprs}, nil
+}
+
+func (p *calcParser) parseList(end rune) ([]calcExpr, error) {
+	items := []calcExpr{}
+	if p.consume(end) {
+		return items, nil
+	}
+	for {
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.consume(end) {
+			return items, nil
+		}
+		if !p.consume(',') {
+			return nil, fmt.Errorf("expected , or %c at %d", end, p.position)
+		}
+	}
+}
+
+func (p *calcParser) parseTerm() (calcExpr, error) {
+	p.skipSpaces()
+	if p.position >= len(p.text) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	switch r := p.text[p.position]; {
+	case r == '[':
+		p.position++
+		items, err := p.parseList(']')
+		if err != nil {
+			return nil, err
+		}
+		return calcArray{items: items}, nil
+	case r == '\'':
+		start := p.position + 1
+		end := start
+		for end < len(p.text) && p.text[end] != '\'' {
+			end++
+		}
+		if end >= len(p.text) {
+			return nil, fmt.Errorf("missing closing quote")
+		}
+		p.position = end + 1
+		return calcLiteral{value: string(p.text[start:end])}, nil
+	case unicode.IsDigit(r) || r == '-':
+		start := p.position
+		p.position++
+		for p.position < len(p.text) && (unicode.IsDigit(p.text[p.position]) || p.text[p.position] == '.') {
+			p.position++
+		}
+		n, err := strconv.ParseFloat(string(p.text[start:p.position]), 64)
+		if err != nil {
+			return nil, err
+		}
+		return calcLiteral{value: n}, nil
+	}
+
+	name := p.readIdentifier()
+	if name == "" {
+		return nil, fmt.Errorf("unexpected %c at %d", p.text[p.position], p.position)
+	}
+	if p.consume('(') {
+		if _, ok := calcFunctions[name]; !ok {
+			return nil, fmt.Errorf("unknown function %s", name)
+		}
+		args, err := p.parseList(')')
+		if err != nil {
+			return nil, err
+		}
+		return calcCall{name: name, args: args}, nil
+	}
+	if id, found := strings.CutPrefix(name, "column."); found {
+		return calcColumn{id: id}, nil
+	}
+	return calcField{name: name}, nil
+}
+
+// toCalculatedValue formats the calculated value of a column as text
+func toCalculatedValue(genericMap config.GenericMap, id string) string {
+	v := evalColumn(genericMap, id)
+	switch value := v.(type) {
+	case nil:
+		return emptyText
+	case []interface{}:
+		arr := make([]string, len(value))
+		for i, item := range value {
+			if isEmptyValue(item) {
+				arr[i] = emptyText
+			} else {
+				arr[i] = fmt.Sprintf("%v", item)
+			}
+		}
+		return strings.Join(arr, ",")
+	case float64:
+		switch id {
+		case "FlowDuration", "CollectionLatency":
+			return toDuration(config.GenericMap{id: value}, id, time.Millisecond)
+		}
+		return fmt.Sprintf("%v", value)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculatedColumns(t *testing.T) {
+	setup(t)
+
+	var flow config.GenericMap
+	err := json.Unmarshal([]byte(sampleFlow), &flow)
+	assert.Nil(t, err)
+
+	// kube objects
+	assert.Equal(t, "first-namespace.src-pod", toColValue(flow, "SrcK8S_Object", 0))
+	assert.Equal(t, "second-namespace.my-statefulset", toColValue(flow, "DstK8S_OwnerObject", 0))
+	assert.Equal(t, "first-namespace.src-pod,second-namespace.dst-pod", toColValue(flow, "K8S_Object", 0))
+
+	// concat
+	assert.Equal(t, "10.128.0.29:1234", toColValue(flow, "SrcAddrPort", 0))
+	assert.Equal(t, "10.128.0.29:1234,10.129.0.26:5678", toColValue(flow, "AddrPort", 0))
+
+	// arrays of fields
+	assert.Equal(t, "first-namespace,second-namespace", toColValue(flow, "K8S_Namespace", 0))
+	assert.Equal(t, "1234,5678", toColValue(flow, "Port", 0))
+
+	// arithmetic and column references
+	assert.Equal(t, "43ms", toColValue(flow, "FlowDuration", 0))
+	assert.Equal(t, "1.297s", toColValue(flow, "CollectionLatency", 0))
+
+	// columns with fields keep showing field value
+	assert.Equal(t, "src-pod", toColValue(flow, "SrcK8S_Name", 0))
+
+	// fallback on ip and port without kubernetes enrichment
+	noK8s := config.GenericMap{"SrcAddr": "10.0.0.1", "SrcPort": float64(80)}
+	assert.Equal(t, "10.0.0.1:80", toColValue(noK8s, "SrcK8S_Object", 0))
+	assert.Equal(t, emptyText, toColValue(noK8s, "SrcK8S_OwnerObject", 0))
+	assert.Equal(t, "n/a,n/a", toColValue(noK8s, "K8S_Name", 0))
+}
+
+func TestCalculatedExpressions(t *testing.T) {
+	flow := config.GenericMap{"Name": "pod", "Namespace": "ns", "Count": float64(2)}
+
+	for text, expected := range map[string]interface{}{
+		`kubeObject('Pod',Namespace,Name,0)`:             "pod",
+		`kubeObject('Pod',Namespace,Name,1)`:             "ns.pod",
+		`kubeObject('Node','',Name,1)`:                   "pod",
+		`kubeObject('Pod',Namespace,Missing,1) or 'n/a'`: "n/a",
+		`concat(Namespace, '/', Name)`:                   "ns/pod",
+		`concat(Missing,':',Name) or Name`:               "pod",
+		`multiply(Count,1000)`:                           float64(2000),
+		`substract(Count,0.5)`:                           float64(1.5),
+		`[Name,Missing]`:                                 []interface{}{"pod", nil},
+	} {
+		expr, err := getCalculatedExpr(text)
+		if assert.Nil(t, err, text) {
+			assert.Equal(t, expected, expr.eval(flow), text)
+		}
+	}
+
+	for _, text := range []string{`unknown(Name)`, `concat(Name`, `'missing quote`, `[Name,]`, `Name Namespace`} {
+		_, err := getCalculatedExpr(text)
+		assert.NotNil(t, err, text)
+	}
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	noCompression   = "none"
+	gzipCompression = "gzip"
+	gzipExt         = ".gz"
+	zstdCompression = "zstd"
+	zstdExt         = ".zst"
+
+	// compressed streams are flushed periodically so interrupted captures can be read up to there
+	compressFlushInterval = time.Second
+)
+
+var (
+	compression         = noCompression
+	countCompressedSize = false
+
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+func validateCompression() error {
+	switch compression {
+	case noCompression, gzipCompression, zstdCompression:
+		return nil
+	default:
+		return fmt.Errorf("unknown compression %s, expected %s, %s or %s", compression, noCompression, gzipCompression, zstdCompression)
+	}
+}
+
+func isCompressed() bool {
+	return compression != noCompression
+}
+
+// getCompressionExt returns the file extension of a compression
+func getCompressionExt(c string) string {
+	switch c {
+	case gzipCompression:
+		return gzipExt
+	case zstdCompression:
+		return zstdExt
+	default:
+		return ""
+	}
+}
+
+// splitCompressionExt returns a path without its compression extension and the matching compression
+func splitCompressionExt(path string) (string, string) {
+	for _, c := range []string{gzipCompression, zstdCompression} {
+		if ext := getCompressionExt(c); strings.HasSuffix(path, ext) {
+			return strings.TrimSuffix(path, ext), c
+		}
+	}
+	return path, noCompression
+}
+
+// compressWriter is a streaming compressor that can be flushed to make written data readable
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newCompressWriter(c string, w io.Writer) (compressWriter, error) {
+	switch c {
+	case gzipCompression:
+		return gzip.NewWriter(w), nil
+	case zstdCompression:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unknown compression %s", c)
+	}
+}
+
+// countingWriter counts bytes written to the underlying writer
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// outputFile writes to an output file, compressing its content when enabled
+type outputFile struct {
+	file      *os.File
+	disk      *countingWriter
+	cw        compressWriter
+	lastFlush time.Time
+}
+
+func createCompressedOutputFile(kind, filename string) (*outputFile, error) {
+	f, err := createOutputFile(kind, filename+getCompressionExt(compression))
+	if err != nil {
+		return nil, err
+	}
+	out := &outputFile{file: f, disk: &countingWriter{w: f}, lastFlush: currentTime()}
+	if isCompressed() {
+		if out.cw, err = newCompressWriter(compression, out.disk); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (o *outputFile) Name() string {
+	return o.file.Name()
+}
+
+func (o *outputFile) Write(p []byte) (int, error) {
+	if o.cw == nil {
+		return o.disk.Write(p)
+	}
+	n, err := o.cw.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if now := currentTime(); now.Sub(o.lastFlush) >= compressFlushInterval {
+		o.lastFlush = now
+		err = o.cw.Flush()
+	}
+	return n, err
+}
+
+// Flush sends compressed data to the file, which is written as is when not compressed
+func (o *outputFile) Flush() error {
+	if o.cw == nil {
+		return nil
+	}
+	return o.cw.Flush()
+}
+
+// getDiskSize returns the bytes written to the file, after compression
+func (o *outputFile) getDiskSize() int64 {
+	return o.disk.count
+}
+
+func (o *outputFile) Close() error {
+	if o.cw != nil {
+		if err := o.cw.Close(); err != nil {
+			o.file.Close()
+			return err
+		}
+	}
+	if err := o.file.Sync(); err != nil {
+		o.file.Close()
+		return err
+	}
+	return o.file.Close()
+}
+
+// countedSize returns the size to account for max bytes and rotation according to
+// the uncompressed size and the disk size written meanwhile
+func countedSize(uncompressed int, diskBefore, diskAfter int64) int64 {
+	if countCompressedSize {
+		return diskAfter - diskBefore
+	}
+	return int64(uncompressed)
+}
+
+// compressFile replaces a file by its compressed version, adding the compression extension
+func compressFile(path, c string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(path + getCompressionExt(c))
+	if err != nil {
+		return err
+	}
+	cw, err := newCompressWriter(c, dst)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err = io.Copy(cw, src); err != nil {
+		cw.Close()
+		dst.Close()
+		return err
+	}
+	if err = cw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+type decompressedReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressedReader) Close() error {
+	var err error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if cErr := d.closers[i].Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// openDecompressed opens a file, transparently decompressing gzip and zstd content
+func openDecompressed(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(f)
+	// peek errors only mean the file is too short to be compressed
+	magic, _ := reader.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &decompressedReader{Reader: gz, closers: []io.Closer{f, gz}}, nil
+	case bytes.Equal(magic, zstdMagic):
+		zr, err := zstd.NewReader(reader)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &decompressedReader{Reader: zr, closers: []io.Closer{f, zr.IOReadCloser()}}, nil
+	default:
+		return &decompressedReader{Reader: reader, closers: []io.Closer{f}}, nil
+	}
+}
+
+// withDecompressedDB runs fn on the database, decompressing it in a temporary file first if needed
+func withDecompressedDB(path string, fn func(path string) error) error {
+	if _, c := splitCompressionExt(path); c == noCompression {
+		return fn(path)
+	}
+
+	reader, err := openDecompressed(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tmp, err := os.CreateTemp("", "netobserv-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, reader); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error decompressing %s: %w", path, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return fn(tmp.Name())
+}
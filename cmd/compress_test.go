@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedFlowOutput(t *testing.T) {
+	defer func() { compression = noCompression }()
+
+	for c, ext := range map[string]string{gzipCompression: ".gz", zstdCompression: ".zst"} {
+		compression = c
+
+		// json array can't be compressed
+		_, err := newFlowOutputWriter("flow", "compress_test", jsonOutput)
+		assert.NotNil(t, err)
+
+		w, err := newFlowOutputWriter("flow", "compress_test", ndjsonOutput)
+		assert.Nil(t, err)
+		assert.Equal(t, "./output/flow/compress_test.ndjson"+ext, w.name())
+
+		// uncompressed size is counted by default
+		n, err := w.write([]byte(`{"Bytes":1}`))
+		assert.Nil(t, err)
+		assert.Equal(t, int64(12), n)
+		_, err = w.write([]byte(`{"Bytes":2}`))
+		assert.Nil(t, err)
+		assert.Nil(t, w.close())
+
+		flows, err := readFlowsFile(w.name())
+		assert.Nil(t, err, c)
+		assert.Equal(t, []config.GenericMap{{"Bytes": float64(1)}, {"Bytes": float64(2)}}, flows, c)
+		os.Remove(w.name())
+	}
+}
+
+func TestCompressedSize(t *testing.T) {
+	assert.Equal(t, int64(100), countedSize(100, 10, 30))
+
+	countCompressedSize = true
+	defer func() { countCompressedSize = false }()
+	assert.Equal(t, int64(20), countedSize(100, 10, 30))
+}
+
+func TestCompressedDBQuery(t *testing.T) {
+	setup(t)
+	defer resetQueryFlags()
+
+	querySQL = "SELECT SUM(Bytes) AS Bytes FROM flow"
+	for _, c := range []string{gzipCompression, zstdCompression} {
+		db := createQueryTestDB(t, "capture.db", `{"Bytes":1,"Proto":6}`, `{"Bytes":2,"Proto":17}`)
+		assert.Nil(t, compressFile(db, c))
+		_, err := os.Stat(db)
+		assert.True(t, os.IsNotExist(err))
+
+		compressed := db + getCompressionExt(c)
+		cols, rows, err := queryFlowsFiles([]string{compressed})
+		assert.Nil(t, err, c)
+		assert.Equal(t, []string{"Bytes"}, cols)
+		assert.Equal(t, [][]interface{}{{int64(3)}}, rows)
+
+		flows, err := readFlowsFile(compressed)
+		assert.Nil(t, err, c)
+		assert.Len(t, flows, 2)
+	}
+}
+
+func TestCompressionValidation(t *testing.T) {
+	defer func() { compression = noCompression }()
+
+	compression = gzipCompression
+	assert.Nil(t, validateCompression())
+
+	compression = zstdCompression
+	assert.Nil(t, validateCompression())
+
+	compression = "lz4"
+	assert.NotNil(t, validateCompression())
+}
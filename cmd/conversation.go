@@ -0,0 +1,369 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	maxConversations = 50000 // conversations tracked, new ones being ignored once reached
+
+	// tcp flags, including custom ones set by the agent
+	tcpFIN    = 0x01
+	tcpSYN    = 0x02
+	tcpRST    = 0x04
+	tcpACK    = 0x10
+	tcpSYNACK = 0x100
+	tcpFINACK = 0x200
+	tcpRSTACK = 0x400
+)
+
+var (
+	// pseudo columns only available in conversations display
+	conversationColumns = []*ColumnConfig{
+		{ID: "ConvState", Name: "State", Field: "ConvState", Tooltip: "The connection state, deduced from TCP flags seen in both directions.", Width: 11},
+		{ID: "ConvFlows", Name: "Reports", Field: "ConvFlows", Tooltip: "The number of flows merged in the conversation.", Width: 5},
+		{ID: "ConvDuration", Name: "Duration", Field: "ConvDuration", Tooltip: "The time between the first and the last packet of the conversation.", Width: 8},
+		{ID: "ConvBytesFwd", Name: "Bytes Sent", Field: "ConvBytesFwd", Tooltip: "The number of bytes sent by the source.", Width: 8},
+		{ID: "ConvBytesRev", Name: "Bytes Received", Field: "ConvBytesRev", Tooltip: "The number of bytes sent by the destination.", Width: 8},
+		{ID: "ConvPacketsFwd", Name: "Packets Sent", Field: "ConvPacketsFwd", Tooltip: "The number of packets sent by the source.", Width: 8},
+		{ID: "ConvPacketsRev", Name: "Packets Received", Field: "ConvPacketsRev", Tooltip: "The number of packets sent by the destination.", Width: 8},
+	}
+
+	// fields stored in the conversation table, in addition to the key
+	conversationDBFields = []*FieldConfig{
+		{Name: "_HashId", Type: "string"},
+		{Name: "SrcAddr", Type: "string"},
+		{Name: "SrcPort", Type: "number"},
+		{Name: "SrcK8S_Name", Type: "string"},
+		{Name: "SrcK8S_Type", Type: "string"},
+		{Name: "SrcK8S_OwnerName", Type: "string"},
+		{Name: "SrcK8S_OwnerType", Type: "string"},
+		{Name: "SrcK8S_Namespace", Type: "string"},
+		{Name: "DstAddr", Type: "string"},
+		{Name: "DstPort", Type: "number"},
+		{Name: "DstK8S_Name", Type: "string"},
+		{Name: "DstK8S_Type", Type: "string"},
+		{Name: "DstK8S_OwnerName", Type: "string"},
+		{Name: "DstK8S_OwnerType", Type: "string"},
+		{Name: "DstK8S_Namespace", Type: "string"},
+		{Name: "Proto", Type: "number"},
+		{Name: "TimeFlowStartMs", Type: "number"},
+		{Name: "TimeFlowEndMs", Type: "number"},
+		{Name: "Bytes", Type: "number"},
+		{Name: "Packets", Type: "number"},
+		{Name: "Flags", Type: "number"},
+		{Name: "ConvFlows", Type: "number"},
+		{Name: "ConvBytesFwd", Type: "number"},
+		{Name: "ConvBytesRev", Type: "number"},
+		{Name: "ConvPacketsFwd", Type: "number"},
+		{Name: "ConvPacketsRev", Type: "number"},
+		{Name: "ConvState", Type: "string"},
+	}
+)
+
+// conversation merges both directions and successive reports of a connection
+type conversation struct {
+	// endpoint fields oriented from the client to the server
+	fields     config.GenericMap
+	startMs    float64
+	endMs      float64
+	flows      float64
+	bytesFwd   float64
+	bytesRev   float64
+	packetsFwd float64
+	packetsRev float64
+	flagsFwd   int
+	flagsRev   int
+	// updated since last written to the database
+	dirty bool
+}
+
+type conversationTracker struct {
+	conversations map[string]*conversation
+	warned        bool
+	mutex         sync.Mutex
+}
+
+func newConversationTracker() *conversationTracker {
+	return &conversationTracker{conversations: map[string]*conversation{}}
+}
+
+func (s *CaptureSession) isConversationsDisplay() bool {
+	return len(s.selectedColumns) == 0 && display.getCurrentItem().name == conversationsDisplay
+}
+
+func getConversationCols() []string {
+	cols := []string{"EndTime"}
+	if enrichment.getCurrentItem().name != noOptions {
+		cols = append(cols, enrichment.getCurrentItem().ids...)
+	} else {
+		cols = append(cols, "SrcAddr", "SrcPort", "DstAddr", "DstPort")
+	}
+	return append(cols,
+		"Proto",
+		"ConvState",
+		"TCPFlags",
+		"ConvFlows",
+		"ConvDuration",
+		"ConvBytesFwd",
+		"ConvBytesRev",
+		"ConvPacketsFwd",
+		"ConvPacketsRev",
+	)
+}
+
+// getConversationKey returns the conversation id of the flow if any, or its 5-tuple in both directions
+func getConversationKey(flow config.GenericMap) string {
+	if id, ok := flow["_HashId"].(string); ok && id != "" {
+		return id
+	}
+	if _, ok := flow["SrcAddr"]; !ok {
+		return ""
+	} else if _, ok := flow["DstAddr"]; !ok {
+		return ""
+	}
+	src := fmt.Sprintf("%v|%v", flow["SrcAddr"], flow["SrcPort"])
+	dst := fmt.Sprintf("%v|%v", flow["DstAddr"], flow["DstPort"])
+	if src > dst {
+		src, dst = dst, src
+	}
+	return fmt.Sprintf("%v|%s|%s", flow["Proto"], src, dst)
+}
+
+// isReverseFlow returns true if the flow goes from the server to the client of the conversation
+func (c *conversation) isReverseFlow(flow config.GenericMap) bool {
+	return fmt.Sprintf("%v", flow["SrcAddr"]) == fmt.Sprintf("%v", c.fields["DstAddr"]) &&
+		fmt.Sprintf("%v", flow["SrcPort"]) == fmt.Sprintf("%v", c.fields["DstPort"]) &&
+		(flow["SrcAddr"] != flow["DstAddr"] || flow["SrcPort"] != flow["DstPort"])
+}
+
+// setFields copies the missing endpoint and common fields of the flow, swapping source and destination when reversed
+func (c *conversation) setFields(flow config.GenericMap, reverse bool) {
+	for k, v := range flow {
+		name := k
+		if reverse && strings.HasPrefix(k, "Src") {
+			name = "Dst" + strings.TrimPrefix(k, "Src")
+		} else if reverse && strings.HasPrefix(k, "Dst") {
+			name = "Src" + strings.TrimPrefix(k, "Dst")
+		} else if !strings.HasPrefix(k, "Src") && !strings.HasPrefix(k, "Dst") &&
+			k != "Proto" && k != "_HashId" && k != "K8S_ClusterName" {
+			continue
+		}
+		if _, found := c.fields[name]; !found {
+			c.fields[name] = v
+		}
+	}
+}
+
+func (c *conversation) add(flow config.GenericMap) {
+	reverse := c.isReverseFlow(flow)
+	c.setFields(flow, reverse)
+	c.flows++
+	if reverse {
+		c.bytesRev += toNumber(flow, "Bytes")
+		c.packetsRev += toNumber(flow, "Packets")
+		c.flagsRev |= int(toNumber(flow, "Flags"))
+	} else {
+		c.bytesFwd += toNumber(flow, "Bytes")
+		c.packetsFwd += toNumber(flow, "Packets")
+		c.flagsFwd |= int(toNumber(flow, "Flags"))
+	}
+
+	startMs, endMs := getFlowTimeRange(flow)
+	if c.startMs == 0 || startMs < c.startMs {
+		c.startMs = startMs
+	}
+	if endMs > c.endMs {
+		c.endMs = endMs
+	}
+	c.dirty = true
+}
+
+// getState returns the connection state from tcp flags seen in each direction
+func (c *conversation) getState() string {
+	if toNumber(c.fields, "Proto") != 6 {
+		if c.packetsFwd > 0 && c.packetsRev > 0 {
+			return "Replied"
+		}
+		return "Unreplied"
+	}
+
+	flags := c.flagsFwd | c.flagsRev
+	finFwd := c.flagsFwd&(tcpFIN|tcpFINACK) != 0
+	finRev := c.flagsRev&(tcpFIN|tcpFINACK) != 0
+	switch {
+	case flags&(tcpRST|tcpRSTACK) != 0:
+		return "Reset"
+	case finFwd && finRev:
+		return "Closed"
+	case finFwd || finRev:
+		return "Closing"
+	case flags&(tcpSYNACK|tcpACK) != 0:
+		return "Established"
+	case flags&tcpSYN != 0:
+		return "SynSent"
+	default:
+		return "Unknown"
+	}
+}
+
+// toGenericMap returns the conversation as a table row
+func (c *conversation) toGenericMap() config.GenericMap {
+	row := c.fields.Copy()
+	row["TimeFlowStartMs"] = c.startMs
+	row["TimeFlowEndMs"] = c.endMs
+	row["Bytes"] = c.bytesFwd + c.bytesRev
+	row["Packets"] = c.packetsFwd + c.packetsRev
+	row["Flags"] = float64(c.flagsFwd | c.flagsRev)
+	row["ConvFlows"] = c.flows
+	row["ConvDuration"] = c.endMs - c.startMs
+	row["ConvBytesFwd"] = c.bytesFwd
+	row["ConvBytesRev"] = c.bytesRev
+	row["ConvPacketsFwd"] = c.packetsFwd
+	row["ConvPacketsRev"] = c.packetsRev
+	row["ConvState"] = c.getState()
+	return row
+}
+
+// add merges a flow in its conversation, ignoring flows without addresses
+func (t *conversationTracker) add(flow config.GenericMap) {
+	key := getConversationKey(flow)
+	if key == "" || isDuplicate(flow) {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	c, ok := t.conversations[key]
+	if !ok {
+		if len(t.conversations) >= maxConversations {
+			if !t.warned {
+				log.Warnf("Tracking %d conversations, ignoring new ones", maxConversations)
+				t.warned = true
+			}
+			return
+		}
+		c = &conversation{fields: config.GenericMap{}}
+		// the lowest port is considered as the server one
+		srcPort, dstPort := toNumber(flow, "SrcPort"), toNumber(flow, "DstPort")
+		c.setFields(flow, srcPort > 0 && srcPort < dstPort)
+		t.conversations[key] = c
+	}
+	c.add(flow)
+}
+
+func (t *conversationTracker) reset() {
+	t.mutex.Lock()
+	t.conversations = map[string]*conversation{}
+	t.warned = false
+	t.mutex.Unlock()
+}
+
+func (t *conversationTracker) getFlows() []config.GenericMap {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	flows := make([]config.GenericMap, 0, len(t.conversations))
+	for key, c := range t.conversations {
+		row := c.toGenericMap()
+		row[rowIDField] = key
+		flows = append(flows, row)
+	}
+	return flows
+}
+
+// popUpdated returns the conversations updated since the last call as rows keyed by Id
+func (t *conversationTracker) popUpdated() []config.GenericMap {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rows := []config.GenericMap{}
+	for key, c := range t.conversations {
+		if !c.dirty {
+			continue
+		}
+		c.dirty = false
+		row := c.toGenericMap()
+		row[rowIDField] = key
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// getConversationFlows returns the conversations rows, the latest ones last unless another sort column is selected
+func (s *CaptureSession) getConversationFlows() []config.GenericMap {
+	flows := filterFlows(s.conversations.getFlows())
+	if s.sortColumn != "" {
+		s.sortFlows(flows)
+		if len(flows) > s.showCount {
+			flows = flows[:s.showCount]
+		}
+		return flows
+	}
+
+	slices.SortStableFunc(flows, func(a, b config.GenericMap) int {
+		if c := compareSortValues(toNumber(a, "TimeFlowEndMs"), toNumber(b, "TimeFlowEndMs")); c != 0 {
+			return c
+		}
+		return compareRowIDs(a, b)
+	})
+	if len(flows) > s.showCount {
+		flows = flows[len(flows)-s.showCount:]
+	}
+	return flows
+}
+
+// toConversationValue formats pseudo columns values
+func toConversationValue(genericMap config.GenericMap, id string) string {
+	v, ok := genericMap[id]
+	if !ok {
+		return emptyText
+	}
+	switch id {
+	case "ConvBytesFwd", "ConvBytesRev":
+		return toCount(genericMap, id)
+	case "ConvDuration":
+		return toDuration(genericMap, id, time.Millisecond)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func createConversationsDBTable(db *sql.DB) error {
+	cols := []string{`"Id" TEXT PRIMARY KEY`}
+	for _, field := range conversationDBFields {
+		cols = append(cols, fmt.Sprintf("%q %s", field.Name, toSQLType(field.Type)))
+	}
+	_, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS conversation (\n\t%s\n);", strings.Join(cols, ",\n\t")))
+	if err != nil {
+		log.Errorf("Error creating conversation table: %v", err.Error())
+	}
+	return err
+}
+
+// conversationUpsertSQL returns the statement replacing a conversation by its latest totals
+func conversationUpsertSQL() string {
+	cols := []string{`"Id"`}
+	placeholders := []string{"?"}
+	for _, field := range conversationDBFields {
+		cols = append(cols, fmt.Sprintf("%q", field.Name))
+		placeholders = append(placeholders, "?")
+	}
+	return fmt.Sprintf("INSERT OR REPLACE INTO conversation(%s) VALUES (%s)", strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+}
+
+func toConversationDBValues(row config.GenericMap) []interface{} {
+	values := []interface{}{row["Id"]}
+	for _, field := range conversationDBFields {
+		values = append(values, row[field.Name])
+	}
+	return values
+}
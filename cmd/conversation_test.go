@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// getConversationTestFlow returns a flow of the conversation between 10.0.0.1:40000 client and 10.0.0.2:80 server
+func getConversationTestFlow(reply bool, bytes, flags, timeMs int) string {
+	src, dst, srcPort, dstPort := "10.0.0.1", "10.0.0.2", 40000, 80
+	if reply {
+		src, dst, srcPort, dstPort = dst, src, dstPort, srcPort
+	}
+	return fmt.Sprintf(`{"SrcAddr":"%s","SrcPort":%d,"SrcK8S_Name":"pod-%s","DstAddr":"%s","DstPort":%d,"DstK8S_Name":"pod-%s",
+		"Proto":6,"Bytes":%d,"Packets":1,"Flags":%d,"TimeFlowStartMs":%d,"TimeFlowEndMs":%d}`,
+		src, srcPort, src, dst, dstPort, dst, bytes, flags, timeMs, timeMs+100)
+}
+
+func TestConversationTracker(t *testing.T) {
+	setup(t)
+	tracker := newConversationTracker()
+
+	// first report is the server reply, orientation still goes from client to server
+	for _, str := range []string{
+		getConversationTestFlow(true, 200, tcpSYNACK, 1000),
+		getConversationTestFlow(false, 100, tcpSYN|tcpACK, 1000),
+		getConversationTestFlow(false, 50, tcpACK, 5000),
+		`{"SrcAddr":"10.0.0.3","SrcPort":53000,"DstAddr":"10.0.0.4","DstPort":53,"Proto":17,"Bytes":10,"Packets":1,"TimeFlowStartMs":1000,"TimeFlowEndMs":1000}`,
+		`{"Bytes":1}`,
+	} {
+		var flow config.GenericMap
+		assert.Nil(t, json.Unmarshal([]byte(str), &flow))
+		tracker.add(flow)
+	}
+
+	rows := tracker.popUpdated()
+	assert.Len(t, rows, 2)
+	assert.Empty(t, tracker.popUpdated())
+	if rows[0]["Proto"] != float64(6) {
+		rows[0], rows[1] = rows[1], rows[0]
+	}
+
+	tcp := rows[0]
+	assert.Equal(t, "10.0.0.1", tcp["SrcAddr"])
+	assert.Equal(t, "pod-10.0.0.1", tcp["SrcK8S_Name"])
+	assert.Equal(t, float64(80), tcp["DstPort"])
+	assert.Equal(t, float64(3), tcp["ConvFlows"])
+	assert.Equal(t, float64(150), tcp["ConvBytesFwd"])
+	assert.Equal(t, float64(200), tcp["ConvBytesRev"])
+	assert.Equal(t, float64(350), tcp["Bytes"])
+	assert.Equal(t, float64(1), tcp["ConvPacketsRev"])
+	assert.Equal(t, float64(1000), tcp["TimeFlowStartMs"])
+	assert.Equal(t, float64(5100), tcp["TimeFlowEndMs"])
+	assert.Equal(t, float64(tcpSYN|tcpACK|tcpSYNACK), tcp["Flags"])
+	assert.Equal(t, "Established", tcp["ConvState"])
+
+	udp := rows[1]
+	assert.Equal(t, "Unreplied", udp["ConvState"])
+
+	// state follows flags seen in each direction
+	var flow config.GenericMap
+	assert.Nil(t, json.Unmarshal([]byte(getConversationTestFlow(false, 10, tcpFIN, 6000)), &flow))
+	tracker.add(flow)
+	rows = tracker.popUpdated()
+	assert.Len(t, rows, 1)
+	assert.Equal(t, "Closing", rows[0]["ConvState"])
+	assert.Nil(t, json.Unmarshal([]byte(getConversationTestFlow(true, 10, tcpFINACK, 6000)), &flow))
+	tracker.add(flow)
+	assert.Equal(t, "Closed", tracker.popUpdated()[0]["ConvState"])
+	assert.Nil(t, json.Unmarshal([]byte(getConversationTestFlow(true, 10, tcpRST, 7000)), &flow))
+	tracker.add(flow)
+	assert.Equal(t, "Reset", tracker.popUpdated()[0]["ConvState"])
+
+	// conversation id is used when available
+	assert.Equal(t, "abc", getConversationKey(config.GenericMap{"_HashId": "abc", "SrcAddr": "10.0.0.1", "DstAddr": "10.0.0.2"}))
+}
+
+func TestConversationsDisplay(t *testing.T) {
+	setup(t)
+	previousDisplay := display
+	previousEnrichment := enrichment
+	display = option{all: []optionItem{{name: conversationsDisplay}}}
+	enrichment = option{all: []optionItem{{name: noOptions}}}
+	defer func() {
+		display = previousDisplay
+		enrichment = previousEnrichment
+	}()
+
+	session.appendFlow(parseTestFlow(t, []byte(getConversationTestFlow(false, 1000, tcpSYN, 1704063600000))))
+	session.appendFlow(parseTestFlow(t, []byte(getConversationTestFlow(true, 3000, tcpSYNACK, 1704063601000))))
+	session.updateTableAndSuggestions()
+
+	assert.Equal(t, "Conversations", session.getTableTitle())
+	assert.Equal(t, []string{"EndTime", "SrcAddr", "SrcPort", "DstAddr", "DstPort", "Proto", "ConvState", "TCPFlags", "ConvFlows",
+		"ConvDuration", "ConvBytesFwd", "ConvBytesRev", "ConvPacketsFwd", "ConvPacketsRev"}, session.tableData.cols)
+	assert.Equal(t, []string{"10.0.0.1"}, getColumnValues(1))
+	assert.Equal(t, []string{"Established"}, getColumnValues(6))
+	assert.Equal(t, []string{"2"}, getColumnValues(8))
+	assert.Equal(t, []string{"1.1s"}, getColumnValues(9))
+	assert.Equal(t, []string{"1KB"}, getColumnValues(10))
+	assert.Equal(t, []string{"3KB"}, getColumnValues(11))
+}
+
+func TestConversationsDB(t *testing.T) {
+	setup(t)
+	db := openTestFlowDB(t)
+	defer db.Close()
+
+	w, err := newFlowDBWriter(db, 10, time.Hour)
+	assert.Nil(t, err)
+	w.start()
+	for _, reply := range []bool{false, true} {
+		bytes, flags := 100, tcpSYN
+		if reply {
+			bytes, flags = 200, tcpSYNACK
+		}
+		assert.Nil(t, w.writeFlow(parseTestFlow(t, []byte(getConversationTestFlow(reply, bytes, flags, 1000)))))
+	}
+	w.close()
+
+	cols, rows, err := queryDBRows(db, "SELECT SrcAddr, DstPort, Bytes, ConvFlows, ConvState FROM conversation")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"SrcAddr", "DstPort", "Bytes", "ConvFlows", "ConvState"}, cols)
+	assert.Equal(t, [][]interface{}{{"10.0.0.1", int64(80), int64(300), int64(2), "Established"}}, rows)
+}
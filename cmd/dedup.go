@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	noDedup            = "none"
+	markDedup          = "mark"
+	mergeDedup         = "merge"
+	defaultDedupWindow = 2 * time.Second
+	maxDedupGroups     = 50000 // flows held in memory before being released early
+)
+
+var (
+	dedupMode   = noDedup
+	dedupWindow = defaultDedupWindow
+
+	dedupColumns = []*ColumnConfig{
+		{ID: "Duplicate", Name: "Duplicate", Field: "Duplicate", Tooltip: "Whether the flow is another observation of a flow already reported.", Width: 9},
+	}
+
+	// fields stored in the flow table in addition to config.yaml ones
+	dedupDBFields = []*FieldConfig{
+		{Name: "Duplicate", Type: "boolean", Description: "Another observation of a flow already reported"},
+	}
+)
+
+type dedupRecord struct {
+	value     []byte
+	flow      config.GenericMap
+	duplicate bool
+}
+
+// dedupGroup holds the observations of the same flow from different interfaces and agents
+type dedupGroup struct {
+	key          string
+	until        time.Time
+	records      []dedupRecord
+	observations map[string]bool
+	done         bool
+}
+
+// flowDeduper holds flows for a time window to merge the observations of the same traffic
+// into a canonical flow, the other observations being flagged as duplicates
+type flowDeduper struct {
+	window  time.Duration
+	groups  map[string]*dedupGroup
+	pending []*dedupGroup
+	mutex   sync.Mutex
+}
+
+func newFlowDeduper(window time.Duration) *flowDeduper {
+	return &flowDeduper{
+		window: window,
+		groups: map[string]*dedupGroup{},
+	}
+}
+
+// initDeduper creates the deduplicator of the session from flags, if enabled
+func (s *CaptureSession) initDeduper() {
+	switch s.dedupMode {
+	case noDedup:
+		return
+	case markDedup, mergeDedup:
+		if dedupWindow <= 0 {
+			log.Fatalf("invalid deduplication window %s", dedupWindow)
+		}
+		s.deduper = newFlowDeduper(dedupWindow)
+		log.Infof("Deduplicating flows observed within %s (%s mode)", dedupWindow, s.dedupMode)
+	default:
+		log.Fatalf("invalid deduplication mode %s, expected %s, %s or %s", s.dedupMode, noDedup, markDedup, mergeDedup)
+	}
+}
+
+// isDuplicate returns true for flows flagged as duplicates, either here or by the agent
+func isDuplicate(flow config.GenericMap) bool {
+	v, _ := flow["Duplicate"].(bool)
+	return v
+}
+
+// withoutDuplicates returns the flows that are not flagged as duplicates
+func withoutDuplicates(flows []config.GenericMap) []config.GenericMap {
+	filtered := []config.GenericMap{}
+	for _, flow := range flows {
+		if !isDuplicate(flow) {
+			filtered = append(filtered, flow)
+		}
+	}
+	return filtered
+}
+
+// getDedupKey identifies a flow regardless of its observation point, returning an empty key
+// for flows that can't be deduplicated
+func getDedupKey(flow config.GenericMap) string {
+	if flow["SrcAddr"] == nil || flow["DstAddr"] == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v|%v|%v|%v|%v|%v|%v|%v", flow["Etype"], flow["Proto"],
+		flow["SrcAddr"], flow["SrcPort"], flow["DstAddr"], flow["DstPort"], flow["IcmpType"], flow["IcmpCode"])
+}
+
+type interfaceDirection struct {
+	name      interface{}
+	direction interface{}
+}
+
+// getInterfaceDirections returns the interface and direction pairs of a flow,
+// falling back on the single interface fields of older agents
+func getInterfaceDirections(flow config.GenericMap) []interfaceDirection {
+	pairs := []interfaceDirection{}
+	if interfaces, ok := flow["Interfaces"].([]interface{}); ok {
+		directions, _ := flow["IfDirections"].([]interface{})
+		for i, name := range interfaces {
+			var direction interface{}
+			if i < len(directions) {
+				direction = directions[i]
+			}
+			pairs = append(pairs, interfaceDirection{name: name, direction: direction})
+		}
+	} else if name, ok := flow["Interface"]; ok {
+		pairs = append(pairs, interfaceDirection{name: name, direction: flow["IfDirection"]})
+	}
+	return pairs
+}
+
+// getObservations returns the observation points of a flow as agent and interface pairs
+func getObservations(flow config.GenericMap) []string {
+	observations := []string{}
+	for _, pair := range getInterfaceDirections(flow) {
+		observations = append(observations, fmt.Sprintf("%v|%v", flow["AgentIP"], pair.name))
+	}
+	if len(observations) == 0 {
+		observations = append(observations, fmt.Sprintf("%v", flow["AgentIP"]))
+	}
+	return observations
+}
+
+// getObservationScore favors the flows reported by the source node agent while leaving it,
+// before any encapsulation or translation happens on the path
+func getObservationScore(flow config.GenericMap) int {
+	score := 0
+	if agent, ok := flow["AgentIP"]; ok && agent == flow["SrcK8S_HostIP"] {
+		score += 2
+	}
+	if direction, ok := flow["FlowDirection"].(float64); ok && direction == 1 {
+		score++
+	}
+	return score
+}
+
+// add holds a flow and returns the records of the groups ready to be released
+func (d *flowDeduper) add(value []byte, now time.Time) ([]dedupRecord, error) {
+	flow := config.GenericMap{}
+	if err := json.Unmarshal(value, &flow); err != nil {
+		return nil, err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	record := dedupRecord{value: value, flow: flow}
+	key := getDedupKey(flow)
+	if key == "" {
+		return append(d.expire(now), record), nil
+	}
+
+	records := []dedupRecord{}
+	observations := getObservations(flow)
+	group, found := d.groups[key]
+	if found && slices.ContainsFunc(observations, func(o string) bool { return group.observations[o] }) {
+		// same observation point reported again, this is the next report of the flow
+		records = append(records, d.release(group)...)
+		found = false
+	}
+	if !found {
+		if len(d.groups) >= maxDedupGroups {
+			log.Warnf("Deduplication reached %d flows, releasing them early", maxDedupGroups)
+			records = append(records, d.expire(now.Add(d.window))...)
+		}
+		group = &dedupGroup{key: key, until: now.Add(d.window), observations: map[string]bool{}}
+		d.groups[key] = group
+		d.pending = append(d.pending, group)
+	}
+	group.records = append(group.records, record)
+	for _, o := range observations {
+		group.observations[o] = true
+	}
+	return append(records, d.expire(now)...), nil
+}
+
+// expireAt returns the records of the groups held until now or before
+func (d *flowDeduper) expireAt(now time.Time) []dedupRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.expire(now)
+}
+
+// flush returns the records of every held group
+func (d *flowDeduper) flush() []dedupRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	records := []dedupRecord{}
+	for _, group := range d.pending {
+		records = append(records, d.release(group)...)
+	}
+	d.pending = []*dedupGroup{}
+	return records
+}
+
+func (d *flowDeduper) expire(now time.Time) []dedupRecord {
+	records := []dedupRecord{}
+	i := 0
+	for ; i < len(d.pending); i++ {
+		group := d.pending[i]
+		if group.until.After(now) {
+			break
+		}
+		records = append(records, d.release(group)...)
+	}
+	d.pending = d.pending[i:]
+	return records
+}
+
+func (d *flowDeduper) release(group *dedupGroup) []dedupRecord {
+	if group.done {
+		return nil
+	}
+	group.done = true
+	if d.groups[group.key] == group {
+		delete(d.groups, group.key)
+	}
+	return mergeDedupGroup(group.records)
+}
+
+// mergeDedupGroup returns the canonical flow, completed by the other observations, followed
+// by the duplicates
+func mergeDedupGroup(records []dedupRecord) []dedupRecord {
+	if len(records) == 1 {
+		return records
+	}
+
+	canonical := 0
+	for i, record := range records {
+		if getObservationScore(record.flow) > getObservationScore(records[canonical].flow) {
+			canonical = i
+		}
+	}
+
+	merged := records[canonical].flow.Copy()
+	pairs := []interfaceDirection{}
+	for _, record := range append([]dedupRecord{records[canonical]}, records...) {
+		for _, pair := range getInterfaceDirections(record.flow) {
+			if !slices.Contains(pairs, pair) {
+				pairs = append(pairs, pair)
+			}
+		}
+		// keep the widest time range and features only reported by other observations
+		for k, v := range record.flow {
+			if _, found := merged[k]; !found {
+				merged[k] = v
+			}
+		}
+		if start, ok := record.flow["TimeFlowStartMs"].(float64); ok && start < toFloat64(merged, "TimeFlowStartMs") {
+			merged["TimeFlowStartMs"] = start
+		}
+		if end, ok := record.flow["TimeFlowEndMs"].(float64); ok && end > toFloat64(merged, "TimeFlowEndMs") {
+			merged["TimeFlowEndMs"] = end
+		}
+	}
+	if len(pairs) > 0 {
+		interfaces := make([]interface{}, len(pairs))
+		directions := make([]interface{}, len(pairs))
+		for i, pair := range pairs {
+			interfaces[i] = pair.name
+			directions[i] = pair.direction
+		}
+		merged["Interfaces"] = interfaces
+		merged["IfDirections"] = directions
+	}
+	merged["Duplicate"] = false
+
+	result := []dedupRecord{toDedupRecord(merged, false)}
+	for i, record := range records {
+		if i == canonical {
+			continue
+		}
+		duplicate := record.flow.Copy()
+		duplicate["Duplicate"] = true
+		result = append(result, toDedupRecord(duplicate, true))
+	}
+	return result
+}
+
+func toDedupRecord(flow config.GenericMap, duplicate bool) dedupRecord {
+	value, err := json.Marshal(flow)
+	if err != nil {
+		log.Errorf("Can't marshal deduplicated flow: %v", err)
+	}
+	return dedupRecord{value: value, flow: flow, duplicate: duplicate}
+}
+
+// isWrittenDedupRecord returns false for duplicates that must be kept out of the outputs
+func (s *CaptureSession) isWrittenDedupRecord(record dedupRecord) bool {
+	return !record.duplicate || s.dedupMode != mergeDedup
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// getDedupTestFlow returns the observation of the same flow by an agent on an interface
+func getDedupTestFlow(agent, iface string, direction, timeMs int, extra string) []byte {
+	return []byte(fmt.Sprintf(`{"AgentIP":"%s","SrcK8S_HostIP":"10.0.0.1","DstK8S_HostIP":"10.0.0.2",
+		"SrcAddr":"10.128.0.1","SrcPort":40000,"DstAddr":"10.129.0.1","DstPort":8080,"Proto":6,"Etype":2048,
+		"FlowDirection":%d,"Interfaces":["%s"],"IfDirections":[%d],"Bytes":100,"Packets":1,
+		"TimeFlowStartMs":%d,"TimeFlowEndMs":%d%s}`, agent, direction, iface, direction, timeMs, timeMs+10, extra))
+}
+
+func TestDedupMerge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newFlowDeduper(2 * time.Second)
+
+	// destination node observation comes first
+	for _, value := range [][]byte{
+		getDedupTestFlow("10.0.0.2", "genev_sys_6081", 0, 1005, `,"PktDropPackets":1`),
+		getDedupTestFlow("10.0.0.1", "veth1", 1, 1000, ""),
+		getDedupTestFlow("10.0.0.1", "br-ex", 1, 1001, ""),
+	} {
+		records, err := d.add(value, now)
+		assert.Nil(t, err)
+		assert.Empty(t, records)
+	}
+	assert.Empty(t, d.expireAt(now.Add(time.Second)))
+
+	records := d.expireAt(now.Add(2 * time.Second))
+	assert.Len(t, records, 3)
+
+	// source node egress observation is the canonical one
+	canonical := records[0]
+	assert.False(t, canonical.duplicate)
+	assert.Equal(t, false, canonical.flow["Duplicate"])
+	assert.Equal(t, "10.0.0.1", canonical.flow["AgentIP"])
+	assert.Equal(t, []interface{}{"veth1", "genev_sys_6081", "br-ex"}, canonical.flow["Interfaces"])
+	assert.Equal(t, []interface{}{float64(1), float64(0), float64(1)}, canonical.flow["IfDirections"])
+	assert.Equal(t, float64(100), canonical.flow["Bytes"])
+	assert.Equal(t, float64(1), canonical.flow["PktDropPackets"])
+	assert.Equal(t, float64(1000), canonical.flow["TimeFlowStartMs"])
+	assert.Equal(t, float64(1015), canonical.flow["TimeFlowEndMs"])
+	assert.Contains(t, string(canonical.value), `"Duplicate":false`)
+
+	for _, record := range records[1:] {
+		assert.True(t, record.duplicate)
+		assert.True(t, isDuplicate(record.flow))
+		assert.Contains(t, string(record.value), `"Duplicate":true`)
+	}
+	assert.Empty(t, d.flush())
+}
+
+func TestDedupRelease(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := newFlowDeduper(2 * time.Second)
+
+	// flows without addresses are not held
+	records, err := d.add([]byte(`{"Bytes":1}`), now)
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, `{"Bytes":1}`, string(records[0].value))
+
+	// next report from the same observation point releases the previous one unchanged
+	first := getDedupTestFlow("10.0.0.1", "veth1", 1, 1000, "")
+	records, err = d.add(first, now)
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+	records, err = d.add(getDedupTestFlow("10.0.0.1", "veth1", 1, 1500, ""), now.Add(time.Second))
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, first, records[0].value)
+	assert.False(t, records[0].duplicate)
+
+	// pending flows are released on flush
+	records = d.flush()
+	assert.Len(t, records, 1)
+	assert.Equal(t, float64(1500), records[0].flow["TimeFlowStartMs"])
+	assert.Empty(t, d.expireAt(now.Add(time.Minute)))
+
+	_, err = d.add([]byte(`{`), now)
+	assert.NotNil(t, err)
+}
+
+func TestDedupDisplay(t *testing.T) {
+	setup(t)
+
+	session.appendFlow(parseTestFlow(t, getDedupTestFlow("10.0.0.1", "veth1", 1, 1000, `,"Duplicate":false`)))
+	session.appendFlow(parseTestFlow(t, getDedupTestFlow("10.0.0.2", "eth0", 0, 1000, `,"Duplicate":true`)))
+	session.updateTableAndSuggestions()
+
+	// duplicates are hidden and not aggregated
+	assert.Equal(t, "Flows", session.getTableTitle())
+	assert.Len(t, session.tableData.flows, 1)
+	assert.NotContains(t, session.tableData.cols, "Duplicate")
+	convs := session.conversations.getFlows()
+	assert.Len(t, convs, 1)
+	assert.Equal(t, float64(100), convs[0]["Bytes"])
+	assert.NotContains(t, session.getSuggestions(), "[eth0]")
+
+	session.showDuplicates.Store(true)
+	session.updateTableAndSuggestions()
+	assert.Equal(t, "Flows including duplicates", session.getTableTitle())
+	assert.Len(t, session.tableData.flows, 2)
+	assert.Contains(t, session.tableData.cols, "Duplicate")
+	assert.Equal(t, []string{"false", "true"}, getColumnValues(slices.Index(session.tableData.cols, "Duplicate")))
+	assert.Contains(t, session.getSuggestions(), "[eth0]")
+}
+
+func TestDedupMergeWrite(t *testing.T) {
+	dedupMode = mergeDedup
+	defer func() { dedupMode = noDedup }()
+	setup(t)
+
+	// merged duplicates are only displayed
+	assert.False(t, session.isWrittenDedupRecord(dedupRecord{duplicate: true}))
+	assert.True(t, session.isWrittenDedupRecord(dedupRecord{}))
+
+	// mode is read from flags when the session is created
+	dedupMode = markDedup
+	assert.False(t, session.isWrittenDedupRecord(dedupRecord{duplicate: true}))
+	assert.True(t, newCaptureSession(t.Context(), Flow).isWrittenDedupRecord(dedupRecord{duplicate: true}))
+}
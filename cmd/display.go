@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/jpillora/sizestr"
@@ -13,7 +14,6 @@ const (
 )
 
 var (
-	app             *tview.Application
 	pages           *tview.Pages
 	mainView        *tview.Flex
 	playPauseButton *tview.Button
@@ -22,16 +22,14 @@ var (
 	sizeText      = tview.NewTextView()
 	countTextView = tview.NewTextView()
 
-	showCount          = 1
 	framesPerSecond    = defaultFramesPerSecond
 	showPopup          bool
-	paused             = false
 	errAdvancedDisplay error
 	focus              = ""
 )
 
 func getPages() *tview.Pages {
-	if capture == Metric {
+	if session.capture == Metric {
 		pages = tview.NewPages().AddPage("main", getMetricMain(), true, true)
 
 		if showPopup {
@@ -62,11 +60,21 @@ func getModal(p tview.Primitive, width, height int) tview.Primitive {
 
 func getInfoRow() tview.Primitive {
 	playPauseButton = tview.NewButton(getPlayPauseText()).SetSelectedFunc(func() {
-		pause(!paused)
+		pause(!session.paused.Load())
 	})
 	infoRow := tview.NewFlex().SetDirection(tview.FlexColumn).
-		AddItem(tview.NewTextView().SetText(getcaptureText()), 0, 1, false).
-		AddItem(playPauseButton, 10, 0, false)
+		AddItem(tview.NewTextView().SetText(session.getCaptureText()), 0, 1, false)
+	if replay != nil {
+		infoRow.AddItem(tview.NewButton("⏪︎").SetSelectedFunc(func() {
+			replay.seek(-replaySeekStep)
+		}), 5, 0, false)
+	}
+	infoRow.AddItem(playPauseButton, 10, 0, false)
+	if replay != nil {
+		infoRow.AddItem(tview.NewButton("⏩︎").SetSelectedFunc(func() {
+			replay.seek(replaySeekStep)
+		}), 5, 0, false)
+	}
 	if logLevel != "info" {
 		infoRow.AddItem(tview.NewTextView().SetText(getLogLevelText()), 0, 1, false)
 	}
@@ -96,21 +104,21 @@ func getCountRow(useSpacer bool) *tview.Flex {
 	countRow := tview.NewFlex().SetDirection(tview.FlexColumn).
 		AddItem(countTextView, 0, 1, false).
 		AddItem(tview.NewButton("-").SetSelectedFunc(func() {
-			if showCount > 5 {
-				if capture == Metric {
-					showCount -= 5
+			if session.showCount > 5 {
+				if session.capture == Metric {
+					session.showCount -= 5
 				} else {
-					showCount--
+					session.showCount--
 				}
 			}
 			countTextView.SetText(getShowCountText())
 			updateScreen()
 		}), 5, 0, false).
 		AddItem(tview.NewButton("+").SetSelectedFunc(func() {
-			if capture == Metric {
-				showCount += 5
+			if session.capture == Metric {
+				session.showCount += 5
 			} else {
-				showCount++
+				session.showCount++
 			}
 			countTextView.SetText(getShowCountText())
 			updateScreen()
@@ -123,10 +131,10 @@ func getCountRow(useSpacer bool) *tview.Flex {
 }
 
 func getShowCountText() string {
-	if capture == Metric {
+	if session.capture == Metric {
 		return getMetricShowCountText()
 	}
-	return getFlowShowCountText()
+	return session.getFlowShowCountText()
 }
 
 func getFPSText() string {
@@ -134,20 +142,34 @@ func getFPSText() string {
 }
 
 func getPlayPauseText() string {
-	if paused {
+	if session.paused.Load() {
 		return "⏸︎"
 	}
 	return "⏵︎"
 }
 
 func getDurationText() string {
-	duration := currentTime().Sub(startupTime)
+	if replay != nil {
+		return replay.getProgressText()
+	}
+	duration := currentTime().Sub(session.startupTime)
 	return fmt.Sprintf("Duration: %s ", duration.Round(time.Second))
 }
 
 func getSizeText() string {
-	if capture != Metric {
-		return fmt.Sprintf("Capture size: %s", sizestr.ToString(totalBytes))
+	if session.capture != Metric {
+		text := fmt.Sprintf("Capture size: %s", sizestr.ToString(session.totalBytes.Load()))
+		// outputs are set once the collector started
+		if session.collectorStarted.Load() {
+			text += session.getStatusText()
+		}
+		if attached != nil {
+			text += attached.getStatusText()
+		}
+		return text
+	}
+	if attached != nil {
+		return strings.TrimSpace(attached.getStatusText())
 	}
 	return ""
 }
@@ -157,38 +179,48 @@ func updateStatusTexts() {
 	sizeText.SetText(getSizeText())
 }
 
-func hearbeat() {
+// hearbeat refreshes the display of the session until it ends
+func (s *CaptureSession) hearbeat() {
 	for {
-		if captureEnded {
+		if s.captureEnded.Load() {
+			return
+		}
+
+		// close the display when stopped by a signal to let the collector drain
+		if s.isStopped() {
+			if s.app != nil {
+				s.app.Stop()
+			}
 			return
 		}
 
 		updateStatusTexts()
-		if capture == Metric {
+		if s.capture == Metric {
 			updatePlots()
 		} else {
-			updateTableAndSuggestions()
+			s.updateTableAndSuggestions()
 		}
 
 		// refresh
-		if app != nil {
-			app.Draw()
+		if s.app != nil {
+			s.app.Draw()
 		}
 
 		time.Sleep(time.Second / time.Duration(framesPerSecond))
 	}
 }
 
-func backgroundHearbeat() {
+// backgroundHearbeat logs the table of the session periodically until it ends
+func (s *CaptureSession) backgroundHearbeat() {
 	for {
-		if captureEnded {
+		if s.captureEnded.Load() || s.isStopped() {
 			return
 		}
 
-		if capture != Metric {
-			updateTableAndSuggestions()
+		if s.capture != Metric {
+			s.updateTableAndSuggestions()
 			// simply print flow into logs
-			rows := getTableRows()
+			rows := s.getTableRows()
 			for _, row := range rows {
 				log.Println(row)
 			}
@@ -199,14 +231,14 @@ func backgroundHearbeat() {
 }
 
 func pause(pause bool) {
-	paused = pause
+	session.paused.Store(pause)
 	playPauseButton.SetLabel(getPlayPauseText())
 	updateScreen()
 }
 
 func updateScreen() {
-	if app != nil {
+	if session.app != nil {
 		showPopup = false
-		app.SetRoot(getPages(), true)
+		session.app.SetRoot(getPages(), true)
 	}
 }
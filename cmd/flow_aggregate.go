@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	maxAggregates = 10000 // keys to keep per aggregation, new keys being ignored once reached
+	maxRttSamples = 1000  // rtt values kept per key to compute percentiles
+)
+
+type flowAggregate struct {
+	key         config.GenericMap
+	flows       float64
+	bytes       float64
+	packets     float64
+	dropBytes   float64
+	dropPackets float64
+	rttSum      float64
+	rttCount    float64
+	rtts        []float64
+	startMs     float64
+	endMs       float64
+}
+
+// aggregateTracker keeps the aggregates per aggregation name and key, all aggregations being updated
+// to switch without losing history
+type aggregateTracker struct {
+	aggregates map[string]map[string]*flowAggregate
+	mutex      sync.Mutex
+}
+
+var (
+	// aggregations of top talkers display, cycled using the enrichment controls
+	aggregation = option{
+		all: []optionItem{
+			{name: "Namespace", ids: []string{"SrcK8S_Namespace", "DstK8S_Namespace"}},
+			{name: "Owner", ids: []string{"SrcK8S_Namespace", "SrcK8S_OwnerName", "DstK8S_Namespace", "DstK8S_OwnerName"}},
+			{name: "Node", ids: []string{"SrcK8S_HostName", "DstK8S_HostName"}},
+			{name: "Zone", ids: []string{"SrcZone", "DstZone"}},
+			{name: "IP & Protocol", ids: []string{"SrcAddr", "DstAddr", "Proto"}},
+		},
+		current: 0,
+	}
+
+	// pseudo columns only available in top talkers display
+	aggregateColumns = []*ColumnConfig{
+		{ID: "AggFlows", Name: "Flows", Field: "AggFlows", Tooltip: "The number of flows aggregated.", Width: 5},
+		{ID: "AggBytesRate", Name: "Bytes Rate", Field: "AggBytesRate", Tooltip: "The average number of bytes per second.", Width: 10},
+		{ID: "AggPacketsRate", Name: "Packets Rate", Field: "AggPacketsRate", Tooltip: "The average number of packets per second.", Width: 10},
+		{ID: "AggRttAvg", Name: "Avg RTT", Field: "AggRttAvg", Tooltip: "The average TCP Smoothed Round Trip Time.", Width: 5},
+		{ID: "AggRttP95", Name: "P95 RTT", Field: "AggRttP95", Tooltip: "The 95th percentile of TCP Smoothed Round Trip Time.", Width: 5},
+	}
+)
+
+func newAggregateTracker() *aggregateTracker {
+	return &aggregateTracker{aggregates: map[string]map[string]*flowAggregate{}}
+}
+
+func (s *CaptureSession) isTopTalkersDisplay() bool {
+	return len(s.selectedColumns) == 0 && display.getCurrentItem().name == topTalkersDisplay
+}
+
+// getEnrichmentOption returns the option cycled by the enrichment controls
+func (s *CaptureSession) getEnrichmentOption() *option {
+	if s.isTopTalkersDisplay() {
+		return &aggregation
+	}
+	return &enrichment
+}
+
+// getPseudoColumn returns the top talkers, conversations or deduplication column matching id
+func getPseudoColumn(id string) *ColumnConfig {
+	for _, cols := range [][]*ColumnConfig{aggregateColumns, conversationColumns, dedupColumns} {
+		colIndex := slices.IndexFunc(cols, func(c *ColumnConfig) bool { return c.ID == id })
+		if colIndex != -1 {
+			return cols[colIndex]
+		}
+	}
+	return nil
+}
+
+func getAggregateCols() []string {
+	cols := slices.Clone(aggregation.getCurrentItem().ids)
+	return append(cols,
+		"AggFlows",
+		"Bytes",
+		"Packets",
+		"AggBytesRate",
+		"AggPacketsRate",
+		"PktDropPackets",
+		"AggRttAvg",
+		"AggRttP95",
+	)
+}
+
+func (t *aggregateTracker) reset() {
+	t.mutex.Lock()
+	t.aggregates = map[string]map[string]*flowAggregate{}
+	t.mutex.Unlock()
+}
+
+// add adds a flow to each aggregation
+func (t *aggregateTracker) add(flow config.GenericMap) {
+	// duplicates would count the same traffic twice
+	if isDuplicate(flow) {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, item := range aggregation.all {
+		fields := make([]string, len(item.ids))
+		values := make([]string, len(item.ids))
+		for i, id := range item.ids {
+			fields[i] = toFieldName(id)
+			values[i] = fmt.Sprintf("%v", flow[fields[i]])
+		}
+		key := strings.Join(values, "|")
+
+		byKey, ok := t.aggregates[item.name]
+		if !ok {
+			byKey = map[string]*flowAggregate{}
+			t.aggregates[item.name] = byKey
+		}
+		agg, ok := byKey[key]
+		if !ok {
+			if len(byKey) >= maxAggregates {
+				continue
+			}
+			agg = &flowAggregate{key: config.GenericMap{}}
+			for _, field := range fields {
+				if v, found := flow[field]; found {
+					agg.key[field] = v
+				}
+			}
+			byKey[key] = agg
+		}
+		agg.add(flow)
+	}
+}
+
+func (a *flowAggregate) add(flow config.GenericMap) {
+	a.flows++
+	a.bytes += toNumber(flow, "Bytes")
+	a.packets += toNumber(flow, "Packets")
+	a.dropBytes += toNumber(flow, "PktDropBytes")
+	a.dropPackets += toNumber(flow, "PktDropPackets")
+	if rtt := toNumber(flow, "TimeFlowRttNs"); rtt > 0 {
+		a.rttSum += rtt
+		a.rttCount++
+		a.rtts = append(a.rtts, rtt)
+		if len(a.rtts) > maxRttSamples {
+			a.rtts = a.rtts[len(a.rtts)-maxRttSamples:]
+		}
+	}
+
+	startMs, endMs := getFlowTimeRange(flow)
+	if a.startMs == 0 || startMs < a.startMs {
+		a.startMs = startMs
+	}
+	if endMs > a.endMs {
+		a.endMs = endMs
+	}
+}
+
+// toGenericMap returns the aggregate as a table row
+func (a *flowAggregate) toGenericMap() config.GenericMap {
+	row := a.key.Copy()
+	row["AggFlows"] = a.flows
+	row["Bytes"] = a.bytes
+	row["Packets"] = a.packets
+	row["PktDropBytes"] = a.dropBytes
+	row["PktDropPackets"] = a.dropPackets
+
+	// rates are computed over the aggregate lifetime, at least a second
+	seconds := max((a.endMs-a.startMs)/1000, 1)
+	row["AggBytesRate"] = a.bytes / seconds
+	row["AggPacketsRate"] = a.packets / seconds
+
+	if a.rttCount > 0 {
+		row["AggRttAvg"] = a.rttSum / a.rttCount
+		sorted := slices.Clone(a.rtts)
+		slices.Sort(sorted)
+		row["AggRttP95"] = sorted[(len(sorted)*95-1)/100]
+	}
+	return row
+}
+
+func toNumber(flow config.GenericMap, fieldName string) float64 {
+	switch v := flow[fieldName].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	default:
+		return 0
+	}
+}
+
+// getFlowTimeRange returns flow start and end times in milliseconds
+func getFlowTimeRange(flow config.GenericMap) (float64, float64) {
+	if getTimeField(flow) == "TimeFlowEndMs" {
+		return toNumber(flow, "TimeFlowStartMs"), toNumber(flow, "TimeFlowEndMs")
+	}
+	// packets time is in seconds
+	t := toNumber(flow, "Time") * 1000
+	return t, t
+}
+
+// getFlows returns the rows of an aggregation
+func (t *aggregateTracker) getFlows(name string) []config.GenericMap {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	flows := make([]config.GenericMap, 0, len(t.aggregates[name]))
+	for key, agg := range t.aggregates[name] {
+		row := agg.toGenericMap()
+		row[rowIDField] = key
+		flows = append(flows, row)
+	}
+	return flows
+}
+
+// getAggregatedFlows returns the rows of the current aggregation, sorted by bytes unless
+// another sort column is selected
+func (s *CaptureSession) getAggregatedFlows() []config.GenericMap {
+	flows := filterFlows(s.aggregates.getFlows(aggregation.getCurrentItem().name))
+	if s.sortColumn == "" {
+		slices.SortStableFunc(flows, func(a, b config.GenericMap) int {
+			if c := compareSortValues(toNumber(b, "Bytes"), toNumber(a, "Bytes")); c != 0 {
+				return c
+			}
+			return compareRowIDs(a, b)
+		})
+	} else {
+		s.sortFlows(flows)
+	}
+	if len(flows) > s.showCount {
+		flows = flows[:s.showCount]
+	}
+	return flows
+}
+
+// toAggregateValue formats pseudo columns values
+func toAggregateValue(genericMap config.GenericMap, id string) string {
+	v, ok := genericMap[id]
+	if !ok {
+		return emptyText
+	}
+	switch id {
+	case "AggBytesRate":
+		return toCount(genericMap, id) + "/s"
+	case "AggPacketsRate":
+		return fmt.Sprintf("%.1f/s", v)
+	case "AggRttAvg", "AggRttP95":
+		return toDuration(genericMap, id, time.Nanosecond)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
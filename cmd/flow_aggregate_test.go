@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopTalkers(t *testing.T) {
+	setup(t)
+	previousDisplay := display
+	display = option{all: []optionItem{{name: topTalkersDisplay}}}
+	aggregation.current = 0
+	defer func() { display = previousDisplay }()
+
+	for i, flow := range []struct {
+		src, dst string
+		bytes    int
+		rtt      int
+	}{
+		{"ns-a", "ns-b", 1000, 1000},
+		{"ns-a", "ns-b", 3000, 3000},
+		{"ns-c", "ns-b", 500, 0},
+		{"ns-a", "ns-c", 20000, 2000},
+	} {
+		session.appendFlow(parseTestFlow(t, []byte(fmt.Sprintf(`{
+			"SrcK8S_Namespace":"%s",
+			"DstK8S_Namespace":"%s",
+			"Bytes":%d,
+			"Packets":2,
+			"PktDropPackets":1,
+			"TimeFlowRttNs":%d,
+			"TimeFlowStartMs":%d,
+			"TimeFlowEndMs":%d
+		}`, flow.src, flow.dst, flow.bytes, flow.rtt, 1704063600000+i*1000, 1704063600000+i*1000+500))))
+	}
+	session.updateTableAndSuggestions()
+
+	assert.Equal(t, "Top talkers by Namespace", session.getTableTitle())
+	assert.Equal(t, []string{"SrcK8S_Namespace", "DstK8S_Namespace", "AggFlows", "Bytes", "Packets", "AggBytesRate", "AggPacketsRate", "PktDropPackets", "AggRttAvg", "AggRttP95"}, session.tableData.cols)
+
+	// sorted by bytes by default
+	assert.Equal(t, []string{"ns-a", "ns-a", "ns-c"}, getColumnValues(0))
+	assert.Equal(t, []string{"ns-c", "ns-b", "ns-b"}, getColumnValues(1))
+	assert.Equal(t, []string{"1", "2", "1"}, getColumnValues(2))
+	assert.Equal(t, []string{"20KB", "4KB", "500B"}, getColumnValues(3))
+	assert.Equal(t, []string{"2", "4", "2"}, getColumnValues(4))
+	assert.Equal(t, []string{"20KB/s", "2.67KB/s", "500B/s"}, getColumnValues(5))
+	assert.Equal(t, []string{"2.0/s", "2.7/s", "2.0/s"}, getColumnValues(6))
+	assert.Equal(t, []string{"1", "2", "1"}, getColumnValues(7))
+	assert.Equal(t, []string{"2µs", "2µs", "n/a"}, getColumnValues(8))
+	assert.Equal(t, []string{"2µs", "3µs", "n/a"}, getColumnValues(9))
+
+	// live filters apply on keys
+	liveFilters = []string{`src_namespace="ns-a"`}
+	session.updateTableAndSuggestions()
+	assert.Equal(t, []string{"ns-c", "ns-b"}, getColumnValues(1))
+
+	// switching aggregation keeps the whole history
+	liveFilters = []string{}
+	aggregation.current = 2
+	defer func() { aggregation.current = 0 }()
+	session.updateTableAndSuggestions()
+	assert.Equal(t, "Aggregate by: Node\n", session.getEnrichmentText())
+	assert.Equal(t, []string{"4"}, getColumnValues(2))
+}
@@ -2,12 +2,10 @@ package cmd
 
 import (
 	"encoding/json"
-	"strings"
+	"fmt"
 	"time"
 
-	"github.com/jpillora/sizestr"
 	"github.com/netobserv/flowlogs-pipeline/pkg/config"
-	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/utils"
 	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/write/grpc"
 	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/write/grpc/genericmap"
 
@@ -21,39 +19,33 @@ var flowCmd = &cobra.Command{
 	Run:   runFlowCapture,
 }
 
-func runFlowCapture(_ *cobra.Command, _ []string) {
-	capture = Flow
-	showCount = defaultFlowShowCount
-	if isBackground {
-		go backgroundHearbeat() // show table periodically in background
-		startFlowCollector()
-	} else {
-		go startFlowCollector()
-		createFlowDisplay()
-	}
+func runFlowCapture(c *cobra.Command, _ []string) {
+	s := startSession(c.Context(), Flow)
+	s.run(s.startFlowCollector, createFlowDisplay)
 }
 
-func startFlowCollector() {
-	if len(filename) > 0 {
-		log.Infof("Starting Flow Capture for %s...", filename)
+func (s *CaptureSession) startFlowCollector() {
+	if len(s.filename) > 0 {
+		log.Infof("Starting Flow Capture for %s...", s.filename)
 	} else {
 		log.Infof("Starting Flow Capture...")
-		filename = strings.ReplaceAll(
-			currentTime().UTC().Format(time.RFC3339),
-			":", "") // get rid of offensive colons
 	}
 
-	// Create a text file to receive json chunks; the file will be fixed and renamed as json later, when pulled in shell.
-	f, err := createOutputFile("flow", filename+".txt")
-	if err != nil {
-		log.Fatalf("Creating output file failed: %v", err)
+	if err := validateCompression(); err != nil {
+		log.Fatal(err)
 	}
-	defer f.Close()
-	log.Debugf("Created flow logs txt file: %s", f.Name())
-
-	// Initialize sqlite DB
-	db := initFlowDB(filename)
-	log.Debug("Initialized database")
+	if isCompressed() && outputFormat == jsonOutput {
+		// json array end is rewritten on each write, which is not possible in a compressed stream
+		log.Fatalf("%s output format can't be compressed, use --output-format=%s instead", jsonOutput, ndjsonOutput)
+	}
+	s.initTrigger()
+	s.initAnonymizer()
+	s.initDeduper()
+	s.startDisplayQueue()
+	defer s.displayQueue.close()
+	rotation := newOutputRotation("flow", s.getOutputName())
+	s.initSinks(rotation.getName())
+	defer s.sinks.close()
 
 	flowPackets := make(chan *genericmap.Flow, 100)
 	collector, err := grpc.StartCollector(port, flowPackets)
@@ -61,83 +53,164 @@ func startFlowCollector() {
 		log.Errorf("StartCollector failed: %v", err.Error())
 		return
 	}
+	defer collector.Close()
 	log.Debug("Started collector")
-	collectorStarted = true
+	s.collectorStarted.Store(true)
+
+	// hold flows to deduplicate them, releasing the expired ones even when no flow is received
+	var dedupTick <-chan time.Time
+	if s.deduper != nil {
+		ticker := time.NewTicker(s.deduper.window / 2)
+		defer ticker.Stop()
+		dedupTick = ticker.C
+	}
+
+	// end the trigger window on time even when no flow is received
+	var triggerTick <-chan time.Time
+	if s.trigger != nil {
+		ticker := time.NewTicker(s.trigger.getTickInterval())
+		defer ticker.Stop()
+		triggerTick = ticker.C
+	}
 
-	go func() {
-		<-utils.ExitChannel()
-		log.Debug("Ending collector")
-		close(flowPackets)
-		collector.Close()
-		db.Close()
-		log.Debug("Done")
-	}()
+	// end the capture on time even when no flow is received
+	var maxTimeTick <-chan time.Time
+	if timer := s.newMaxTimeTimer(); timer != nil {
+		defer timer.Stop()
+		maxTimeTick = timer.C
+	}
 
 	log.Debug("Ready ! Waiting for flows...")
-	for fp := range flowPackets {
-		if !captureStarted {
-			log.Debugf("Received first %d flows", len(flowPackets))
+	for {
+		var records []dedupRecord
+		stopped := false
+		select {
+		case <-s.ctx.Done():
+			log.Debugf("Stop received: %v", s.getStopCause())
+			// stop receiving then write the flows already received
+			collector.Close()
+			records = s.drainFlows(flowPackets)
+			stopped = true
+		case fp := <-flowPackets:
+			if !s.captureStarted.Load() {
+				log.Debugf("Received first %d flows", len(flowPackets))
+			}
+			records, err = s.readFlow(fp.GenericMap.Value)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+		case <-dedupTick:
+			records = s.deduper.expireAt(currentTime())
+		case <-maxTimeTick:
+			// the stopped context then drains the records received meanwhile
+			s.endOnReachedLimit(s.maxTime.String())
+		case <-triggerTick:
+			// the stopped context then drains the records received meanwhile
+			s.expireTrigger()
 		}
 
-		if stopReceived {
-			log.Debug("Stop received")
+		if exit := s.writeFlows(records, rotation); exit || stopped {
 			return
 		}
-		// parse and display flow async
-		go parseGenericMapAndAppendFlow(fp.GenericMap.Value)
+	}
+}
+
+// readFlow anonymizes and decodes a received flow, returning the records ready to be written
+func (s *CaptureSession) readFlow(value []byte) ([]dedupRecord, error) {
+	s.received.Add(1)
 
-		// Write flows to sqlite DB
-		err = queryFlowDB(fp.GenericMap.Value, db)
+	// anonymize before anything is displayed or written
+	if s.anonymizer != nil {
+		var err error
+		value, _, err = s.anonymizer.anonymizeRecord(value)
 		if err != nil {
-			log.Error("Error while writing to DB:", err.Error())
+			return nil, fmt.Errorf("error while anonymizing flow: %w", err)
 		}
-		if !captureStarted {
-			log.Debug("Wrote flows to DB")
+	}
+
+	// decode once for every stage
+	if s.deduper == nil {
+		flow := config.GenericMap{}
+		if err := json.Unmarshal(value, &flow); err != nil {
+			return nil, fmt.Errorf("error while parsing json: %w", err)
 		}
+		return []dedupRecord{{value: value, flow: flow}}, nil
+	}
+	records, err := s.deduper.add(value, currentTime())
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing json: %w", err)
+	}
+	return records, nil
+}
 
-		// append new line between each record to read file easilly
-		bytes, err := f.Write(append(fp.GenericMap.Value, []byte(",\n")...))
+// drainFlows reads the flows queued when the collector stopped and releases the ones held for deduplication
+func (s *CaptureSession) drainFlows(flowPackets chan *genericmap.Flow) []dedupRecord {
+	records := []dedupRecord{}
+	for len(flowPackets) > 0 {
+		read, err := s.readFlow((<-flowPackets).GenericMap.Value)
 		if err != nil {
 			log.Error(err)
-			return
+			continue
 		}
-		if !captureStarted {
-			log.Debug("Wrote flows to json")
+		records = append(records, read...)
+	}
+	if s.deduper != nil {
+		records = append(records, s.deduper.flush()...)
+	}
+	log.Debugf("Drained %d flows", len(records))
+	return records
+}
+
+// writeFlows displays the records and writes them to sinks, returning true when the collector must exit
+func (s *CaptureSession) writeFlows(records []dedupRecord, rotation *outputRotation) bool {
+	for _, record := range records {
+		// display a copy since the table adds its own fields
+		s.displayRecord(record.flow.Copy())
+
+		// duplicates are displayed but not written in merge mode
+		if !s.isWrittenDedupRecord(record) {
+			continue
 		}
 
-		// terminate capture if max bytes reached
-		totalBytes += int64(bytes)
-		if totalBytes > maxBytes {
-			if exit := onLimitReached(); exit {
-				log.Infof("Capture reached %s, exiting now...", sizestr.ToString(maxBytes))
-				return
+		// only write flows around trigger matches when enabled
+		written := []triggerRecord{{value: record.value, flow: record.flow}}
+		if s.trigger != nil {
+			var stop bool
+			written, stop = s.trigger.process(record.value, record.flow)
+			if stop && s.endOnTriggerWindow() {
+				return true
 			}
 		}
 
-		// terminate capture if max time reached
-		now := currentTime()
-		duration := now.Sub(startupTime)
-		if duration > maxTime {
-			if exit := onLimitReached(); exit {
-				log.Infof("Capture reached %s, exiting now...", maxTime)
-				return
+		for _, w := range written {
+			bytes := s.sinks.write(w.flow)
+			if !s.captureStarted.Load() {
+				log.Debug("Wrote flows to sinks")
+			}
+			s.written.Add(1)
+
+			// continuous capture switches to new files instead of ending
+			s.addBytes(bytes)
+			if rotation.add(bytes) {
+				if err := s.rotateSinks(rotation); err != nil {
+					log.Error(err)
+					return true
+				}
+				log.Infof("Rotated flow capture to %s", rotation.getName())
 			}
 		}
+		if isRotationEnabled() {
+			s.captureStarted.Store(true)
+			continue
+		}
 
-		captureStarted = true
-	}
-}
-
-func parseGenericMapAndAppendFlow(bytes []byte) {
-	genericMap := config.GenericMap{}
-	err := json.Unmarshal(bytes, &genericMap)
-	if err != nil {
-		log.Error("Error while parsing json", err)
-		return
-	}
+		// terminate capture if max bytes or time reached
+		if s.endOnLimit() {
+			return true
+		}
 
-	if !captureStarted {
-		log.Debugf("Parsed genericMap %v", genericMap)
+		s.captureStarted.Store(true)
 	}
-	AppendFlow(genericMap)
+	return false
 }
@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/netobserv/flowlogs-pipeline/pkg/config"
 	// need to import the sqlite3 driver
@@ -37,126 +40,305 @@ func initFlowDB(filename string) *sql.DB {
 	return db
 }
 
-func queryFlowDB(fp []byte, db *sql.DB) error {
-	return insertFlowToDB(db, fp)
+// flowDBFields returns the fields stored in the flow table, as defined in config.yaml,
+// followed by the ones added by the CLI
+func flowDBFields() []*FieldConfig {
+	fields := []*FieldConfig{}
+	names := map[string]bool{}
+	for _, field := range append(slices.Clone(cfg.Fields), dedupDBFields...) {
+		if names[field.Name] {
+			continue
+		}
+		names[field.Name] = true
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// toSQLType returns the column type of a field; booleans are stored as integers, arrays and objects as json text
+func toSQLType(fieldType string) string {
+	switch fieldType {
+	case "number", "boolean":
+		return "INTEGER"
+	default:
+		return "TEXT"
+	}
+}
+
+// toSQLValue converts a flow value to a value that can be inserted in the flow table
+func toSQLValue(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	default:
+		return v, nil
+	}
 }
 
 func createFlowsDBTable(db *sql.DB) error {
-	createFlowsTableSQL := `CREATE TABLE flow (
-		"DnsErrno" INTEGER,
-		"Dscp" INTEGER,
-		"DstAddr" TEXT ,
-		"DstPort" INTEGER,
-		"Interface" TEXT,
-		"Proto" INTEGER,
-		"SrcAddr" TEXT,
-		"SrcPort" INTEGER,
-		"Bytes" INTEGER,
-		"Packets" INTEGER,
-		"PktDropLatestDropCause" TEXT,
-		"PktDropBytes" INTEGER,
-		"PktDropPackets" INTEGER,
-		"DnsId" INTEGER,
-		"DnsName" TEXT,
-		"DnsFlagsResponseCode" TEXT,
-		"DnsLatencyMs" TIMESTAMP,
-		"TimeFlowRTTNs" TIMESTAMP
-	  );` // SQL Statement for Create Table
+	fields := flowDBFields()
+	if len(fields) == 0 {
+		return fmt.Errorf("no field found in config")
+	}
+
+	cols := make([]string, len(fields))
+	for i, field := range fields {
+		cols[i] = fmt.Sprintf("%q %s", field.Name, toSQLType(field.Type))
+	}
+	createFlowsTableSQL := fmt.Sprintf("CREATE TABLE IF NOT EXISTS flow (\n\t%s\n);", strings.Join(cols, ",\n\t"))
 
 	log.Println("Create flows table...")
-	statement, err := db.Prepare(createFlowsTableSQL) // Prepare SQL Statement
+	_, err := db.Exec(createFlowsTableSQL)
 	if err != nil {
-		if err.Error() == "table flow already exists" {
-			return nil
-		}
-		log.Errorf("Error prepare table: %v", err.Error())
+		log.Errorf("Error creating table: %v", err.Error())
 		return err
 	}
-	_, err = statement.Exec() // Execute SQL Statements
+
+	// add columns missing from an existing table
+	existingCols, err := getFlowsDBColumns(db)
 	if err != nil {
-		if err.Error() == "table flow already exists" {
-			return nil
-		}
-		log.Errorf("Error creating table: %v", err.Error())
 		return err
 	}
+	for _, field := range fields {
+		if existingCols[field.Name] {
+			continue
+		}
+		log.Debugf("Adding %s column to flows table", field.Name)
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE flow ADD COLUMN %q %s", field.Name, toSQLType(field.Type)))
+		if err != nil {
+			log.Errorf("Error adding column %s: %v", field.Name, err.Error())
+			return err
+		}
+	}
 
 	log.Println("flows table created")
-	return nil
+	return createConversationsDBTable(db)
 }
 
-func insertFlowToDB(db *sql.DB, buf []byte) error {
-	flow := config.GenericMap{}
+func getFlowsDBColumns(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('flow')")
+	if err != nil {
+		return nil, fmt.Errorf("error reading flow table info: %v", err.Error())
+	}
+	defer rows.Close()
 
-	// Unmarshal the JSON string into the flow object
-	err := json.Unmarshal(buf, &flow)
+	cols := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err.Error())
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
+func insertFlowToDB(db *sql.DB, buf []byte) error {
+	fields := flowDBFields()
+	values, err := toFlowDBValues(fields, buf)
 	if err != nil {
-		return fmt.Errorf("error: %w", err)
-	}
-	// Insert message into database
-	var flowSQL string
-	switch {
-	case flow["PktDropPackets"] != 0 && flow["DnsId"] != 0:
-		flowSQL =
-			`INSERT INTO flow(DnsErrno, Dscp, DstAddr, DstPort, Interface, Proto, SrcAddr, SrcPort, Bytes, Packets, PktDropLatestDropCause, PktDropBytes, PktDropPackets, DnsId, DnsName, DnsFlagsResponseCode, DnsLatencyMs, TimeFlowRttNs) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	case flow["PktDropPackets"] != 0:
-		flowSQL =
-			`INSERT INTO flow(DnsErrno, Dscp, DstAddr, DstPort, Interface, Proto, SrcAddr, SrcPort, Bytes, Packets, PktDropLatestDropCause, PktDropBytes, PktDropPackets, TimeFlowRttNs) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	case flow["DnsId"] != 0:
-		flowSQL =
-			`INSERT INTO flow(DnsErrno, Dscp, DstAddr, DstPort, Interface, Proto, SrcAddr, SrcPort, Bytes, Packets, DnsId, DnsName, DnsFlagsResponseCode, DnsLatencyMs, TimeFlowRttNs) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	default:
-		flowSQL =
-			`INSERT INTO flow(DnsErrno, Dscp, DstAddr, DstPort, Interface, Proto, SrcAddr, SrcPort, Bytes, Packets, TimeFlowRttNs) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		return err
 	}
 
-	statement, err := db.Prepare(flowSQL) // Prepare statement.
+	statement, err := db.Prepare(flowInsertSQL(fields)) // Prepare statement.
 	// This is good to avoid SQL injections
 	if err != nil {
 		return fmt.Errorf("error preparing SQL: %v", err.Error())
 	}
+	defer statement.Close()
 
-	switch {
-	case flow["PktDropLatestDropCause"] != 0 && flow["DnsId"] != 0:
-		_, err = statement.Exec(
-			flow["DNSErrno"], flow["Dscp"], flow["DstAddr"], flow["DstPort"], flow["Interface"],
-			flow["Proto"], flow["SrcAddr"], flow["SrcPort"], flow["Bytes"], flow["Packets"],
-			flow["PktDropLatestDropCause"], flow["PktDropBytes"], flow["PktDropPackets"],
-			flow["DnsId"], flow["DnsName"], flow["DnsFlagsResponseCode"], flow["DnsLatencyMs"],
-			flow["TimeFlowRttNs"])
-	case flow["PktDropLatestDropCause"] != 0:
-		_, err = statement.Exec(
-			flow["DNSErrno"], flow["Dscp"], flow["DstAddr"], flow["DstPort"], flow["Interface"],
-			flow["Proto"], flow["SrcAddr"], flow["SrcPort"], flow["Bytes"], flow["Packets"],
-			flow["PktDropLatestDropCause"], flow["PktDropBytes"], flow["PktDropPackets"],
-			flow["TimeFlowRttNs"])
-	case flow["DnsId"] != 0:
-		_, err = statement.Exec(
-			flow["DNSErrno"], flow["Dscp"], flow["DstAddr"], flow["DstPort"], flow["Interface"],
-			flow["Proto"], flow["SrcAddr"], flow["SrcPort"], flow["Bytes"], flow["Packets"],
-			flow["DnsId"], flow["DnsName"], flow["DnsFlagsResponseCode"], flow["DnsLatencyMs"],
-			flow["TimeFlowRttNs"])
-	default:
-		_, err = statement.Exec(
-			flow["DNSErrno"], flow["Dscp"], flow["DstAddr"], flow["DstPort"], flow["Interface"],
-			flow["Proto"], flow["SrcAddr"], flow["SrcPort"], flow["Bytes"], flow["Packets"],
-			flow["TimeFlowRttNs"])
-	}
+	_, err = statement.Exec(values...)
 	if err != nil {
 		return fmt.Errorf("error inserting into database: %v", err.Error())
 	}
 	return nil
 }
 
-func QueryFlowsDB(query, fileName string) ([]string, error) {
-	db, err := sql.Open("sqlite3", fileName)
+// flowInsertSQL returns the statement inserting every configured field, missing ones being set to NULL
+func flowInsertSQL(fields []*FieldConfig) string {
+	cols := make([]string, len(fields))
+	placeholders := make([]string, len(fields))
+	for i, field := range fields {
+		cols[i] = fmt.Sprintf("%q", field.Name)
+		placeholders[i] = "?"
+	}
+	return fmt.Sprintf("INSERT INTO flow(%s) VALUES (%s)", strings.Join(cols, ", "), strings.Join(placeholders, ", "))
+}
+
+// toFlowDBValues returns the values of a json flow matching flowInsertSQL placeholders
+func toFlowDBValues(fields []*FieldConfig, buf []byte) ([]interface{}, error) {
+	flow := config.GenericMap{}
+
+	// Unmarshal the JSON string into the flow object
+	err := json.Unmarshal(buf, &flow)
 	if err != nil {
-		log.Errorf("Error opening database: %v", err.Error())
+		return nil, fmt.Errorf("error: %w", err)
+	}
+	return toFlowMapDBValues(fields, flow)
+}
+
+// toFlowMapDBValues returns the values of a flow matching flowInsertSQL placeholders
+func toFlowMapDBValues(fields []*FieldConfig, flow config.GenericMap) ([]interface{}, error) {
+	var err error
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		values[i], err = toSQLValue(flow[field.Name])
+		if err != nil {
+			return nil, fmt.Errorf("error converting %s: %w", field.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// QueryFlowsDB runs a read only query against a capture database, returning its columns and rows
+func QueryFlowsDB(query, fileName string, args ...interface{}) ([]string, [][]interface{}, error) {
+	// sqlite creates missing databases on open
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, nil, err
+	}
+
+	var cols []string
+	var rows [][]interface{}
+	err := withDecompressedDB(fileName, func(path string) error {
+		db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+		if err != nil {
+			log.Errorf("Error opening database: %v", err.Error())
+			return err
+		}
+		defer db.Close()
+
+		cols, rows, err = queryDBRows(db, query, args...)
+		return err
+	})
+	return cols, rows, err
+}
+
+// readFlowsDB loads all the flows stored in a capture database
+func readFlowsDB(fileName string) ([]config.GenericMap, error) {
+	if _, err := os.Stat(fileName); err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	return queryDB(db, query)
+	var flows []config.GenericMap
+	err := withDecompressedDB(fileName, func(path string) error {
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		rows, err := db.Query("SELECT * FROM flow")
+		if err != nil {
+			return fmt.Errorf("error querying database: %v", err.Error())
+		}
+		defer rows.Close()
+
+		flows, err = scanFlows(rows)
+		return err
+	})
+	return flows, err
+}
+
+// scanFlows converts rows to flows, using float64 for numbers, bool for boolean fields and decoding json arrays as json decoding does
+func scanFlows(rows *sql.Rows) ([]config.GenericMap, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns: %v", err.Error())
+	}
+	jsonCols := map[string]bool{}
+	boolCols := map[string]bool{}
+	for _, field := range flowDBFields() {
+		jsonCols[field.Name] = field.Type != "number" && field.Type != "string" && field.Type != "boolean"
+		boolCols[field.Name] = field.Type == "boolean"
+	}
+
+	flows := []config.GenericMap{}
+	values := make([]interface{}, len(cols))
+	pointers := make([]interface{}, len(cols))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("error scanning row: %v", err.Error())
+		}
+		flow := config.GenericMap{}
+		for i, col := range cols {
+			switch v := values[i].(type) {
+			case nil:
+				// field not set for this flow
+			case int64:
+				if boolCols[col] {
+					flow[col] = v != 0
+				} else {
+					flow[col] = float64(v)
+				}
+			case []byte:
+				flow[col] = fromSQLText(string(v), jsonCols[col])
+			case string:
+				flow[col] = fromSQLText(v, jsonCols[col])
+			default:
+				flow[col] = v
+			}
+		}
+		flows = append(flows, flow)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err.Error())
+	}
+	return flows, nil
+}
+
+func fromSQLText(v string, isJSON bool) interface{} {
+	if isJSON {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(v), &decoded); err == nil {
+			return decoded
+		}
+	}
+	return v
+}
+
+// queryDBRows runs a query and scans every column of the resulting rows
+func queryDBRows(db *sql.DB, query string, args ...interface{}) ([]string, [][]interface{}, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying database: %v", err.Error())
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading columns: %v", err.Error())
+	}
+
+	result := [][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		pointers := make([]interface{}, len(cols))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, fmt.Errorf("error scanning row: %v", err.Error())
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		result = append(result, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %v", err.Error())
+	}
+	return cols, result, nil
 }
 
 // queryDB Function to query the database
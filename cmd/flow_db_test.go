@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"database/sql"
 	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func init() {
@@ -13,6 +17,7 @@ func init() {
 }
 
 func TestInitFlowDB(t *testing.T) {
+	setup(t)
 	db := initFlowDB("test")
 	if db == nil {
 		t.Error("Expected database to initialize successfully")
@@ -50,3 +55,63 @@ func TestInitFlowDB(t *testing.T) {
 		t.Error("Expected error for closed DB")
 	}
 }
+
+func TestFlowDBSchema(t *testing.T) {
+	setup(t)
+	db := initFlowDB("schema")
+	assert.NotNil(t, db)
+	defer os.RemoveAll("./output")
+
+	// every configured field is a column
+	cols, err := getFlowsDBColumns(db)
+	assert.Nil(t, err)
+	for _, field := range cfg.Fields {
+		assert.True(t, cols[field.Name], field.Name)
+	}
+
+	// enrichment and array fields are stored
+	err = insertFlowToDB(db, []byte(sampleFlow))
+	assert.Nil(t, err)
+	q, err := queryDB(db, "SELECT SrcK8S_Namespace || ' ' || DstK8S_Zone || ' ' || Interfaces FROM flow")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`first-namespace us-west-1a ["f18b970c2ce8fdd"]`}, q)
+
+	// booleans are read back as booleans
+	err = insertFlowToDB(db, []byte(`{"Bytes":32,"Duplicate":true}`))
+	assert.Nil(t, err)
+	db.Close()
+
+	flows, err := readFlowsDB("./output/flow/schema.db")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(flows))
+	assert.Equal(t, false, flows[0]["Duplicate"])
+	assert.Equal(t, true, flows[1]["Duplicate"])
+	assert.Equal(t, "my-deployment", flows[0]["SrcK8S_OwnerName"])
+	assert.Equal(t, []interface{}{"f18b970c2ce8fdd"}, flows[0]["Interfaces"])
+	assert.Equal(t, []interface{}{float64(1)}, flows[0]["IfDirections"])
+	assert.Equal(t, float64(5678), flows[0]["DstPort"])
+	assert.NotContains(t, flows[0], "XlatSrcAddr")
+}
+
+func TestFlowDBSchemaMigration(t *testing.T) {
+	setup(t)
+
+	// existing table missing most of the columns
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migration.db"))
+	assert.Nil(t, err)
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE flow ("Bytes" INTEGER)`)
+	assert.Nil(t, err)
+
+	err = createFlowsDBTable(db)
+	assert.Nil(t, err)
+	cols, err := getFlowsDBColumns(db)
+	assert.Nil(t, err)
+	assert.Equal(t, len(flowDBFields()), len(cols))
+
+	err = insertFlowToDB(db, []byte(`{"Bytes":32,"SrcK8S_Name":"src-pod"}`))
+	assert.Nil(t, err)
+	q, err := queryDB(db, "SELECT SrcK8S_Name FROM flow WHERE Bytes = 32")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"src-pod"}, q)
+}
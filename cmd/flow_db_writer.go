@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	defaultDBBatchSize     = 500
+	defaultDBFlushInterval = time.Second
+)
+
+var (
+	dbBatchSize     = defaultDBBatchSize
+	dbFlushInterval = defaultDBFlushInterval
+)
+
+// flowDBWriter inserts flows in batched transactions from a dedicated goroutine
+// so the collector loop never waits for the database, dropping flows when it can't keep up,
+// and updates their conversations in the same transactions
+type flowDBWriter struct {
+	db                    *sql.DB
+	fields                []*FieldConfig
+	statement             *sql.Stmt
+	conversationStatement *sql.Stmt
+	conversations         *conversationTracker
+	batchSize             int
+	flushInterval         time.Duration
+
+	input   chan config.GenericMap
+	done    chan struct{}
+	backlog atomic.Int64
+	dropped atomic.Int64
+	// bytes of the values inserted, counted once committed
+	inserted atomic.Int64
+	closed   bool
+	mutex    sync.RWMutex
+}
+
+func newFlowDBWriter(db *sql.DB, batchSize int, flushInterval time.Duration) (*flowDBWriter, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultDBFlushInterval
+	}
+	fields := flowDBFields()
+	statement, err := db.Prepare(flowInsertSQL(fields))
+	if err != nil {
+		return nil, fmt.Errorf("error preparing SQL: %v", err.Error())
+	}
+	conversationStatement, err := db.Prepare(conversationUpsertSQL())
+	if err != nil {
+		statement.Close()
+		return nil, fmt.Errorf("error preparing SQL: %v", err.Error())
+	}
+	return &flowDBWriter{
+		db:                    db,
+		fields:                fields,
+		statement:             statement,
+		conversationStatement: conversationStatement,
+		conversations:         newConversationTracker(),
+		batchSize:             batchSize,
+		flushInterval:         flushInterval,
+		// allow a few batches to queue while one is committing
+		input: make(chan config.GenericMap, 4*batchSize),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// start runs the writer until close is called
+func (w *flowDBWriter) start() {
+	go w.run()
+}
+
+// writeFlow queues a flow for insertion, waiting when the queue is full;
+// the flow must not be modified afterwards
+func (w *flowDBWriter) writeFlow(flow config.GenericMap) error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		return fmt.Errorf("database writer is closed")
+	}
+	w.backlog.Add(1)
+	w.input <- flow
+	return nil
+}
+
+// pushFlow queues a flow for insertion without blocking, dropping it when the queue is full;
+// the flow must not be modified afterwards
+func (w *flowDBWriter) pushFlow(flow config.GenericMap) error {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		return fmt.Errorf("database writer is closed")
+	}
+	w.backlog.Add(1)
+	select {
+	case w.input <- flow:
+	default:
+		w.backlog.Add(-1)
+		w.dropped.Add(1)
+	}
+	return nil
+}
+
+// getBacklog returns the number of flows waiting to be written
+func (w *flowDBWriter) getBacklog() int64 {
+	return w.backlog.Load()
+}
+
+func (w *flowDBWriter) getDropped() int64 {
+	return w.dropped.Load()
+}
+
+// getStatusText shows flows waiting to be written and dropped flows, if any
+func (w *flowDBWriter) getStatusText() string {
+	text := ""
+	if backlog := w.getBacklog(); backlog > 0 {
+		text += fmt.Sprintf(" DB backlog: %d", backlog)
+	}
+	if dropped := w.getDropped(); dropped > 0 {
+		text += fmt.Sprintf(" DB dropped: %d", dropped)
+	}
+	return text
+}
+
+// close flushes pending flows and waits for the writer to end
+func (w *flowDBWriter) close() {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		<-w.done
+		return
+	}
+	w.closed = true
+	close(w.input)
+	w.mutex.Unlock()
+
+	<-w.done
+	w.statement.Close()
+	w.conversationStatement.Close()
+	log.Debug("Database writer closed")
+}
+
+func (w *flowDBWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]config.GenericMap, 0, w.batchSize)
+	for {
+		select {
+		case flow, ok := <-w.input:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, flow)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush inserts the batch in a single transaction
+func (w *flowDBWriter) flush(batch []config.GenericMap) {
+	if len(batch) == 0 {
+		return
+	}
+	defer w.backlog.Add(-int64(len(batch)))
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		log.Errorf("Error starting transaction: %v", err.Error())
+		return
+	}
+	statement := tx.Stmt(w.statement)
+	inserted := int64(0)
+	for _, flow := range batch {
+		values, err := toFlowMapDBValues(w.fields, flow)
+		if err != nil {
+			log.Errorf("Error while parsing flow for DB: %v", err.Error())
+			continue
+		}
+		if _, err = statement.Exec(values...); err != nil {
+			log.Errorf("Error inserting into database: %v", err.Error())
+		} else {
+			inserted += getDBValuesSize(values)
+		}
+		w.conversations.add(flow)
+	}
+
+	// replace updated conversations by their new totals
+	conversationStatement := tx.Stmt(w.conversationStatement)
+	for _, row := range w.conversations.popUpdated() {
+		if _, err = conversationStatement.Exec(toConversationDBValues(row)...); err != nil {
+			log.Errorf("Error updating conversation: %v", err.Error())
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		log.Errorf("Error committing %d flows: %v", len(batch), err.Error())
+		return
+	}
+	w.inserted.Add(inserted)
+	log.Tracef("Wrote %d flows to DB", len(batch))
+}
+
+// getDBValuesSize returns the bytes stored for values, ignoring sqlite headers and indexes
+func getDBValuesSize(values []interface{}) int64 {
+	size := int64(0)
+	for _, v := range values {
+		switch value := v.(type) {
+		case nil:
+		case string:
+			size += int64(len(value))
+		case []byte:
+			size += int64(len(value))
+		case bool:
+			size++
+		default:
+			// numbers are stored on up to 8 bytes
+			size += 8
+		}
+	}
+	return size
+}
+
+func init() {
+	registerSink("db", "sqlite flows database", []captureType{Flow}, func() Sink { return &flowDBSink{} })
+}
+
+// flowDBSink writes flows and their conversations to ./output/flow/<name>.db
+type flowDBSink struct {
+	db *sql.DB
+	// replaced on rotation while the display reads its backlog
+	writer atomic.Pointer[flowDBWriter]
+}
+
+func (s *flowDBSink) Open(name string) error {
+	db := initFlowDB(name)
+	if db == nil {
+		return fmt.Errorf("database initialization failed")
+	}
+	log.Debug("Initialized database")
+	writer, err := newFlowDBWriter(db, dbBatchSize, dbFlushInterval)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	writer.start()
+	s.db = db
+	s.writer.Store(writer)
+	return nil
+}
+
+// Write queues the flow without blocking the collector, counting it as dropped when the writer lags
+func (s *flowDBSink) Write(record config.GenericMap) error {
+	return s.writer.Load().pushFlow(record)
+}
+
+// Flush does nothing as the writer commits on its own, at least every flush interval
+func (s *flowDBSink) Flush() error {
+	return nil
+}
+
+// Size returns the bytes inserted in the database, updated as batches are committed
+func (s *flowDBSink) Size() int64 {
+	if writer := s.writer.Load(); writer != nil {
+		return writer.inserted.Load()
+	}
+	return 0
+}
+
+// getStatusText shows the flows waiting to be written or dropped, if any
+func (s *flowDBSink) getStatusText() string {
+	if writer := s.writer.Load(); writer != nil {
+		return writer.getStatusText()
+	}
+	return ""
+}
+
+// Close flushes pending flows and closes the database, compressing its file when enabled
+func (s *flowDBSink) Close() error {
+	if writer := s.writer.Swap(nil); writer != nil {
+		writer.close()
+	}
+
+	var dbFile string
+	if isCompressed() {
+		if err := s.db.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&dbFile); err != nil {
+			log.Errorf("Can't get database file: %v", err)
+		}
+	}
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	if dbFile != "" {
+		if err := compressFile(dbFile, compression); err != nil {
+			return fmt.Errorf("compressing database failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestFlowDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "writer.db"))
+	assert.Nil(t, err)
+	err = createFlowsDBTable(db)
+	assert.Nil(t, err)
+	return db
+}
+
+func countFlows(t *testing.T, db *sql.DB) string {
+	q, err := queryDB(db, "SELECT COUNT(*) FROM flow")
+	assert.Nil(t, err)
+	return q[0]
+}
+
+func TestFlowDBWriterBatchSize(t *testing.T) {
+	setup(t)
+	db := openTestFlowDB(t)
+	defer db.Close()
+
+	w, err := newFlowDBWriter(db, 10, time.Hour)
+	assert.Nil(t, err)
+	w.start()
+
+	for i := range 25 {
+		err = w.writeFlow(config.GenericMap{"Bytes": float64(i), "SrcK8S_Name": fmt.Sprintf("pod-%d", i)})
+		assert.Nil(t, err)
+	}
+
+	// two full batches are written without waiting for the interval
+	assert.Eventually(t, func() bool { return w.getBacklog() == 5 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "20", countFlows(t, db))
+
+	// remaining flows are flushed on close
+	w.close()
+	assert.Equal(t, int64(0), w.getBacklog())
+	assert.Equal(t, "25", countFlows(t, db))
+	q, err := queryDB(db, "SELECT SrcK8S_Name FROM flow WHERE Bytes = 24")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"pod-24"}, q)
+
+	// closed writer refuses new flows
+	assert.NotNil(t, w.writeFlow(config.GenericMap{"Bytes": float64(1)}))
+	assert.NotNil(t, w.pushFlow(config.GenericMap{"Bytes": float64(1)}))
+}
+
+func TestFlowDBWriterInterval(t *testing.T) {
+	setup(t)
+	db := openTestFlowDB(t)
+	defer db.Close()
+
+	w, err := newFlowDBWriter(db, 1000, 50*time.Millisecond)
+	assert.Nil(t, err)
+	w.start()
+	defer w.close()
+
+	err = w.writeFlow(config.GenericMap{"Bytes": float64(1)})
+	assert.Nil(t, err)
+	// invalid flows are skipped without failing the batch
+	err = w.writeFlow(config.GenericMap{"Bytes": float64(2), "Interfaces": []interface{}{make(chan int)}})
+	assert.Nil(t, err)
+	err = w.writeFlow(config.GenericMap{"Bytes": float64(3)})
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool { return w.getBacklog() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "2", countFlows(t, db))
+}
+
+func TestFlowDBSinkDrops(t *testing.T) {
+	setup(t)
+	db := openTestFlowDB(t)
+	defer db.Close()
+
+	// writer not started yet, queue is full after four flows
+	w, err := newFlowDBWriter(db, 1, time.Hour)
+	assert.Nil(t, err)
+	sink := &flowDBSink{db: db}
+	sink.writer.Store(w)
+	for i := range 6 {
+		assert.Nil(t, sink.Write(config.GenericMap{"Bytes": float64(i)}))
+	}
+	assert.Equal(t, int64(4), w.getBacklog())
+	assert.Equal(t, int64(2), w.getDropped())
+	assert.Equal(t, " DB backlog: 4 DB dropped: 2", sink.getStatusText())
+
+	// queued flows are written on close
+	w.start()
+	w.close()
+	assert.Equal(t, "4", countFlows(t, db))
+	assert.Equal(t, " DB dropped: 2", sink.getStatusText())
+}
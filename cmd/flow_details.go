@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/rivo/tview"
+)
+
+const (
+	commonGroup = "Common" // columns without group
+	otherGroup  = "Other"  // fields without column
+)
+
+var (
+	selectedFlow config.GenericMap
+	detailsView  *tview.TextView
+)
+
+type flowDetail struct {
+	name    string
+	value   string
+	tooltip string
+	docURL  string
+}
+
+func selectFlow(flow config.GenericMap) {
+	selectedFlow = flow.Copy()
+	if detailsView != nil {
+		detailsView.SetText(getFlowDetailsText(selectedFlow)).ScrollToBeginning()
+	}
+	pause(true)
+}
+
+func getFlowDetails() *tview.TextView {
+	if detailsView == nil {
+		detailsView = tview.NewTextView().
+			SetDynamicColors(true).
+			SetWrap(true).
+			SetWordWrap(true)
+		detailsView.SetBorder(true).SetTitle("Details")
+	}
+	detailsView.SetText(getFlowDetailsText(selectedFlow))
+	return detailsView
+}
+
+// getFlowDetailsGroups returns every field of the flow grouped like the columns, with their tooltip and documentation
+func getFlowDetailsGroups(flow config.GenericMap) ([]string, map[string][]flowDetail) {
+	groups := []string{}
+	details := map[string][]flowDetail{}
+	addDetail := func(group string, detail flowDetail) {
+		if _, ok := details[group]; !ok {
+			groups = append(groups, group)
+		}
+		details[group] = append(details[group], detail)
+	}
+
+	// fields having a column, following config order
+	shownFields := []string{}
+	for _, col := range cfg.Columns {
+		if col.Field == "" || slices.Contains(shownFields, col.Field) {
+			continue
+		}
+		if _, ok := flow[col.Field]; !ok {
+			continue
+		}
+		shownFields = append(shownFields, col.Field)
+
+		group := col.Group
+		if group == "" {
+			group = commonGroup
+		}
+		addDetail(group, flowDetail{
+			name:    col.Name,
+			value:   toColValue(flow, col.ID, 0),
+			tooltip: col.Tooltip,
+			docURL:  col.DocURL,
+		})
+	}
+
+	// remaining fields, sorted by name
+	others := []string{}
+	for k := range flow {
+		if k == "Index" || k == "Data" || slices.Contains(shownFields, k) {
+			continue
+		}
+		others = append(others, k)
+	}
+	sort.Strings(others)
+	for _, k := range others {
+		detail := flowDetail{
+			name:  k,
+			value: toValue(flow, k),
+		}
+		fieldIndex := slices.IndexFunc(cfg.Fields, func(f *FieldConfig) bool { return f.Name == k })
+		if fieldIndex != -1 {
+			detail.tooltip = cfg.Fields[fieldIndex].Description
+		}
+		addDetail(otherGroup, detail)
+	}
+
+	// keep common and other groups last
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groupOrder(groups[i]) < groupOrder(groups[j])
+	})
+	return groups, details
+}
+
+func groupOrder(group string) int {
+	switch group {
+	case commonGroup:
+		return 1
+	case otherGroup:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func getFlowDetailsText(flow config.GenericMap) string {
+	if flow == nil {
+		return "Select a row to show its details"
+	}
+
+	var sb strings.Builder
+	groups, details := getFlowDetailsGroups(flow)
+	for i, group := range groups {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("[yellow::b]%s[-::-]\n", tview.Escape(group)))
+		for _, detail := range details[group] {
+			sb.WriteString(fmt.Sprintf("[::b]%s:[::-] %s\n", tview.Escape(detail.name), tview.Escape(detail.value)))
+			if detail.tooltip != "" {
+				sb.WriteString(fmt.Sprintf("  [gray]%s[-]\n", tview.Escape(strings.ReplaceAll(detail.tooltip, "\n", " "))))
+			}
+			if detail.docURL != "" {
+				sb.WriteString(fmt.Sprintf("  [blue]%s[-]\n", tview.Escape(detail.docURL)))
+			}
+		}
+	}
+	return sb.String()
+}
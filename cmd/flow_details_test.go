@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowDetails(t *testing.T) {
+	setup(t)
+
+	var flow config.GenericMap
+	err := json.Unmarshal([]byte(sampleFlow), &flow)
+	assert.Nil(t, err)
+	flow["Index"] = 1
+	flow["Data"] = "AAEC"
+
+	groups, details := getFlowDetailsGroups(flow)
+	assert.Equal(t, "Source", groups[0])
+	assert.Equal(t, "Destination", groups[1])
+	assert.Equal(t, []string{"Common", "Other"}, groups[len(groups)-2:])
+
+	// formatted values with tooltip and doc
+	src := details["Source"]
+	assert.Equal(t, "Name", src[0].name)
+	assert.Equal(t, "src-pod", src[0].value)
+	assert.Equal(t, "The source name of the related kubernetes resource.", src[0].tooltip)
+	assert.Equal(t, "http://kubernetes.io/docs/user-guide/identifiers#names", src[0].docURL)
+	assert.Contains(t, details["L3 Layer"], flowDetail{name: "Protocol", value: "TCP", tooltip: "The value of the protocol number in the IP packet header"})
+
+	// fields without column
+	others := []string{}
+	for _, detail := range details["Other"] {
+		others = append(others, detail.name)
+	}
+	assert.Equal(t, []string{"AgentIP", "DnsFlags", "Duplicate", "Etype"}, others)
+
+	text := getFlowDetailsText(flow)
+	assert.True(t, strings.HasPrefix(text, "[yellow::b]Source[-::-]\n[::b]Name:[::-] src-pod\n"))
+	assert.NotContains(t, text, "AAEC")
+	assert.Equal(t, "Select a row to show its details", getFlowDetailsText(nil))
+}
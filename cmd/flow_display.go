@@ -3,7 +3,6 @@ package cmd
 import (
 	"encoding/base64"
 	"fmt"
-	"regexp"
 	"slices"
 	"sort"
 	"strings"
@@ -19,8 +18,9 @@ import (
 )
 
 type TableData struct {
-	cols  []string
-	flows []config.GenericMap
+	session *CaptureSession
+	cols    []string
+	flows   []config.GenericMap
 	tview.TableContentReadOnly
 }
 
@@ -31,11 +31,7 @@ const (
 )
 
 var (
-	flowIndex       = 0
-	regexes         = []string{}
-	lastFlows       = []config.GenericMap{}
-	suggestions     = []string{}
-	selectedColumns = []string{}
+	liveFilters = []string{}
 
 	extraWidth = defaultExtraWidth
 
@@ -46,35 +42,33 @@ var (
 	enrichmentTextView = tview.NewTextView()
 
 	inputField *tview.InputField
-
-	tableData = &TableData{
-		cols:  []string{},
-		flows: []config.GenericMap{},
-	}
-	selectedData = []byte{}
 )
 
 func createFlowDisplay() {
 	focus = "inputField"
-	app = tview.NewApplication().
+	session.app = tview.NewApplication().
 		SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 			//nolint:exhaustive
 			switch event.Key() {
 			case tcell.KeyCtrlC:
 				log.Info("Ctrl-C pressed, exiting program.")
-				if app != nil {
-					app.Stop()
+				session.stop(errInterrupted)
+				if session.app != nil {
+					session.app.Stop()
 				}
 			case tcell.KeyESC:
 				// reset pages when esc key pressed
 				resetSelection()
 			case tcell.KeyTab:
 				// focus on table, hex viewer if available and input field
-				if focus == "inputField" {
+				switch {
+				case focus == "inputField":
 					focus = "table"
-				} else if focus == "table" && paused && len(selectedData) > 0 {
+				case focus == "table" && session.paused.Load() && selectedFlow != nil:
+					focus = "details"
+				case (focus == "table" || focus == "details") && session.paused.Load() && len(session.selectedData) > 0:
 					focus = "hex"
-				} else {
+				default:
 					focus = "inputField"
 				}
 				updateScreen()
@@ -83,11 +77,32 @@ func createFlowDisplay() {
 				updateDisplayEnrichmentTexts()
 				updateScreen()
 			case tcell.KeyCtrlE:
-				enrichment.next()
+				session.getEnrichmentOption().next()
 				updateDisplayEnrichmentTexts()
 				updateScreen()
 			case tcell.KeyCtrlSpace:
-				pause(!paused)
+				pause(!session.paused.Load())
+			case tcell.KeyCtrlU:
+				// show or hide duplicated flows
+				session.showDuplicates.Store(!session.showDuplicates.Load())
+				updateScreen()
+				return nil
+			case tcell.KeyCtrlS:
+				// sort on selected column
+				if tableView != nil && len(session.tableData.cols) > 0 {
+					_, col := tableView.GetSelection()
+					session.sortOnColumn(col)
+				}
+			case tcell.KeyLeft, tcell.KeyRight:
+				// seek replay using Ctrl + arrows
+				if replay != nil && event.Modifiers()&tcell.ModCtrl != 0 {
+					if event.Key() == tcell.KeyLeft {
+						replay.seek(-replaySeekStep)
+					} else {
+						replay.seek(replaySeekStep)
+					}
+					return nil
+				}
 			default:
 				// nothing to do here
 			}
@@ -96,9 +111,9 @@ func createFlowDisplay() {
 		SetRoot(getPages(), true).
 		EnableMouse(true)
 
-	go hearbeat()
+	go session.hearbeat()
 
-	errAdvancedDisplay = app.Run()
+	errAdvancedDisplay = session.app.Run()
 	if errAdvancedDisplay != nil {
 		log.Errorf("Can't display advanced UI: %v", errAdvancedDisplay)
 	}
@@ -108,11 +123,19 @@ func getFlowMain() tview.Primitive {
 	mainView = tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(getFlowTop(), 4, 0, false)
 
-	mainView.AddItem(getTable(), 0, 1, focus == "table")
+	if session.paused.Load() && selectedFlow != nil {
+		// show selected flow details next to the table
+		tableRow := tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(getTable(), 0, 2, focus == "table").
+			AddItem(getFlowDetails(), 0, 1, focus == "details")
+		mainView.AddItem(tableRow, 0, 1, focus == "table" || focus == "details")
+	} else {
+		mainView.AddItem(getTable(), 0, 1, focus == "table")
+	}
 
-	if paused {
-		if len(selectedData) > 0 {
-			hex := hexview.NewHexView(selectedData)
+	if session.paused.Load() {
+		if len(session.selectedData) > 0 {
+			hex := hexview.NewHexView(session.selectedData)
 			hex.SetBorder(true).SetTitle("Payload")
 			mainView.AddItem(hex, 0, 1, focus == "hex")
 		}
@@ -138,7 +161,7 @@ func getFlowTop() tview.Primitive {
 	// display
 	displayRow := tview.NewFlex().SetDirection(tview.FlexColumn).
 		AddItem(displayTextView, 0, 1, false)
-	if len(selectedColumns) == 0 {
+	if len(session.selectedColumns) == 0 {
 		displayRow.
 			AddItem(tview.NewButton("←").SetSelectedFunc(func() {
 				display.prev()
@@ -153,7 +176,7 @@ func getFlowTop() tview.Primitive {
 	} else {
 		displayRow.
 			AddItem(tview.NewButton("⟲").SetSelectedFunc(func() {
-				selectedColumns = []string{}
+				session.selectedColumns = []string{}
 				display.current = defaultDisplayIndex
 				updateDisplayEnrichmentTexts()
 				updateScreen()
@@ -165,15 +188,15 @@ func getFlowTop() tview.Primitive {
 	// enrichment
 	enrichmentRow := tview.NewFlex().SetDirection(tview.FlexColumn).
 		AddItem(enrichmentTextView, 0, 1, false)
-	if display.getCurrentItem().name != rawDisplay && len(selectedColumns) == 0 {
+	if display.getCurrentItem().name != rawDisplay && len(session.selectedColumns) == 0 {
 		enrichmentRow.
 			AddItem(tview.NewButton("←").SetSelectedFunc(func() {
-				enrichment.prev()
+				session.getEnrichmentOption().prev()
 				updateDisplayEnrichmentTexts()
 				updateScreen()
 			}), 5, 0, false).
 			AddItem(tview.NewButton("→").SetSelectedFunc(func() {
-				enrichment.next()
+				session.getEnrichmentOption().next()
 				updateDisplayEnrichmentTexts()
 				updateScreen()
 			}), 5, 0, false)
@@ -186,7 +209,7 @@ func getFlowTop() tview.Primitive {
 	columnsRow.AddItem(cyclesCol, 0, 1, false)
 	columnsRow.AddItem(tview.NewButton(" Manage columns ").SetSelectedFunc(func() {
 		showPopup = true
-		app.SetRoot(getPages(), true)
+		session.app.SetRoot(getPages(), true)
 	}), 16, 0, false)
 	flexView.AddItem(columnsRow, 2, 0, false)
 
@@ -195,51 +218,60 @@ func getFlowTop() tview.Primitive {
 
 func getTable() *tview.Table {
 	if tableView != nil {
-		tableView.SetTitle(getTableTitle())
+		tableView.SetTitle(session.getTableTitle())
 		return tableView
 	}
 
 	tableView = tview.NewTable().
 		SetBorders(false).
 		SetSelectable(true, true).
-		SetSelectionChangedFunc(func(row, _ int) {
+		SetSelectionChangedFunc(func(row, col int) {
 			focus = "table"
+			if row == 0 {
+				// header navigation, sorting on Enter, click or Ctrl-S only
+				return
+			}
 			index := row - 1
-			if row <= 0 || index >= len(tableData.flows) {
+			if row < 0 || index >= len(session.tableData.flows) {
 				resetSelection()
 				return
 			}
-			selectedFlow := tableData.flows[index]
-			data, ok := selectedFlow["Data"]
+			flow := session.tableData.flows[index]
+			data, ok := flow["Data"]
 			if ok {
 				bytes, err := base64.StdEncoding.DecodeString(data.(string))
 				if err != nil {
 					log.Error("Error while decoding data", err)
-					return
+				} else {
+					session.selectedData = bytes
 				}
-				selectData(bytes)
 			}
+			selectFlow(flow)
 		}).
 		SetSelectedFunc(func(row, col int) {
-			if row <= 0 || inputField == nil {
+			if row == 0 {
+				session.sortOnColumn(col)
+				return
+			}
+			if row < 0 || inputField == nil {
 				return
 			}
 
-			id := tableData.cols[col]
+			id := session.tableData.cols[col]
 			index := row - 1
-			if index < len(tableData.flows) {
+			if index < len(session.tableData.flows) {
 				fieldName := toFieldName(id)
-				value, ok := tableData.flows[index][fieldName]
+				value, ok := session.tableData.flows[index][fieldName]
 				if !ok || value == nil {
 					return
 				}
 				focus = "inputField"
 				updateScreen()
-				inputField.SetText(fmt.Sprintf("%s:%v", fieldName, value))
+				inputField.SetText(toFilterText(id, value))
 			}
 		}).
-		SetContent(tableData)
-	tableView.SetBorder(true).SetTitle(getTableTitle())
+		SetContent(session.tableData)
+	tableView.SetBorder(true).SetTitle(session.getTableTitle())
 
 	return tableView
 }
@@ -247,23 +279,24 @@ func getTable() *tview.Table {
 func getFilters() *tview.Flex {
 	filtersView = tview.NewFlex().SetDirection(tview.FlexColumn)
 
-	if len(regexes) > 0 {
+	if len(liveFilters) > 0 {
 		filtersView.AddItem(tview.NewTextView().SetText("Current filters:"), 17, 0, false)
-		for _, regex := range regexes {
-			filtersView.AddItem(tview.NewButton(regex).SetSelectedFunc(func() {
-				for i, v := range regexes {
-					if v == regex {
-						regexes = slices.Delete(regexes, i, i+1)
+		for _, filter := range liveFilters {
+			filtersView.AddItem(tview.NewButton(filter).SetSelectedFunc(func() {
+				for i, v := range liveFilters {
+					if v == filter {
+						liveFilters = slices.Delete(liveFilters, i, i+1)
+						onLiveFiltersChanged()
 						updateScreen()
 						break
 					}
 				}
-			}), len(regex), 0, false)
+			}), len(filter), 0, false)
 			filtersView.AddItem(tview.NewTextView(), 1, 0, false)
 		}
 		filtersView.AddItem(tview.NewTextView().SetText("Press `Enter` key to add a new one and backspace to remove last one"), 0, 1, false)
 	} else {
-		filtersView.AddItem(tview.NewTextView().SetText("Press `Enter` key to match multiple regexes at once"), 0, 1, false)
+		filtersView.AddItem(tview.NewTextView().SetText("Press `Enter` key to add a filter such as src_namespace=\"netobserv\" and (dst_port=443 or not protocol=\"UDP\"), or a regex"), 0, 1, false)
 	}
 
 	return filtersView
@@ -274,13 +307,13 @@ func getBottom() tview.Primitive {
 
 	if inputField == nil {
 		inputField = tview.NewInputField().
-			SetLabel("Live table regexes: ").
+			SetLabel("Live table filters: ").
 			SetFieldWidth(30)
 		inputField.SetAutocompleteFunc(func(currentText string) (entries []string) {
 			if len(currentText) == 0 {
 				return
 			}
-			for _, word := range suggestions {
+			for _, word := range session.getSuggestions() {
 				if strings.HasPrefix(strings.ToLower(word), strings.ToLower(currentText)) {
 					entries = append(entries, word)
 				}
@@ -295,8 +328,9 @@ func getBottom() tview.Primitive {
 			//nolint:exhaustive
 			switch event.Key() {
 			case tcell.KeyBackspace, tcell.KeyBackspace2:
-				if len(inputField.GetText()) == 0 && len(regexes) > 0 {
-					regexes = regexes[:len(regexes)-1]
+				if len(inputField.GetText()) == 0 && len(liveFilters) > 0 {
+					liveFilters = liveFilters[:len(liveFilters)-1]
+					onLiveFiltersChanged()
 				}
 				filtersView = getFilters()
 				updateScreen()
@@ -319,7 +353,8 @@ func getBottom() tview.Primitive {
 			case tcell.KeyEnter:
 				text := inputField.GetText()
 				if len(text) > 0 {
-					regexes = append(regexes, text)
+					liveFilters = append(liveFilters, text)
+					onLiveFiltersChanged()
 					inputField.SetText("")
 				}
 				filtersView = getFilters()
@@ -338,7 +373,7 @@ func getBottom() tview.Primitive {
 func getColumnsModal() tview.Primitive {
 	availableColumns := []*ColumnConfig{}
 	for _, col := range cfg.Columns {
-		if col.Field != "" {
+		if col.Field != "" || col.Calculated != "" {
 			availableColumns = append(availableColumns, col)
 		}
 	}
@@ -353,7 +388,7 @@ func getColumnsModal() tview.Primitive {
 
 	setCell := func(i int, col *ColumnConfig) {
 		checkedStr := "[   ]"
-		if slices.Contains(selectedColumns, col.ID) {
+		if slices.Contains(session.selectedColumns, col.ID) {
 			checkedStr = "[ X ]"
 		}
 		colsTable.SetCell(i, 0, tview.NewTableCell(checkedStr))
@@ -371,16 +406,16 @@ func getColumnsModal() tview.Primitive {
 			return
 		}
 		c := availableColumns[row]
-		for i, v := range selectedColumns {
+		for i, v := range session.selectedColumns {
 			// remove id if found
 			if v == c.ID {
-				selectedColumns = append(selectedColumns[:i], selectedColumns[i+1:]...)
+				session.selectedColumns = append(session.selectedColumns[:i], session.selectedColumns[i+1:]...)
 				setCell(row, c)
 				return
 			}
 		}
 		// else add it to selection
-		selectedColumns = append(selectedColumns, c.ID)
+		session.selectedColumns = append(session.selectedColumns, c.ID)
 		setCell(row, c)
 		updateDisplayEnrichmentTexts()
 	}
@@ -395,10 +430,10 @@ func getColumnsModal() tview.Primitive {
 
 	buttons := tview.NewFlex().SetDirection(tview.FlexColumn).
 		AddItem(tview.NewButton("Restore defaults").SetSelectedFunc(func() {
-			selectedColumns = []string{}
+			session.selectedColumns = []string{}
 			for i, c := range availableColumns {
 				if c.Default {
-					selectedColumns = append(selectedColumns, c.ID)
+					session.selectedColumns = append(session.selectedColumns, c.ID)
 				}
 				setCell(i, c)
 			}
@@ -406,7 +441,7 @@ func getColumnsModal() tview.Primitive {
 		}), 0, 1, false).
 		AddItem(tview.NewTextView(), 1, 0, false).
 		AddItem(tview.NewButton("Reset").SetSelectedFunc(func() {
-			selectedColumns = []string{}
+			session.selectedColumns = []string{}
 			setTableContent()
 			updateDisplayEnrichmentTexts()
 		}), 0, 1, false).
@@ -419,83 +454,141 @@ func getColumnsModal() tview.Primitive {
 	return getModal(content, 50, 30)
 }
 
-func getcaptureText() string {
-	return fmt.Sprintf("%s Capture", capture)
+func (s *CaptureSession) getCaptureText() string {
+	if replay != nil {
+		return fmt.Sprintf("%s Replay", s.capture)
+	}
+	if attached != nil {
+		return fmt.Sprintf("%s Capture (attached)", s.capture)
+	}
+	return fmt.Sprintf("%s Capture", s.capture)
 }
 
-func getTableTitle() string {
-	if paused {
+func (s *CaptureSession) getTableTitle() string {
+	if s.paused.Load() {
 		return "Table refresh is paused. Press `ESC` to resume."
 	}
-	return "Flows"
+	if s.isTopTalkersDisplay() {
+		if s.sortColumn != "" {
+			return fmt.Sprintf("Top talkers by %s sorted by %s %s", aggregation.getCurrentItem().name, toColName(s.sortColumn, 0), s.getSortIndicator(s.sortColumn))
+		}
+		return fmt.Sprintf("Top talkers by %s", aggregation.getCurrentItem().name)
+	}
+	if s.isConversationsDisplay() {
+		if s.sortColumn != "" {
+			return fmt.Sprintf("Conversations sorted by %s %s", toColName(s.sortColumn, 0), s.getSortIndicator(s.sortColumn))
+		}
+		return "Conversations"
+	}
+	title := "Flows"
+	if s.showDuplicates.Load() {
+		title = "Flows including duplicates"
+	}
+	if s.sortColumn != "" {
+		return fmt.Sprintf("%s sorted by %s %s", title, toColName(s.sortColumn, 0), s.getSortIndicator(s.sortColumn))
+	}
+	return title
 }
 
 func getLogLevelText() string {
 	return fmt.Sprintf("Log level: %s", logLevel)
 }
 
-func getEnrichmentText() string {
-	if len(selectedColumns) > 0 {
+func (s *CaptureSession) getEnrichmentText() string {
+	if len(s.selectedColumns) > 0 {
 		return ""
 	} else if display.getCurrentItem().name == rawDisplay {
 		return "Enrichment: n/a\n"
+	} else if s.isTopTalkersDisplay() {
+		return fmt.Sprintf("Aggregate by: %s\n", aggregation.getCurrentItem().name)
 	}
 	return fmt.Sprintf("Enrichment: %s\n", enrichment.getCurrentItem().name)
 }
 
-func getDisplayText() string {
-	if len(selectedColumns) > 0 {
+func (s *CaptureSession) getDisplayText() string {
+	if len(s.selectedColumns) > 0 {
 		return "Custom columns"
 	}
 	return fmt.Sprintf("Display: %s\n", display.getCurrentItem().name)
 }
 
-func getFlowShowCountText() string {
-	return fmt.Sprintf("Showing last: %d\n", showCount)
+func (s *CaptureSession) getFlowShowCountText() string {
+	return fmt.Sprintf("Showing last: %d\n", s.showCount)
 }
 
-func AppendFlow(genericMap config.GenericMap) {
-	if paused {
+// appendFlow adds a flow to the session aggregates, conversations and displayed flows
+func (s *CaptureSession) appendFlow(genericMap config.GenericMap) {
+	// aggregate even when paused to cover the whole capture
+	s.aggregates.add(genericMap)
+	s.conversations.add(genericMap)
+
+	if s.paused.Load() {
 		return
 	}
 
 	// lock since we are updating lastFlows concurrently
-	mutex.Lock()
+	s.mutex.Lock()
+	s.insertFlow(genericMap)
+	s.mutex.Unlock()
+}
 
+// resetFlows replaces the flows kept in memory and the displayed ones
+func (s *CaptureSession) resetFlows(flows []config.GenericMap) {
+	s.mutex.Lock()
+	s.lastFlows = []config.GenericMap{}
+	s.tableData.flows = []config.GenericMap{}
+	for _, flow := range flows {
+		s.insertFlow(flow.Copy())
+	}
+	s.mutex.Unlock()
+}
+
+func (s *CaptureSession) insertFlow(genericMap config.GenericMap) {
 	// add new flow to the array
-	genericMap["Index"] = flowIndex
-	flowIndex++
-	lastFlows = append(lastFlows, genericMap)
-
-	// sort flows according to time
-	sort.Slice(lastFlows, func(i, j int) bool {
-		if capture == Flow {
-			return toFloat64(lastFlows[i], "TimeFlowEndMs") < toFloat64(lastFlows[j], "TimeFlowEndMs")
-		}
-		return toFloat64(lastFlows[i], "Time") < toFloat64(lastFlows[j], "Time")
+	genericMap["Index"] = s.flowIndex
+	s.flowIndex++
+
+	// insert flow according to time instead of sorting them all again
+	timeField := "Time"
+	if s.capture == Flow {
+		timeField = "TimeFlowEndMs"
+	}
+	t := toFloat64(genericMap, timeField)
+	i := sort.Search(len(s.lastFlows), func(i int) bool {
+		return toFloat64(s.lastFlows[i], timeField) > t
 	})
+	s.lastFlows = slices.Insert(s.lastFlows, i, genericMap)
 
 	// limit flows kept in memory
-	if len(lastFlows) > keepCount {
-		lastFlows = lastFlows[len(lastFlows)-keepCount:]
+	if len(s.lastFlows) > keepCount {
+		s.lastFlows = s.lastFlows[len(s.lastFlows)-keepCount:]
 	}
+}
 
-	mutex.Unlock()
+// getLastFlows returns a copy of the flows kept in memory since they may change during the render
+func (s *CaptureSession) getLastFlows() []config.GenericMap {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.lastFlows)
 }
 
 func updateDisplayEnrichmentTexts() {
-	displayTextView.SetText(getDisplayText())
-	enrichmentTextView.SetText(getEnrichmentText())
+	displayTextView.SetText(session.getDisplayText())
+	enrichmentTextView.SetText(session.getEnrichmentText())
 }
 
-func getCols() []string {
+func (s *CaptureSession) getCols() []string {
 	cols := []string{}
-	if len(selectedColumns) > 0 {
-		cols = selectedColumns
+	if len(s.selectedColumns) > 0 {
+		cols = s.selectedColumns
 	} else if display.getCurrentItem().name == rawDisplay {
 		cols = append(cols,
 			rawDisplay,
 		)
+	} else if s.isTopTalkersDisplay() {
+		cols = getAggregateCols()
+	} else if s.isConversationsDisplay() {
+		cols = getConversationCols()
 	} else {
 		// main field, always show the end time
 		cols = append(cols,
@@ -521,6 +614,9 @@ func getCols() []string {
 			"Interfaces",
 			"IfDirections",
 		)
+		if s.showDuplicates.Load() {
+			cols = append(cols, "Duplicate")
+		}
 
 		// standard / feature fields
 		if display.getCurrentItem().name != standardDisplay {
@@ -543,10 +639,14 @@ func getCols() []string {
 	return cols
 }
 
-func getFlows() []config.GenericMap {
-	// lastFlows may change during the render so we make a copy first
-	lfCopy := make([]config.GenericMap, len(lastFlows))
-	copy(lfCopy, lastFlows)
+func (s *CaptureSession) getFlows() []config.GenericMap {
+	if s.isTopTalkersDisplay() {
+		return s.getAggregatedFlows()
+	} else if s.isConversationsDisplay() {
+		return s.getConversationFlows()
+	}
+
+	lfCopy := s.getLastFlows()
 
 	// keep already displayed flows that may been removed in lastFlows
 	indexes := []int{}
@@ -554,7 +654,7 @@ func getFlows() []config.GenericMap {
 		indexes = append(indexes, lf["Index"].(int))
 	}
 	missingFlows := []config.GenericMap{}
-	for _, flow := range tableData.flows {
+	for _, flow := range s.tableData.flows {
 		if !slices.Contains(indexes, flow["Index"].(int)) {
 			missingFlows = append(missingFlows, flow)
 		}
@@ -562,47 +662,74 @@ func getFlows() []config.GenericMap {
 	// prepend missing flows to keep the order
 	lfCopy = append(missingFlows, lfCopy...)
 
-	// apply regexes to filter flows
-	flows := []config.GenericMap{}
-	if len(regexes) > 0 {
-		// regexes may change during the render so we make a copy first
-		rCopy := make([]string, len(regexes))
-		copy(rCopy, regexes)
-
-		for _, flow := range lfCopy {
-			match := true
-			for i := range rCopy {
-				ok, _ := regexp.MatchString(rCopy[i], fmt.Sprintf("%v", flow))
-				match = match && ok
-				if !match {
-					break
-				}
-			}
-			if match {
-				flows = append(flows, flow)
+	// apply filters to flows, hiding duplicates unless requested
+	if !s.showDuplicates.Load() {
+		lfCopy = withoutDuplicates(lfCopy)
+	}
+	flows := filterFlows(lfCopy)
+
+	// limit filtered flows to display size, keeping the top ones when sorted
+	if s.sortColumn != "" {
+		s.sortFlows(flows)
+		if len(flows) > s.showCount {
+			flows = flows[:s.showCount]
+		}
+	} else if len(flows) > s.showCount {
+		flows = flows[len(flows)-s.showCount:]
+	}
+	return flows
+}
+
+// filterFlows returns the flows matching every live filter
+func filterFlows(flows []config.GenericMap) []config.GenericMap {
+	if len(liveFilters) == 0 {
+		return flows
+	}
+
+	// filters may change during the render so we compile a copy first
+	predicates := make([]flowPredicate, len(liveFilters))
+	for i, filter := range liveFilters {
+		predicates[i] = getFilterPredicate(filter)
+	}
+
+	filtered := []config.GenericMap{}
+	for _, flow := range flows {
+		match := true
+		for _, predicate := range predicates {
+			match = predicate(flow)
+			if !match {
+				break
 			}
 		}
-	} else {
-		flows = lfCopy
+		if match {
+			filtered = append(filtered, flow)
+		}
 	}
+	return filtered
+}
 
-	// limit filtered flows to display size
-	if len(flows) > showCount {
-		flows = flows[len(flows)-showCount:]
+// sortOnColumn toggles sort on the column at index and refreshes the table, even when paused
+func (s *CaptureSession) sortOnColumn(col int) {
+	if col < 0 || col >= len(s.tableData.cols) {
+		return
+	}
+	s.toggleSort(s.tableData.cols[col])
+	s.updateTableAndSuggestions()
+	if tableView != nil {
+		tableView.SetTitle(s.getTableTitle())
 	}
-	return flows
 }
 
-func getTableRows() []string {
+func (s *CaptureSession) getTableRows() []string {
 	arr := []string{}
-	if len(tableData.cols) == 0 || len(tableData.flows) == 0 {
+	if len(s.tableData.cols) == 0 || len(s.tableData.flows) == 0 {
 		return arr
 	}
 
-	for i := range len(tableData.flows) + 1 {
+	for i := range len(s.tableData.flows) + 1 {
 		str := ""
-		for j := range tableData.cols {
-			str += tableData.GetCell(i, j).Text
+		for j := range s.tableData.cols {
+			str += s.tableData.GetCell(i, j).Text
 		}
 		arr = append(arr, str)
 	}
@@ -610,14 +737,17 @@ func getTableRows() []string {
 	return arr
 }
 
-func updateTableAndSuggestions() {
+func (s *CaptureSession) updateTableAndSuggestions() {
 	// update tableData
-	tableData.cols = getCols()
-	tableData.flows = getFlows()
+	s.tableData.cols = s.getCols()
+	s.tableData.flows = s.getFlows()
 
-	// update suggestions
-	suggestions = []string{}
-	for _, flow := range tableData.flows {
+	// update suggestions, starting with filter keys
+	suggestions := []string{}
+	for _, filter := range cfg.Filters {
+		suggestions = append(suggestions, filter.ID)
+	}
+	for _, flow := range s.tableData.flows {
 		for k, v := range flow {
 			if !slices.Contains(suggestions, k) {
 				suggestions = append(suggestions, k)
@@ -629,15 +759,21 @@ func updateTableAndSuggestions() {
 			}
 		}
 	}
+	s.mutex.Lock()
+	s.suggestions = suggestions
+	s.mutex.Unlock()
 }
 
-func selectData(data []byte) {
-	selectedData = data
-	pause(true)
+// getSuggestions returns the filter suggestions of the last table update
+func (s *CaptureSession) getSuggestions() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.suggestions
 }
 
 func resetSelection() {
-	selectedData = []byte{}
+	session.selectedData = []byte{}
+	selectedFlow = nil
 	pause(false)
 }
 
@@ -665,11 +801,20 @@ func (d *TableData) GetCell(row, col int) *tview.TableCell {
 		bgColor = tcell.ColorWhite
 	}
 	if row == 0 {
-		return tview.NewTableCell(toColName(id, toColWidth(id))).
+		name := toColName(id, toColWidth(id))
+		if indicator := d.session.getSortIndicator(id); indicator != "" {
+			name = toColName(id, toColWidth(id)-2) + " " + indicator
+		}
+		return tview.NewTableCell(name).
 			SetTextColor(color).
 			SetBackgroundColor(bgColor).
 			SetAlign(tview.AlignLeft).
-			SetMaxWidth(width)
+			SetMaxWidth(width).
+			SetClickedFunc(func() bool {
+				// header click sorts without selecting
+				d.session.sortOnColumn(col)
+				return true
+			})
 	}
 	index := row - 1
 	if index < len(d.flows) {
@@ -9,13 +9,13 @@ import (
 
 func TestFlowDisplayRefreshDelay(t *testing.T) {
 	setup(t)
-	assert.Empty(t, getTableRows())
+	assert.Empty(t, session.getTableRows())
 
-	parseGenericMapAndAppendFlow([]byte(`{"TimeFlowEndMs": 1709741962017}`))
-	assert.Empty(t, getTableRows())
+	session.appendFlow(parseTestFlow(t, []byte(`{"TimeFlowEndMs": 1709741962017}`)))
+	assert.Empty(t, session.getTableRows())
 
-	updateTableAndSuggestions()
-	rows := getTableRows()
+	session.updateTableAndSuggestions()
+	rows := session.getTableRows()
 	assert.Equal(t, 2, len(rows))
 	assert.Equal(t, "End Time            Src Kind       Dst Kind       Src Name            Dst Name            Src Namespace       Dst Namespace       Interfaces     Interface Dirs Node Dir       L3 Protocol    L3 DSCP        Bytes     Packets   ", rows[0])
 	assert.Equal(t, "17:19:22.017000     n/a            n/a            n/a                 n/a                 n/a                 n/a                 n/a            n/a            n/a            n/a            n/a            n/a       n/a       ", rows[1])
@@ -24,12 +24,12 @@ func TestFlowDisplayRefreshDelay(t *testing.T) {
 func TestFlowDisplayDefaultDisplay(t *testing.T) {
 	setup(t)
 
-	parseGenericMapAndAppendFlow([]byte(sampleFlow))
+	session.appendFlow(parseTestFlow(t, []byte(sampleFlow)))
 	tickTimeAndAddBytes()
-	updateTableAndSuggestions()
+	session.updateTableAndSuggestions()
 
 	// get table output as string
-	rows := getTableRows()
+	rows := session.getTableRows()
 	assert.Equal(t, 2, len(rows))
 	assert.Equal(t, "End Time            Src Kind       Dst Kind       Src Name            Dst Name            Src Namespace       Dst Namespace       Interfaces     Interface Dirs Node Dir       L3 Protocol    L3 DSCP        Bytes     Packets   ", rows[0])
 	assert.Equal(t, "17:25:28.703000     Pod            Pod            src-pod             dst-pod             first-namespace     second-namespace    f18b970c2ce8fddEgress         Ingress        TCP            Standard       456B      5         ", rows[1])
@@ -53,22 +53,22 @@ func TestFlowDisplayMultipleFlows(t *testing.T) {
 		bytes += 1000
 
 		// add flow to table
-		parseGenericMapAndAppendFlow([]byte(fmt.Sprintf(`{
+		session.appendFlow(parseTestFlow(t, []byte(fmt.Sprintf(`{
 			"AgentIP":"10.0.1.1",
 			"Bytes":%d,
 			"DstAddr":"10.0.0.6",
 			"Packets":1,
 			"SrcAddr":"10.0.0.5",
 			"TimeFlowEndMs":%d
-		}`, bytes, flowTime)))
+		}`, bytes, flowTime))))
 
 		tickTimeAndAddBytes()
 	}
 
-	updateTableAndSuggestions()
+	session.updateTableAndSuggestions()
 
 	// get table output as string
-	rows := getTableRows()
+	rows := session.getTableRows()
 	// table must display only 31 rows (30 flows (max displayed limit) + 1 columns row)
 	assert.Equal(t, 31, len(rows))
 	// table columns
@@ -103,12 +103,12 @@ func TestFlowDisplayAdvancedDisplay(t *testing.T) {
 
 		// clear previous data and buffer
 		setup(t)
-		parseGenericMapAndAppendFlow([]byte(sampleFlow))
+		session.appendFlow(parseTestFlow(t, []byte(sampleFlow)))
 		tickTimeAndAddBytes()
-		updateTableAndSuggestions()
+		session.updateTableAndSuggestions()
 
 		// get table output per rows
-		return getTableRows()
+		return session.getTableRows()
 	}
 
 	// set display without enrichment
@@ -0,0 +1,418 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+// flowPredicate returns true when the flow matches a filter
+type flowPredicate func(config.GenericMap) bool
+
+type filterTokenKind int
+
+const (
+	tokenEOF filterTokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	value string
+}
+
+// filters are compiled on each key stroke so the cache is cleared once full
+const maxCompiledFilters = 100
+
+var (
+	filterOperators = []string{"=~", "!~", "!=", ">=", "<=", "=", ">", "<"}
+
+	compiledFilters      = map[string]flowPredicate{}
+	compiledFiltersMutex = sync.Mutex{}
+)
+
+// getFilterPredicate compiles a filter once, falling back on a regex matching the whole flow
+// when the text is not a valid query
+func getFilterPredicate(text string) flowPredicate {
+	compiledFiltersMutex.Lock()
+	defer compiledFiltersMutex.Unlock()
+
+	if predicate, ok := compiledFilters[text]; ok {
+		return predicate
+	}
+
+	predicate, err := parseFilter(text)
+	if err != nil {
+		log.Debugf("Using %s as regex: %v", text, err)
+		predicate = regexPredicate(text)
+	}
+	if len(compiledFilters) >= maxCompiledFilters {
+		clear(compiledFilters)
+	}
+	compiledFilters[text] = predicate
+	return predicate
+}
+
+func regexPredicate(text string) flowPredicate {
+	r, err := regexp.Compile(text)
+	if err != nil {
+		log.Debugf("Invalid regex %s: %v", text, err)
+		return func(config.GenericMap) bool { return false }
+	}
+	return func(flow config.GenericMap) bool {
+		return r.MatchString(fmt.Sprintf("%v", flow))
+	}
+}
+
+// parseFilter compiles a query such as `src_namespace="netobserv" and (dst_port=443 or not proto="UDP")`
+func parseFilter(text string) (flowPredicate, error) {
+	tokens, err := lexFilter(text)
+	if err != nil {
+		return nil, err
+	}
+	p := filterParser{tokens: tokens}
+	predicate, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s", p.peek().value)
+	}
+	return predicate, nil
+}
+
+func isFilterWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()"=!<>`, r)
+}
+
+func lexFilter(text string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: tokenOpen, value: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: tokenClose, value: ")"})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("missing closing quote")
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, value: sb.String()})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := ""
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("invalid operator at %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: tokenOperator, value: op})
+			i += len(op)
+		default:
+			start := i
+			for i < len(runes) && isFilterWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, value: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+func (p *filterParser) peek() filterToken {
+	if p.position >= len(p.tokens) {
+		return filterToken{kind: tokenEOF, value: "end of filter"}
+	}
+	return p.tokens[p.position]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.peek()
+	p.position++
+	return t
+}
+
+func (p *filterParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.EqualFold(t.value, keyword)
+}
+
+func (p *filterParser) parseOr() (flowPredicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(flow config.GenericMap) bool { return l(flow) || right(flow) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (flowPredicate, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(flow config.GenericMap) bool { return l(flow) && right(flow) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (flowPredicate, error) {
+	if p.isKeyword("not") {
+		p.next()
+		predicate, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(flow config.GenericMap) bool { return !predicate(flow) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (flowPredicate, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenOpen:
+		predicate, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return predicate, nil
+	case tokenWord:
+		fn := strings.ToLower(t.value)
+		if (fn == "with" || fn == "without") && p.peek().kind == tokenOpen {
+			return p.parseFunction(fn)
+		}
+		return p.parseComparison(t.value)
+	default:
+		return nil, fmt.Errorf("unexpected %s", t.value)
+	}
+}
+
+// parseFunction parses with(key) and without(key) checking field presence
+func (p *filterParser) parseFunction(fn string) (flowPredicate, error) {
+	p.next()
+	key := p.next()
+	if key.kind != tokenWord {
+		return nil, fmt.Errorf("expected key in %s()", fn)
+	}
+	if p.next().kind != tokenClose {
+		return nil, fmt.Errorf("missing closing parenthesis after %s(%s", fn, key.value)
+	}
+	fields, err := resolveFilterKey(key.value)
+	if err != nil {
+		return nil, err
+	}
+	with := func(flow config.GenericMap) bool {
+		for _, field := range fields {
+			if v, ok := flow[field]; ok && v != nil {
+				return true
+			}
+		}
+		return false
+	}
+	if fn == "without" {
+		return func(flow config.GenericMap) bool { return !with(flow) }, nil
+	}
+	return with, nil
+}
+
+func (p *filterParser) parseComparison(key string) (flowPredicate, error) {
+	fields, err := resolveFilterKey(key)
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if op.kind != tokenOperator {
+		return nil, fmt.Errorf("expected operator after %s", key)
+	}
+	value := p.next()
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("expected value after %s%s", key, op.value)
+	}
+
+	var match func(candidates []string, numbers []float64) bool
+	switch op.value {
+	case "=", "!=":
+		match = func(candidates []string, _ []float64) bool {
+			return slices.Contains(candidates, value.value)
+		}
+	case "=~", "!~":
+		r, err := regexp.Compile(value.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %s: %w", value.value, err)
+		}
+		match = func(candidates []string, _ []float64) bool {
+			return slices.ContainsFunc(candidates, r.MatchString)
+		}
+	default:
+		expected, err := strconv.ParseFloat(value.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected number after %s%s", key, op.value)
+		}
+		compare := map[string]func(float64) bool{
+			">":  func(n float64) bool { return n > expected },
+			"<":  func(n float64) bool { return n < expected },
+			">=": func(n float64) bool { return n >= expected },
+			"<=": func(n float64) bool { return n <= expected },
+		}[op.value]
+		match = func(_ []string, numbers []float64) bool {
+			return slices.ContainsFunc(numbers, compare)
+		}
+	}
+
+	predicate := func(flow config.GenericMap) bool {
+		for _, field := range fields {
+			candidates, numbers := getFilterCandidates(flow, field)
+			if match(candidates, numbers) {
+				return true
+			}
+		}
+		return false
+	}
+	if strings.HasPrefix(op.value, "!") {
+		return func(flow config.GenericMap) bool { return !predicate(flow) }, nil
+	}
+	return predicate, nil
+}
+
+// resolveFilterKey returns the fields matching a filter id such as src_namespace, a filter id
+// without its src_ / dst_ prefix such as namespace, or a field name such as SrcK8S_Namespace
+func resolveFilterKey(key string) ([]string, error) {
+	fields := []string{}
+	addField := func(field string) {
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	for _, col := range cfg.Columns {
+		if col.Field == "" || col.Filter == "" {
+			continue
+		}
+		if col.Filter == key || col.Filter == "src_"+key || col.Filter == "dst_"+key {
+			addField(col.Field)
+		}
+	}
+	if len(fields) == 0 {
+		if slices.ContainsFunc(cfg.Fields, func(f *FieldConfig) bool { return f.Name == key }) {
+			addField(key)
+		} else if slices.ContainsFunc(cfg.Columns, func(c *ColumnConfig) bool { return c.Field == key }) {
+			addField(key)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("unknown filter key %s", key)
+	}
+	return fields, nil
+}
+
+// getFilterCandidates returns the raw and formatted values of a field to compare with,
+// including each item of arrays
+func getFilterCandidates(flow config.GenericMap, field string) ([]string, []float64) {
+	v, ok := flow[field]
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	candidates := []string{}
+	numbers := []float64{}
+	addValue := func(value interface{}) {
+		switch n := value.(type) {
+		case float64:
+			numbers = append(numbers, n)
+		case int:
+			numbers = append(numbers, float64(n))
+		}
+		candidates = append(candidates, fmt.Sprintf("%v", value))
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		for i := range rv.Len() {
+			addValue(rv.Index(i).Interface())
+		}
+	} else {
+		addValue(v)
+	}
+
+	// also match displayed values such as TCP for protocol 6
+	if id := toColID(field); id != "" {
+		for _, formatted := range strings.Split(toColValue(flow, id, 0), ",") {
+			if !slices.Contains(candidates, formatted) {
+				candidates = append(candidates, formatted)
+			}
+		}
+	}
+	return candidates, numbers
+}
+
+// toFilterText returns a query matching the field value, used when selecting a table cell
+func toFilterText(id string, value interface{}) string {
+	key := toFieldName(id)
+	colIndex := slices.IndexFunc(cfg.Columns, func(c *ColumnConfig) bool { return c.ID == id })
+	if colIndex != -1 && cfg.Columns[colIndex].Filter != "" {
+		if fields, err := resolveFilterKey(cfg.Columns[colIndex].Filter); err == nil && len(fields) == 1 {
+			key = cfg.Columns[colIndex].Filter
+		}
+	}
+
+	if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice && rv.Len() > 0 {
+		value = rv.Index(0).Interface()
+	}
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%s=%s", key, strconv.Quote(v))
+	default:
+		return fmt.Sprintf("%s=%v", key, v)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	setup(t)
+
+	var flow config.GenericMap
+	err := json.Unmarshal([]byte(sampleFlow), &flow)
+	assert.Nil(t, err)
+
+	for filter, expected := range map[string]bool{
+		// filter ids, bare ids and field names
+		`src_namespace="first-namespace"`:    true,
+		`dst_namespace="first-namespace"`:    false,
+		`namespace="second-namespace"`:       true,
+		`SrcK8S_Namespace="first-namespace"`: true,
+		`src_kind="Deployment"`:              true,
+		// comparisons
+		`dst_port=5678`:                    true,
+		`dst_port!=5678`:                   false,
+		`src_port>1000 and src_port<=1234`: true,
+		`src_port>=1235`:                   false,
+		`dns_latency<1`:                    false,
+		// regexes
+		`src_name=~"^src-"`: true,
+		`dst_name!~"pod$"`:  false,
+		// formatted values and arrays
+		`protocol="TCP"`:               true,
+		`protocol=6`:                   true,
+		`ifdirections="Egress"`:        true,
+		`interfaces="f18b970c2ce8fdd"`: true,
+		// boolean operators
+		`not protocol="UDP"`:                                              true,
+		`protocol="UDP" or dst_port=5678`:                                 true,
+		`protocol="UDP" or (dst_port=5678 and not src_zone="us-east-1d")`: false,
+		`protocol="TCP" AND NOT (src_port=1 OR src_port=2)`:               true,
+		// presence
+		`with(dns_name)`:                        true,
+		`without(xlat_src_address)`:             true,
+		`with(XlatSrcAddr) or without(DnsName)`: false,
+	} {
+		predicate, err := parseFilter(filter)
+		if !assert.Nil(t, err, filter) {
+			continue
+		}
+		assert.Equal(t, expected, predicate(flow), filter)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	setup(t)
+
+	for _, filter := range []string{
+		`unknown_key="value"`,
+		`src_port`,
+		`src_port>abc`,
+		`src_name=~"["`,
+		`(src_port=1`,
+		`src_name="missing quote`,
+		`src_port=1 src_port=2`,
+		`with(src_port`,
+	} {
+		_, err := parseFilter(filter)
+		assert.NotNil(t, err, filter)
+	}
+}
+
+func TestFilterFallbackAndCache(t *testing.T) {
+	setup(t)
+
+	var flow config.GenericMap
+	err := json.Unmarshal([]byte(sampleFlow), &flow)
+	assert.Nil(t, err)
+
+	// plain text and regexes still match the whole flow
+	assert.True(t, getFilterPredicate("my-statefulset")(flow))
+	assert.True(t, getFilterPredicate("us-.*-1a")(flow))
+	assert.False(t, getFilterPredicate("other")(flow))
+	assert.False(t, getFilterPredicate("[")(flow))
+
+	// filters are compiled once
+	getFilterPredicate(`src_port=1234`)
+	_, ok := compiledFilters[`src_port=1234`]
+	assert.True(t, ok)
+
+	// the cache is bounded
+	for i := range maxCompiledFilters * 2 {
+		getFilterPredicate(fmt.Sprintf("src_port=%d", i))
+	}
+	assert.LessOrEqual(t, len(compiledFilters), maxCompiledFilters)
+}
+
+func TestFilterTypedSlices(t *testing.T) {
+	setup(t)
+
+	// slices other than []interface{} come from code built maps
+	flow := config.GenericMap{"Interfaces": []string{"eth0", "eth1"}, "Bytes": float64(10)}
+	assert.True(t, getFilterPredicate(`interfaces="eth1"`)(flow))
+	assert.False(t, getFilterPredicate(`interfaces="eth2"`)(flow))
+	assert.Equal(t, "eth0,eth1", toValue(flow, "Interfaces"))
+	assert.Equal(t, `interfaces="eth0"`, toFilterText("Interfaces", []string{"eth0", "eth1"}))
+}
+
+func TestToFilterText(t *testing.T) {
+	setup(t)
+
+	assert.Equal(t, `src_namespace="first-namespace"`, toFilterText("SrcK8S_Namespace", "first-namespace"))
+	assert.Equal(t, `dst_port=5678`, toFilterText("DstPort", float64(5678)))
+	assert.Equal(t, `interfaces="eth0"`, toFilterText("Interfaces", []interface{}{"eth0", "eth1"}))
+	// src_kind matches multiple fields
+	assert.Equal(t, `SrcK8S_Type="Pod"`, toFilterText("SrcK8S_Type", "Pod"))
+
+	// generated filters can be parsed
+	_, err := parseFilter(toFilterText("SrcK8S_Name", `quoted "name"`))
+	assert.Nil(t, err)
+}
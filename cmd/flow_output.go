@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	jsonOutput   = "json"
+	ndjsonOutput = "ndjson"
+
+	// closing bytes of the json array, overwritten by each new record
+	jsonArrayEnd = "\n]\n"
+)
+
+var (
+	outputFormat = jsonOutput
+)
+
+// flowOutputWriter writes flows to a file that stays valid after each write so it can be read
+// even if the collector is killed
+type flowOutputWriter struct {
+	out    *outputFile
+	format string
+	offset int64
+	count  int
+}
+
+func newFlowOutputWriter(kind, filename, format string) (*flowOutputWriter, error) {
+	if format != jsonOutput && format != ndjsonOutput {
+		return nil, fmt.Errorf("unknown output format %s, expected %s or %s", format, jsonOutput, ndjsonOutput)
+	} else if format == jsonOutput && isCompressed() {
+		// json array end is rewritten on each write, which is not possible in a compressed stream
+		return nil, fmt.Errorf("%s output format can't be compressed, use %s instead", jsonOutput, ndjsonOutput)
+	}
+
+	out, err := createCompressedOutputFile(kind, filename+"."+format)
+	if err != nil {
+		return nil, err
+	}
+	w := &flowOutputWriter{out: out, format: format}
+	if format == jsonOutput {
+		// start with an empty array
+		if _, err = out.Write([]byte("[" + jsonArrayEnd)); err != nil {
+			out.Close()
+			return nil, err
+		}
+		w.offset = 1
+	}
+	return w, nil
+}
+
+func (w *flowOutputWriter) name() string {
+	return w.out.Name()
+}
+
+// write appends a json encoded flow and returns the size to account for limits
+func (w *flowOutputWriter) write(record []byte) (int64, error) {
+	diskBefore := w.out.getDiskSize()
+	if w.format == ndjsonOutput {
+		n, err := w.out.Write(append(append([]byte{}, record...), '\n'))
+		if err != nil {
+			return 0, err
+		}
+		w.count++
+		return countedSize(n, diskBefore, w.out.getDiskSize()), nil
+	}
+
+	// overwrite array end in a single write to never leave the file unclosed
+	sep := ",\n"
+	if w.count == 0 {
+		sep = "\n"
+	}
+	chunk := append([]byte(sep), record...)
+	chunk = append(chunk, jsonArrayEnd...)
+	n, err := w.out.file.WriteAt(chunk, w.offset)
+	if err != nil {
+		return 0, err
+	}
+	n -= len(jsonArrayEnd)
+	w.offset += int64(n)
+	w.count++
+	return int64(n), nil
+}
+
+func (w *flowOutputWriter) close() error {
+	return w.out.Close()
+}
+
+func init() {
+	registerSink("file", "json or ndjson flows file", []captureType{Flow}, func() Sink { return &flowFileSink{} })
+}
+
+// flowFileSink writes flows to ./output/flow/<name>.json or .ndjson according to the output format
+type flowFileSink struct {
+	out  *flowOutputWriter
+	size int64
+}
+
+func (s *flowFileSink) Open(name string) error {
+	out, err := newFlowOutputWriter("flow", name, outputFormat)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Created flow logs %s file: %s", outputFormat, out.name())
+	s.out = out
+	s.size = 0
+	return nil
+}
+
+func (s *flowFileSink) Write(record config.GenericMap) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	n, err := s.out.write(value)
+	s.size += n
+	return err
+}
+
+func (s *flowFileSink) Size() int64 {
+	return s.size
+}
+
+// Flush sends compressed data to the file, which is otherwise valid after each write
+func (s *flowFileSink) Flush() error {
+	return s.out.out.Flush()
+}
+
+func (s *flowFileSink) Close() error {
+	return s.out.close()
+}
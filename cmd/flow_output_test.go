@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlowOutputJSON(t *testing.T) {
+	w, err := newFlowOutputWriter("flow", "output_test", jsonOutput)
+	assert.Nil(t, err)
+	defer os.Remove(w.name())
+
+	// file is a valid array before and after each write
+	flows := []config.GenericMap{}
+	content, err := os.ReadFile(w.name())
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(content, &flows))
+	assert.Empty(t, flows)
+
+	n, err := w.write([]byte(`{"Bytes":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), n)
+	_, err = w.write([]byte(`{"Bytes":2}`))
+	assert.Nil(t, err)
+
+	content, err = os.ReadFile(w.name())
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n{\"Bytes\":1},\n{\"Bytes\":2}\n]\n", string(content))
+	assert.Nil(t, w.close())
+
+	flows, err = readFlowsFile(w.name())
+	assert.Nil(t, err)
+	assert.Equal(t, []config.GenericMap{{"Bytes": float64(1)}, {"Bytes": float64(2)}}, flows)
+}
+
+func TestFlowOutputNDJSON(t *testing.T) {
+	w, err := newFlowOutputWriter("flow", "output_test", ndjsonOutput)
+	assert.Nil(t, err)
+	defer os.Remove(w.name())
+
+	n, err := w.write([]byte(`{"Bytes":1}`))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), n)
+	_, err = w.write([]byte(`{"Bytes":2}`))
+	assert.Nil(t, err)
+	assert.Nil(t, w.close())
+
+	content, err := os.ReadFile(w.name())
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"Bytes\":1}\n{\"Bytes\":2}\n", string(content))
+
+	flows, err := readFlowsFile(w.name())
+	assert.Nil(t, err)
+	assert.Equal(t, []config.GenericMap{{"Bytes": float64(1)}, {"Bytes": float64(2)}}, flows)
+}
+
+func TestFlowOutputInvalidFormat(t *testing.T) {
+	_, err := newFlowOutputWriter("flow", "output_test", "txt")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	replayTick     = 100 * time.Millisecond // max sleep between two checks of the replay state
+	replaySeekStep = 10 * time.Second       // capture time skipped on each seek
+)
+
+var replayFlowsCmd = &cobra.Command{
+	Use:   "replay-flows <file>",
+	Short: "Replay a flow capture",
+	Long:  "Replay a flow capture from its output/flow json / txt chunks or sqlite db file",
+	Args:  cobra.ExactArgs(1),
+	Run:   runFlowReplay,
+}
+
+type flowReplay struct {
+	session   *CaptureSession
+	flows     []config.GenericMap
+	timeField string
+	speed     float64
+	position  int
+	// incremented on each seek to interrupt current wait
+	generation int
+	ended      bool
+	mutex      sync.Mutex
+}
+
+var (
+	replaySpeed = 1.0
+	replay      *flowReplay
+)
+
+func runFlowReplay(c *cobra.Command, args []string) {
+	s := startSession(c.Context(), Flow)
+
+	flows, err := readFlowsFile(args[0])
+	if err != nil {
+		log.Fatalf("Reading capture failed: %v", err)
+	}
+	log.Infof("Replaying %d flows from %s...", len(flows), args[0])
+	startReplay(s, args[0], flows, "TimeFlowEndMs")
+}
+
+func startReplay(s *CaptureSession, path string, flows []config.GenericMap, timeField string) {
+	if info, err := os.Stat(path); err == nil {
+		s.totalBytes.Store(info.Size())
+	}
+	s.filename = filepath.Base(path)
+	replay = newFlowReplay(s, flows, timeField, replaySpeed)
+
+	if isBackground {
+		go s.backgroundHearbeat() // show table periodically in background
+		replay.run()
+		s.captureEnded.Store(true)
+	} else {
+		go replay.run()
+		createFlowDisplay()
+	}
+}
+
+func newFlowReplay(s *CaptureSession, flows []config.GenericMap, timeField string, speed float64) *flowReplay {
+	// keep capture order for flows ending at the same time
+	sort.SliceStable(flows, func(i, j int) bool {
+		return toFloat64(flows[i], timeField) < toFloat64(flows[j], timeField)
+	})
+	return &flowReplay{
+		session:   s,
+		flows:     flows,
+		timeField: timeField,
+		speed:     speed,
+	}
+}
+
+// run feeds flows to the display until the end of the capture, respecting pause and seek requests
+func (r *flowReplay) run() {
+	for {
+		if r.session.captureEnded.Load() || r.session.isStopped() {
+			return
+		}
+		if r.session.paused.Load() {
+			time.Sleep(replayTick)
+			continue
+		}
+
+		r.mutex.Lock()
+		if r.position >= len(r.flows) {
+			r.ended = true
+			r.mutex.Unlock()
+			log.Info("Replay ended")
+			return
+		}
+		flow := r.flows[r.position]
+		wait := r.delay(r.position)
+		generation := r.generation
+		r.mutex.Unlock()
+
+		if !r.wait(wait, generation) {
+			// seek or pause occured while waiting
+			continue
+		}
+
+		r.mutex.Lock()
+		if generation == r.generation {
+			r.session.appendFlow(flow.Copy())
+			r.position++
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// delay returns the time to wait before playing flow at index according to replay speed
+func (r *flowReplay) delay(index int) time.Duration {
+	if r.speed <= 0 || index == 0 {
+		return 0
+	}
+	ms := r.timeMs(index) - r.timeMs(index-1)
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms / r.speed * float64(time.Millisecond))
+}
+
+// wait sleeps for the given duration, returning false if interrupted by a pause or a seek
+func (r *flowReplay) wait(d time.Duration, generation int) bool {
+	for d > 0 {
+		step := min(d, replayTick)
+		time.Sleep(step)
+		d -= step
+
+		r.mutex.Lock()
+		interrupted := r.session.paused.Load() || generation != r.generation
+		r.mutex.Unlock()
+		if interrupted {
+			return false
+		}
+	}
+	return true
+}
+
+// seek moves the replay position by the given capture time and redraws the flows kept in memory
+func (r *flowReplay) seek(delta time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.flows) == 0 {
+		return
+	}
+
+	current := r.position
+	if current >= len(r.flows) {
+		current = len(r.flows) - 1
+	}
+	target := r.timeMs(current) + float64(delta.Milliseconds())
+	position := sort.Search(len(r.flows), func(i int) bool {
+		return r.timeMs(i) > target
+	})
+	if position == r.position {
+		// flows without time field can't be seeked by time; move by a page instead
+		if delta < 0 {
+			position = max(0, r.position-r.session.showCount)
+		} else {
+			position = min(len(r.flows), r.position+r.session.showCount)
+		}
+	}
+
+	// playback loop returns at the end of the capture, restart it when seeking from there
+	if r.ended {
+		go r.run()
+	}
+	r.position = position
+	r.ended = false
+	r.generation++
+	r.session.resetFlows(r.flows[max(0, position-keepCount):position])
+
+	// top talkers and conversations cover every flow replayed so far
+	r.session.aggregates.reset()
+	r.session.conversations.reset()
+	for _, flow := range r.flows[:position] {
+		r.session.aggregates.add(flow)
+		r.session.conversations.add(flow)
+	}
+}
+
+func (r *flowReplay) getProgressText() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	speedStr := "max"
+	if r.speed > 0 {
+		speedStr = fmt.Sprintf("x%v", r.speed)
+	}
+	if r.ended {
+		return fmt.Sprintf("Replay ended: %d/%d %s", r.position, len(r.flows), speedStr)
+	}
+	if r.position == 0 || r.position > len(r.flows) {
+		return fmt.Sprintf("Replay: %d/%d %s", r.position, len(r.flows), speedStr)
+	}
+	return fmt.Sprintf("Replay: %s %d/%d %s",
+		time.UnixMilli(int64(r.timeMs(r.position-1))).Format(HHMMSS24h), r.position, len(r.flows), speedStr)
+}
+
+// timeMs returns the time of the flow at index in milliseconds
+func (r *flowReplay) timeMs(index int) float64 {
+	t := toFloat64(r.flows[index], r.timeField)
+	if r.timeField == "Time" {
+		// packets time is in seconds
+		return t * 1000
+	}
+	return t
+}
+
+// readFlowsFile loads flows from a capture written by the flow collector
+func readFlowsFile(path string) ([]config.GenericMap, error) {
+	if uncompressed, _ := splitCompressionExt(path); filepath.Ext(uncompressed) == ".db" {
+		return readFlowsDB(path)
+	}
+
+	f, err := openDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	first, err := peekFirstChar(reader)
+	if err != nil {
+		if err == io.EOF {
+			return []config.GenericMap{}, nil
+		}
+		return nil, err
+	}
+	if first == '[' {
+		return readFlowsArray(reader)
+	}
+	return readFlowsLines(reader)
+}
+
+func peekFirstChar(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// readFlowsArray reads a json array of flows such as the one written by the collector
+func readFlowsArray(reader io.Reader) ([]config.GenericMap, error) {
+	flows := []config.GenericMap{}
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	for decoder.More() {
+		flow := config.GenericMap{}
+		if err := decoder.Decode(&flow); err != nil {
+			return flows, fmt.Errorf("error decoding flow %d: %w", len(flows), err)
+		}
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+// readFlowsLines reads one flow per line, ignoring trailing commas from txt chunks
+// and invalid lines that can be found at the end of an interrupted capture
+func readFlowsLines(reader io.Reader) ([]config.GenericMap, error) {
+	flows := []config.GenericMap{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		b := bytes.TrimSuffix(bytes.TrimSpace(scanner.Bytes()), []byte(","))
+		if len(b) == 0 {
+			continue
+		}
+		flow := config.GenericMap{}
+		if err := json.Unmarshal(b, &flow); err != nil {
+			log.Warnf("Skipping invalid flow at line %d: %v", line, err)
+			continue
+		}
+		flows = append(flows, flow)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// compressed capture interrupted before its end
+			log.Warnf("Capture ends unexpectedly after line %d", line)
+			return flows, nil
+		}
+		return flows, err
+	}
+	return flows, nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFlowsFile(t *testing.T) {
+	dir := t.TempDir()
+
+	// txt chunks as written by the collector, including an interrupted last record
+	txt := filepath.Join(dir, "capture.txt")
+	err := os.WriteFile(txt, []byte(`{"Bytes":1,"TimeFlowEndMs":1000},
+{"Bytes":2,"TimeFlowEndMs":2000},
+{"Bytes":3,"Time`), 0600)
+	assert.Nil(t, err)
+	flows, err := readFlowsFile(txt)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(flows))
+	assert.Equal(t, float64(2), flows[1]["Bytes"])
+
+	// json array as generated by the copy command
+	json := filepath.Join(dir, "capture.json")
+	err = os.WriteFile(json, []byte(`[
+{"Bytes":1,"TimeFlowEndMs":1000},
+{"Bytes":2,"TimeFlowEndMs":2000}
+]`), 0600)
+	assert.Nil(t, err)
+	flows, err = readFlowsFile(json)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(flows))
+	assert.Equal(t, float64(1000), flows[0]["TimeFlowEndMs"])
+
+	// missing file
+	_, err = readFlowsFile(filepath.Join(dir, "missing.db"))
+	assert.NotNil(t, err)
+}
+
+func TestReadFlowsDB(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll("./output")
+
+	db := initFlowDB("replay")
+	assert.NotNil(t, db)
+	err := insertFlowToDB(db, []byte(`{"Bytes":32,"SrcAddr":"10.0.0.1"}`))
+	assert.Nil(t, err)
+	db.Close()
+
+	flows, err := readFlowsFile("./output/flow/replay.db")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(flows))
+	assert.Equal(t, float64(32), flows[0]["Bytes"])
+	assert.Equal(t, "10.0.0.1", flows[0]["SrcAddr"])
+	assert.NotContains(t, flows[0], "DnsName")
+}
+
+func TestFlowReplay(t *testing.T) {
+	setup(t)
+
+	flows := []config.GenericMap{}
+	// unordered capture spanning 60 seconds
+	for i := 60; i > 0; i-- {
+		flows = append(flows, config.GenericMap{
+			"Bytes":         float64(i),
+			"TimeFlowEndMs": float64(1704063600000 + i*1000),
+		})
+	}
+
+	r := newFlowReplay(session, flows, "TimeFlowEndMs", 0)
+	assert.Equal(t, time.Duration(0), r.delay(1))
+
+	// replay as fast as possible
+	r.run()
+	assert.True(t, r.ended)
+	assert.Equal(t, 60, len(session.lastFlows))
+	assert.Equal(t, float64(60), session.lastFlows[59]["Bytes"])
+
+	// seek back in time, paused to check the flows kept
+	session.paused.Store(true)
+	r.seek(-replaySeekStep)
+	r.mutex.Lock()
+	assert.False(t, r.ended)
+	assert.Equal(t, 50, r.position)
+	r.mutex.Unlock()
+	lastFlows := session.getLastFlows()
+	assert.Equal(t, 50, len(lastFlows))
+	assert.Equal(t, float64(50), lastFlows[49]["Bytes"])
+
+	// playback resumes after the end once seeked
+	session.paused.Store(false)
+	assert.Eventually(t, func() bool {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		return r.ended && r.position == 60
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(60), session.getLastFlows()[59]["Bytes"])
+
+	// real time replay waits according to flows time
+	r.speed = 2
+	assert.Equal(t, 500*time.Millisecond, r.delay(1))
+}
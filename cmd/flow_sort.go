@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+// rowIDField holds the key of aggregate and conversation rows
+const rowIDField = "Id"
+
+// toggleSort cycles sort on a column from descending to ascending to time order
+func (s *CaptureSession) toggleSort(id string) {
+	switch {
+	case s.sortColumn != id:
+		s.sortColumn = id
+		s.sortDescending = true
+	case s.sortDescending:
+		s.sortDescending = false
+	default:
+		s.sortColumn = ""
+	}
+}
+
+func (s *CaptureSession) getSortIndicator(id string) string {
+	if id != s.sortColumn {
+		return ""
+	}
+	if s.sortDescending {
+		return "▼"
+	}
+	return "▲"
+}
+
+// sortFlows sorts flows according to the current sort column, keeping time order for equal values
+func (s *CaptureSession) sortFlows(flows []config.GenericMap) {
+	if s.sortColumn == "" {
+		return
+	}
+	id := s.sortColumn
+	desc := s.sortDescending
+	slices.SortStableFunc(flows, func(fa, fb config.GenericMap) int {
+		a := getSortValue(fa, id)
+		b := getSortValue(fb, id)
+		// missing values always come last
+		switch {
+		case a == nil && b == nil:
+			return compareRowIDs(fa, fb)
+		case a == nil:
+			return 1
+		case b == nil:
+			return -1
+		}
+		c := compareSortValues(a, b)
+		if desc {
+			c = -c
+		}
+		if c == 0 {
+			return compareRowIDs(fa, fb)
+		}
+		return c
+	})
+}
+
+// compareRowIDs breaks sort ties on aggregate and conversation keys so their order is kept
+// between refreshes, flows without id keeping their order
+func compareRowIDs(a, b config.GenericMap) int {
+	idA, _ := a[rowIDField].(string)
+	idB, _ := b[rowIDField].(string)
+	return cmp.Compare(idA, idB)
+}
+
+// getSortValue returns the raw value of a column, durations and counts being kept as numbers
+func getSortValue(flow config.GenericMap, id string) interface{} {
+	switch id {
+	case "StartTime", "EndTime":
+		return flow[getTimeField(flow)]
+	}
+
+	var v interface{}
+	if fieldName := toFieldName(id); fieldName != "" {
+		v = flow[fieldName]
+	} else {
+		v = evalColumn(flow, id)
+	}
+
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if value == "" {
+			return nil
+		}
+		if addr, err := netip.ParseAddr(value); err == nil {
+			return addr
+		}
+		return value
+	case float64, int:
+		return value
+	default:
+		// arrays and objects are compared as displayed
+		return toColValue(flow, id, 0)
+	}
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch va := a.(type) {
+	case float64:
+		if vb, ok := b.(float64); ok {
+			return cmp.Compare(va, vb)
+		}
+	case int:
+		if vb, ok := b.(int); ok {
+			return cmp.Compare(va, vb)
+		}
+	case netip.Addr:
+		if vb, ok := b.(netip.Addr); ok {
+			return va.Compare(vb)
+		}
+	case string:
+		if vb, ok := b.(string); ok {
+			return cmp.Compare(va, vb)
+		}
+	}
+	// mixed types are compared as text
+	return cmp.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
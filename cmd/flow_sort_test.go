@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func appendSortTestFlows(t *testing.T) {
+	for i, flow := range []struct {
+		addr  string
+		bytes int
+		rtt   int
+	}{
+		{"10.0.0.9", 1000, 2000},
+		{"10.0.0.10", 500000, 1000},
+		{"10.0.0.100", 20, 0},
+		{"10.0.0.2", 3000, 30000},
+	} {
+		rtt := ""
+		if flow.rtt > 0 {
+			rtt = fmt.Sprintf(`"TimeFlowRttNs":%d,`, flow.rtt)
+		}
+		session.appendFlow(parseTestFlow(t, []byte(fmt.Sprintf(`{"SrcAddr":"%s","Bytes":%d,%s"TimeFlowEndMs":%d}`,
+			flow.addr, flow.bytes, rtt, 1704063600000+i*1000))))
+	}
+}
+
+func getColumnValues(col int) []string {
+	values := []string{}
+	for i := 1; i < session.tableData.GetRowCount(); i++ {
+		values = append(values, strings.TrimSpace(session.tableData.GetCell(i, col).Text))
+	}
+	return values
+}
+
+func TestSortFlows(t *testing.T) {
+	setup(t)
+	appendSortTestFlows(t)
+	session.selectedColumns = []string{"EndTime", "SrcAddr", "Bytes"}
+	session.updateTableAndSuggestions()
+
+	srcAddrCol := 1
+	bytesCol := 2
+
+	// time order by default
+	assert.Equal(t, []string{"10.0.0.9", "10.0.0.10", "10.0.0.100", "10.0.0.2"}, getColumnValues(srcAddrCol))
+
+	// numbers descending then ascending
+	session.sortOnColumn(bytesCol)
+	assert.Equal(t, []string{"500KB", "3KB", "1KB", "20B"}, getColumnValues(bytesCol))
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(session.tableData.GetCell(0, bytesCol).Text), "▼"))
+	session.sortOnColumn(bytesCol)
+	assert.Equal(t, []string{"20B", "1KB", "3KB", "500KB"}, getColumnValues(bytesCol))
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(session.tableData.GetCell(0, bytesCol).Text), "▲"))
+
+	// back to time order
+	session.sortOnColumn(bytesCol)
+	assert.Equal(t, "", session.sortColumn)
+	assert.Equal(t, []string{"10.0.0.9", "10.0.0.10", "10.0.0.100", "10.0.0.2"}, getColumnValues(srcAddrCol))
+
+	// ips are compared as addresses
+	session.sortOnColumn(srcAddrCol)
+	session.sortOnColumn(srcAddrCol)
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.9", "10.0.0.10", "10.0.0.100"}, getColumnValues(srcAddrCol))
+
+	// moving along the header doesn't sort, clicking it does
+	table := getTable()
+	defer func() { tableView = nil }()
+	session.sortOnColumn(srcAddrCol)
+	table.Select(0, bytesCol)
+	table.Select(0, srcAddrCol)
+	assert.Equal(t, "", session.sortColumn)
+	session.tableData.GetCell(0, bytesCol).Clicked()
+	assert.Equal(t, "Bytes", session.sortColumn)
+}
+
+func TestSortFlowsDurationsAndLimit(t *testing.T) {
+	setup(t)
+	appendSortTestFlows(t)
+	session.selectedColumns = []string{"SrcAddr", "TimeFlowRttMs"}
+	session.showCount = 2
+
+	// durations are compared as numbers, keeping the top flows and missing values last
+	session.sortColumn = "TimeFlowRttMs"
+	session.sortDescending = true
+	session.updateTableAndSuggestions()
+	assert.Equal(t, []string{"30µs", "2µs"}, getColumnValues(1))
+
+	session.sortDescending = false
+	session.showCount = 10
+	session.updateTableAndSuggestions()
+	assert.Equal(t, []string{"1µs", "2µs", "30µs", "n/a"}, getColumnValues(1))
+}
+
+func TestSortTiesOnRowIDs(t *testing.T) {
+	setup(t)
+	rows := func() []config.GenericMap {
+		return []config.GenericMap{
+			{rowIDField: "c", "Bytes": float64(10)},
+			{rowIDField: "a", "Bytes": float64(10)},
+			{rowIDField: "b", "Bytes": float64(20)},
+			{rowIDField: "d"},
+		}
+	}
+	ids := func(flows []config.GenericMap) []string {
+		values := []string{}
+		for _, flow := range flows {
+			values = append(values, flow[rowIDField].(string))
+		}
+		return values
+	}
+
+	// equal and missing values are ordered by key whatever the input order
+	session.sortColumn = "Bytes"
+	session.sortDescending = true
+	flows := rows()
+	session.sortFlows(flows)
+	assert.Equal(t, []string{"b", "a", "c", "d"}, ids(flows))
+	flows = rows()
+	slices.Reverse(flows)
+	session.sortFlows(flows)
+	assert.Equal(t, []string{"b", "a", "c", "d"}, ids(flows))
+
+	session.sortDescending = false
+	session.sortFlows(flows)
+	assert.Equal(t, []string{"a", "c", "b", "d"}, ids(flows))
+}
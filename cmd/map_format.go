@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
@@ -31,6 +33,10 @@ var (
 		"Dropped", "Drop",
 		"L3 Layer", "L3",
 	)
+
+	// reverse dictionnaries of formatted values
+	protoValues = sync.OnceValue(func() map[string]float64 { return enumValues(toProto, 255) })
+	dscpValues  = sync.OnceValue(func() map[string]float64 { return enumValues(toDSCP, 63) })
 )
 
 func toCount(genericMap config.GenericMap, fieldName string) string {
@@ -419,10 +425,10 @@ func toDSCP(genericMap config.GenericMap, fieldName string) string {
 func toValue(genericMap config.GenericMap, fieldName string) string {
 	v, ok := genericMap[fieldName]
 	if ok {
-		if reflect.TypeOf(v).Kind() == reflect.Slice {
-			arr := make([]string, len(v.([]interface{})))
-			for i, value := range v.([]interface{}) {
-				switch v := value.(type) {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+			arr := make([]string, rv.Len())
+			for i := range rv.Len() {
+				switch v := rv.Index(i).Interface().(type) {
 				case string:
 					arr[i] = v
 					if arr[i] == "" {
@@ -460,6 +466,16 @@ func toFieldName(id string) string {
 	colIndex := slices.IndexFunc(cfg.Columns, func(c *ColumnConfig) bool { return c.ID == id })
 	if colIndex != -1 {
 		return cfg.Columns[colIndex].Field
+	} else if col := getPseudoColumn(id); col != nil {
+		return col.Field
+	}
+	return ""
+}
+
+func toCalculated(id string) string {
+	colIndex := slices.IndexFunc(cfg.Columns, func(c *ColumnConfig) bool { return c.ID == id })
+	if colIndex != -1 {
+		return cfg.Columns[colIndex].Calculated
 	}
 	return ""
 }
@@ -493,6 +509,8 @@ func toColWidth(id string) int {
 	width := 6
 	if colIndex != -1 {
 		width = cfg.Columns[colIndex].Width
+	} else if col := getPseudoColumn(id); col != nil {
+		width = col.Width
 	}
 	return width + extraWidth
 }
@@ -515,6 +533,8 @@ func toColName(id string, width int) string {
 		} else {
 			name = col.Name
 		}
+	} else if col := getPseudoColumn(id); col != nil {
+		name = col.Name
 	}
 	return ellipsizeAndPad(replacer.Replace(name), width)
 }
@@ -528,6 +548,14 @@ func toMetricName(query string, width int) string {
 	return ellipsizeAndPad(replacer.Replace(name), width)
 }
 
+// getTimeField returns the end time field of a record, packets having a Time in seconds unlike flows
+func getTimeField(genericMap config.GenericMap) string {
+	if _, found := genericMap["Time"]; found {
+		return "Time"
+	}
+	return "TimeFlowEndMs"
+}
+
 func toColValue(genericMap config.GenericMap, id string, width int) string {
 	// convert column id to its field accordingly
 	fieldName := toFieldName(id)
@@ -537,11 +565,7 @@ func toColValue(genericMap config.GenericMap, id string, width int) string {
 	case rawDisplay:
 		outputStr = fmt.Sprintf("%v", genericMap)
 	case "StartTime", "EndTime":
-		if capture == Flow {
-			outputStr = toTimeString(genericMap, "TimeFlowEndMs")
-		} else {
-			outputStr = toTimeString(genericMap, "Time")
-		}
+		outputStr = toTimeString(genericMap, getTimeField(genericMap))
 	// special cases where autocompletes are involved
 	case "FlowDirection", "IfDirections":
 		outputStr = toDirection(genericMap, fieldName)
@@ -562,10 +586,142 @@ func toColValue(genericMap config.GenericMap, id string, width int) string {
 	case "NetworkEvents":
 		events := ovnutils.NetworkEventsToStrings(genericMap)
 		outputStr = strings.Join(events, ", ")
+	// top talkers pseudo columns
+	case "AggFlows", "AggBytesRate", "AggPacketsRate", "AggRttAvg", "AggRttP95":
+		outputStr = toAggregateValue(genericMap, id)
+	// conversations pseudo columns
+	case "ConvState", "ConvFlows", "ConvDuration", "ConvBytesFwd", "ConvBytesRev", "ConvPacketsFwd", "ConvPacketsRev":
+		outputStr = toConversationValue(genericMap, id)
 	default:
-		// else simply pick field value as text from column name
-		outputStr = toValue(genericMap, fieldName)
+		if fieldName == "" && toCalculated(id) != "" {
+			// columns without field are evaluated from their expression
+			outputStr = toCalculatedValue(genericMap, id)
+		} else {
+			// else simply pick field value as text from column name
+			outputStr = toValue(genericMap, fieldName)
+		}
 	}
 
 	return ellipsizeAndPad(outputStr, width)
 }
+
+func toFieldType(name string) string {
+	fieldIndex := slices.IndexFunc(cfg.Fields, func(f *FieldConfig) bool { return f.Name == name })
+	if fieldIndex != -1 {
+		return cfg.Fields[fieldIndex].Type
+	}
+	return ""
+}
+
+func enumValues(format func(config.GenericMap, string) string, maxValue int) map[string]float64 {
+	values := map[string]float64{}
+	for i := range maxValue + 1 {
+		values[format(config.GenericMap{"v": float64(i)}, "v")] = float64(i)
+	}
+	return values
+}
+
+func parseNumber(value string) (interface{}, bool) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func parseDirection(value string) (interface{}, bool) {
+	switch value {
+	case "Ingress":
+		return float64(0), true
+	case "Egress":
+		return float64(1), true
+	case "Inner":
+		return float64(2), true
+	default:
+		return parseNumber(value)
+	}
+}
+
+func parseEnum(values map[string]float64) func(string) (interface{}, bool) {
+	return func(value string) (interface{}, bool) {
+		if v, ok := values[value]; ok {
+			return v, true
+		}
+		return parseNumber(value)
+	}
+}
+
+func parseCount(value string) (interface{}, bool) {
+	v, err := sizestr.Parse(value)
+	if err != nil {
+		return nil, false
+	}
+	return float64(v), true
+}
+
+func parseDuration(factor time.Duration) func(string) (interface{}, bool) {
+	return func(value string) (interface{}, bool) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, false
+		}
+		return float64(d / factor), true
+	}
+}
+
+// parseValue parses a single or a comma separated list of values according to field type
+func parseValue(value string, fieldType string, parse func(string) (interface{}, bool)) (interface{}, bool) {
+	if !strings.HasSuffix(fieldType, "[]") {
+		return parse(value)
+	}
+	arr := []interface{}{}
+	for _, item := range strings.Split(value, ",") {
+		v, ok := parse(item)
+		if !ok {
+			return nil, false
+		}
+		arr = append(arr, v)
+	}
+	return arr, true
+}
+
+// parseColValue converts a value formatted by toColValue back to its field value
+// returning false when it can't be recovered
+func parseColValue(value string, id string) (interface{}, bool) {
+	if value == emptyText {
+		return nil, false
+	}
+
+	fieldType := toFieldType(toFieldName(id))
+	switch id {
+	// time and network events formatting is lossy
+	case "StartTime", "EndTime", "NetworkEvents":
+		return nil, false
+	case "FlowDirection", "IfDirections":
+		return parseValue(value, fieldType, parseDirection)
+	case "Proto":
+		return parseValue(value, fieldType, parseEnum(protoValues()))
+	case "Dscp":
+		return parseValue(value, fieldType, parseEnum(dscpValues()))
+	case "Bytes", "PktDropBytes":
+		return parseValue(value, fieldType, parseCount)
+	case "DNSLatency":
+		return parseValue(value, fieldType, parseDuration(time.Millisecond))
+	case "TimeFlowRttMs":
+		return parseValue(value, fieldType, parseDuration(time.Nanosecond))
+	}
+
+	switch fieldType {
+	case "number", "number[]":
+		return parseValue(value, fieldType, parseNumber)
+	case "string[]":
+		return parseValue(value, fieldType, func(item string) (interface{}, bool) {
+			if item == "None" {
+				return "", true
+			}
+			return item, true
+		})
+	default:
+		return value, true
+	}
+}
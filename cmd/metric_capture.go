@@ -30,18 +30,13 @@ var (
 )
 
 func runMetricCapture(c *cobra.Command, _ []string) {
-	capture = Metric
+	s := startSession(c.Context(), Metric)
 
 	updateGraphs(false) // initial update of graphs to have something to display
-	if isBackground {
-		startMetricCollector(c.Context())
-	} else {
-		go startMetricCollector(c.Context())
-		createMetricDisplay()
-	}
+	s.run(s.startMetricCollector, createMetricDisplay)
 }
 
-func startMetricCollector(ctx context.Context) {
+func (s *CaptureSession) startMetricCollector() {
 	cl, err := newClient(
 		time.Duration(30*time.Second),
 		false,
@@ -61,42 +56,45 @@ func startMetricCollector(ctx context.Context) {
 	ticker := time.NewTicker(15 * time.Second)
 	defer ticker.Stop()
 	log.Trace("Ready ! Querying metrics...")
-	for ; true; <-ticker.C {
-		if stopReceived {
-			log.Debug("Stop received")
-			return
-		}
-
+	for {
 		// run query on tick
-		queryGraphs(ctx, cl)
+		s.queryGraphs(s.ctx, cl)
 
 		// terminate capture if max time reached
-		now := currentTime()
-		duration := now.Sub(startupTime)
-		if int(duration) > int(maxTime) {
-			if exit := onLimitReached(); exit {
-				log.Infof("Capture reached %s, exiting now...", maxTime)
+		if reached, limit := s.isLimitReached(); reached {
+			if exit := s.onLimitReached("capture reached " + limit); exit {
+				log.Infof("Capture reached %s, exiting now...", limit)
 				return
 			}
 		}
 
-		captureStarted = true
+		s.captureStarted.Store(true)
+
+		select {
+		case <-s.ctx.Done():
+			log.Debug("Stop received")
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
-func queryGraphs(ctx context.Context, client api.Client) {
+func (s *CaptureSession) queryGraphs(ctx context.Context, client api.Client) {
 	for index := range graphs {
 		if isBackground {
-			queryGraph(ctx, client, index) // keep logical order for background mode
+			s.queryGraph(ctx, client, index) // keep logical order for background mode
 		} else {
-			go queryGraph(ctx, client, index)
+			go s.queryGraph(ctx, client, index)
 		}
 	}
 }
 
-func queryGraph(ctx context.Context, client api.Client, index int) {
-	query, result := queryProm(ctx, client, graphs[index].Query.PromQL)
-	if app == nil || errAdvancedDisplay != nil {
+func (s *CaptureSession) queryGraph(ctx context.Context, client api.Client, index int) {
+	query, result := s.queryProm(ctx, client, graphs[index].Query.PromQL)
+	if s.api != nil {
+		s.api.publishMetrics(query, result)
+	}
+	if s.app == nil || errAdvancedDisplay != nil {
 		// simply print metrics into logs
 		log.Print(query.PromQL)
 		if result == nil || len(*result) == 0 {
@@ -111,7 +109,7 @@ func queryGraph(ctx context.Context, client api.Client, index int) {
 	}
 }
 
-func queryProm(ctx context.Context, client api.Client, promQL string) (*Query, *Matrix) {
+func (s *CaptureSession) queryProm(ctx context.Context, client api.Client, promQL string) (*Query, *Matrix) {
 	now := currentTime()
 
 	ran, err := time.ParseDuration(durations[selectedDuration])
@@ -120,7 +118,7 @@ func queryProm(ctx context.Context, client api.Client, promQL string) (*Query, *
 	}
 	end := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, now.Location())
 	start := end.Add(-ran)
-	step := time.Duration(ran.Nanoseconds() / int64(showCount))
+	step := time.Duration(ran.Nanoseconds() / int64(s.showCount))
 
 	// update query with start / end / step
 	query := Query{
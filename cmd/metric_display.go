@@ -67,17 +67,18 @@ var (
 func createMetricDisplay() {
 	updateShowMetricCount()
 
-	app = tview.NewApplication().
+	session.app = tview.NewApplication().
 		SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 			//nolint:exhaustive
 			switch event.Key() {
 			case tcell.KeyCtrlC:
 				log.Info("Ctrl-C pressed, exiting program.")
-				if app != nil {
-					app.Stop()
+				session.stop(errInterrupted)
+				if session.app != nil {
+					session.app.Stop()
 				}
 			case tcell.KeyCtrlSpace:
-				pause(!paused)
+				pause(!session.paused.Load())
 			default:
 				// nothing to do here
 			}
@@ -86,9 +87,9 @@ func createMetricDisplay() {
 		SetRoot(getPages(), true).
 		EnableMouse(true)
 
-	go hearbeat()
+	go session.hearbeat()
 
-	errAdvancedDisplay = app.Run()
+	errAdvancedDisplay = session.app.Run()
 	if errAdvancedDisplay != nil {
 		log.Errorf("Can't display advanced UI: %v", errAdvancedDisplay)
 	}
@@ -138,21 +139,21 @@ func getMetricTop() tview.Primitive {
 			}), 10, 0, false)
 	}
 	panelsRow.AddItem(tview.NewTextView(), 0, 2, false)
-	if !paused {
+	if !session.paused.Load() {
 		updatePanels(false)
 	}
 
 	// add panels modal button
 	panelsRow.AddItem(tview.NewButton(" Manage panels ").SetSelectedFunc(func() {
 		showPopup = true
-		app.SetRoot(getPages(), true)
+		session.app.SetRoot(getPages(), true)
 	}), 16, 0, false)
 	topView.AddItem(panelsRow, 1, 0, false)
 	return topView
 }
 
 func updateShowMetricCount() {
-	showCount = metricCounts[durations[selectedDuration]]
+	session.showCount = metricCounts[durations[selectedDuration]]
 	countTextView.SetText(getShowCountText())
 }
 
@@ -287,7 +288,7 @@ func getMetricsModal() tview.Primitive {
 }
 
 func getMetricShowCountText() string {
-	return fmt.Sprintf("Showing %d points per graph", showCount)
+	return fmt.Sprintf("Showing %d points per graph", session.showCount)
 }
 
 func getPanelsText() string {
@@ -333,7 +334,7 @@ func updateGraphs(query bool) {
 
 	getGraphs()
 	if query && client != nil {
-		go queryGraphs(context.TODO(), *client)
+		go session.queryGraphs(context.TODO(), *client)
 	}
 }
 
@@ -377,7 +378,7 @@ func updatePlots() {
 
 func appendMetrics(query *Query, matrix *Matrix, index int) {
 	// Skip if paused, query / matrix are invalid or when graph array changed in between
-	if paused || query == nil || matrix == nil || index >= len(graphs) || graphs[index].Query.PromQL != query.PromQL {
+	if session.paused.Load() || query == nil || matrix == nil || index >= len(graphs) || graphs[index].Query.PromQL != query.PromQL {
 		return
 	}
 
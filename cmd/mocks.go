@@ -46,12 +46,12 @@ func sendMock(ch chan struct{}, client genericmap.CollectorClient) {
 	ch <- struct{}{}
 }
 
-func mockForever() {
+func mockForever(s *CaptureSession) {
 	wait := make(chan struct{})
-	for !collectorStarted {
+	for !s.collectorStarted.Load() {
 		// collector is not involved in metric capture
 		// see mock queryRangeMock below
-		if capture == Metric {
+		if s.capture == Metric {
 			return
 		}
 
@@ -63,7 +63,7 @@ func mockForever() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	for {
+	for !s.isStopped() {
 		go sendMock(wait, cc.Client())
 		<-wait
 	}
@@ -89,7 +89,7 @@ func matrixMock() QueryResponse {
 		// rand numbers to display across time
 		now := currentTime().UnixNano()
 		val := rand.Float64() * 50
-		for j := range showCount {
+		for j := range session.showCount {
 			now -= int64(j) * 1000000
 			samples[i].Values = append(samples[i].Values, pmod.SamplePair{
 				Timestamp: pmod.Time(now),
@@ -106,7 +106,7 @@ func matrixMock() QueryResponse {
 
 	// screw up some start values in one of the samples to test robustness
 	if len(samples) > 7 && len(samples[7].Values) > 0 {
-		samples[7].Values = samples[7].Values[showCount-5 : len(samples[7].Values)]
+		samples[7].Values = samples[7].Values[session.showCount-5 : len(samples[7].Values)]
 	}
 
 	// screw up an end value in one of the samples to test robustness
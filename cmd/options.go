@@ -17,6 +17,8 @@ var (
 	// displays
 	rawDisplay           = "Raw"
 	standardDisplay      = "Standard"
+	topTalkersDisplay    = "Top talkers"
+	conversationsDisplay = "Conversations"
 	pktDropFeature       = "pktDrop"
 	dnsFeature           = "dnsTracking"
 	rttFeature           = "flowRTT"
@@ -34,6 +36,8 @@ var (
 			// exclusive displays
 			{name: rawDisplay},
 			{name: standardDisplay},
+			{name: topTalkersDisplay},
+			{name: conversationsDisplay},
 			// per feature displays
 			{name: "Packet drops", ids: []string{pktDropFeature}},
 			{name: "DNS", ids: []string{dnsFeature}},
@@ -11,9 +11,7 @@ import (
 	"github.com/gopacket/gopacket"
 	"github.com/gopacket/gopacket/layers"
 	"github.com/gopacket/gopacket/pcapgo"
-	"github.com/jpillora/sizestr"
 	"github.com/netobserv/flowlogs-pipeline/pkg/config"
-	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/utils"
 	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/write/grpc"
 	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/write/grpc/genericmap"
 	"github.com/spf13/cobra"
@@ -32,42 +30,28 @@ var (
 	commonComment strings.Builder
 )
 
-func runPacketCapture(_ *cobra.Command, _ []string) {
-	capture = Packet
-	if isBackground {
-		go backgroundHearbeat() // show table periodically in background
-		startPacketCollector()
-	} else {
-		go startPacketCollector()
-		createFlowDisplay()
-	}
+func runPacketCapture(c *cobra.Command, _ []string) {
+	s := startSession(c.Context(), Packet)
+	s.run(s.startPacketCollector, createFlowDisplay)
 }
 
-//nolint:cyclop
-func startPacketCollector() {
-	if len(filename) > 0 {
-		log.Infof("Starting Packet Capture for %s...", filename)
+func (s *CaptureSession) startPacketCollector() {
+	if len(s.filename) > 0 {
+		log.Infof("Starting Packet Capture for %s...", s.filename)
 	} else {
 		log.Infof("Starting Packet Capture...")
-		filename = strings.ReplaceAll(
-			currentTime().UTC().Format(time.RFC3339),
-			":", "") // get rid of offensive colons
 	}
 
-	f, err := createOutputFile("pcap", filename+".pcapng")
-	if err != nil {
+	if err := validateCompression(); err != nil {
 		log.Fatal(err)
 	}
-	defer f.Close()
-	log.Trace("Created pcapng file")
-
-	ngw, err := pcapgo.NewNgWriter(f, layers.LinkTypeEthernet)
-	if err != nil {
-		log.Error("Error while creating writer", err)
-		return
-	}
-	defer ngw.Flush()
-	log.Trace("Wrote pcap section header & interface")
+	s.initTrigger()
+	s.initAnonymizer()
+	s.startDisplayQueue()
+	defer s.displayQueue.close()
+	rotation := newOutputRotation("pcap", s.getOutputName())
+	s.initSinks(rotation.getName())
+	defer s.sinks.close()
 
 	flowPackets := make(chan *genericmap.Flow, 100)
 	collector, err := grpc.StartCollector(port, flowPackets)
@@ -75,74 +59,194 @@ func startPacketCollector() {
 		log.Error("StartCollector failed", err)
 		return
 	}
+	defer collector.Close()
 	log.Debug("Started collector")
-	collectorStarted = true
+	s.collectorStarted.Store(true)
+
+	// end the trigger window on time even when no packet is received
+	var triggerTick <-chan time.Time
+	if s.trigger != nil {
+		ticker := time.NewTicker(s.trigger.getTickInterval())
+		defer ticker.Stop()
+		triggerTick = ticker.C
+	}
 
-	go func() {
-		<-utils.ExitChannel()
-		log.Debug("Ending collector")
-		close(flowPackets)
-		collector.Close()
-		log.Debug("Done")
-	}()
+	// end the capture on time even when no packet is received
+	var maxTimeTick <-chan time.Time
+	if timer := s.newMaxTimeTimer(); timer != nil {
+		defer timer.Stop()
+		maxTimeTick = timer.C
+	}
 
 	log.Trace("Ready ! Waiting for packets...")
-	for fp := range flowPackets {
-		if !captureStarted {
-			log.Debugf("Received first %d packets", len(flowPackets))
-		}
-
-		if stopReceived {
-			log.Debug("Stop received")
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Debugf("Stop received: %v", s.getStopCause())
+			// stop receiving then write the packets already received
+			collector.Close()
+			log.Debugf("Draining %d packets", len(flowPackets))
+			for len(flowPackets) > 0 {
+				if exit := s.writePacket(<-flowPackets, rotation); exit {
+					break
+				}
+			}
 			return
+		case fp := <-flowPackets:
+			if !s.captureStarted.Load() {
+				log.Debugf("Received first %d packets", len(flowPackets))
+			}
+			if exit := s.writePacket(fp, rotation); exit {
+				return
+			}
+		case <-maxTimeTick:
+			// the stopped context then drains the records received meanwhile
+			s.endOnReachedLimit(s.maxTime.String())
+		case <-triggerTick:
+			// the stopped context then drains the records received meanwhile
+			s.expireTrigger()
 		}
+	}
+}
 
-		genericMap := config.GenericMap{}
-		err := json.Unmarshal(fp.GenericMap.Value, &genericMap)
-		if err != nil {
-			log.Error("Error while parsing json", err)
-			return
-		}
-		if !captureStarted {
-			log.Debugf("Parsed genericMap %v", genericMap)
-		}
+// writePacket displays a received packet and writes it to sinks, returning true when the collector must exit
+//
+//nolint:cyclop
+func (s *CaptureSession) writePacket(fp *genericmap.Flow, rotation *outputRotation) bool {
+	s.received.Add(1)
+	genericMap := config.GenericMap{}
+	err := json.Unmarshal(fp.GenericMap.Value, &genericMap)
+	if err != nil {
+		log.Error("Error while parsing json", err)
+		return true
+	}
+	if !s.captureStarted.Load() {
+		log.Debugf("Parsed genericMap %v", genericMap)
+	}
 
-		data, ok := genericMap["Data"]
-		if ok {
-			// display as flow async
-			go AppendFlow(genericMap.Copy())
+	// anonymize before anything is displayed or written
+	value := fp.GenericMap.Value
+	if s.anonymizer != nil {
+		s.anonymizer.anonymizeFlow(genericMap)
+		if value, err = json.Marshal(genericMap); err != nil {
+			log.Error("Error while anonymizing packet", err)
+			return true
+		}
+	}
 
-			writePacketData(ngw, &genericMap, &data)
-		} else {
-			if !captureStarted {
-				log.Debug("Data is missing")
-			}
+	// display as flow through the bounded display queue
+	s.displayRecord(genericMap.Copy())
+	if _, ok := genericMap["Data"]; !ok && !s.captureStarted.Load() {
+		log.Debug("Data is missing")
+	}
 
-			// display as flow async
-			go AppendFlow(genericMap)
+	// only write packets around trigger matches when enabled
+	records := []triggerRecord{{value: value, flow: genericMap}}
+	if s.trigger != nil {
+		var stop bool
+		records, stop = s.trigger.process(value, genericMap)
+		if stop && s.endOnTriggerWindow() {
+			return true
 		}
+	}
 
-		// terminate capture if max bytes reached
-		totalBytes += int64(len(fp.GenericMap.Value))
-		if totalBytes > maxBytes {
-			if exit := onLimitReached(); exit {
-				log.Infof("Capture reached %s, exiting now...", sizestr.ToString(maxBytes))
-				return
+	for _, record := range records {
+		// continuous capture switches to new files instead of ending
+		bytes := s.sinks.write(record.flow)
+		s.written.Add(1)
+		s.addBytes(bytes)
+		if rotation.add(bytes) {
+			if err := s.rotateSinks(rotation); err != nil {
+				log.Error("Error while rotating sinks", err)
+				return true
 			}
+			log.Infof("Rotated packet capture to %s", rotation.getName())
 		}
+	}
+	if isRotationEnabled() {
+		s.captureStarted.Store(true)
+		return false
+	}
 
-		// terminate capture if max time reached
-		now := currentTime()
-		duration := now.Sub(startupTime)
-		if int(duration) > int(maxTime) {
-			if exit := onLimitReached(); exit {
-				log.Infof("Capture reached %s, exiting now...", maxTime)
-				return
-			}
-		}
+	// terminate capture if max bytes or time reached
+	if s.endOnLimit() {
+		return true
+	}
+
+	s.captureStarted.Store(true)
+	return false
+}
 
-		captureStarted = true
+// openPacketOutput creates a pcapng file, writing its section header and interface
+func openPacketOutput(name string) (*outputFile, *pcapgo.NgWriter, error) {
+	out, err := createCompressedOutputFile("pcap", name+".pcapng")
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Trace("Created pcapng file")
+
+	ngw, err := pcapgo.NewNgWriter(out, layers.LinkTypeEthernet)
+	if err != nil {
+		out.Close()
+		return nil, nil, err
+	}
+	log.Trace("Wrote pcap section header & interface")
+	return out, ngw, nil
+}
+
+func init() {
+	registerSink("pcapng", "pcapng file with enrichment as packet comments", []captureType{Packet}, func() Sink { return &pcapngSink{} })
+}
+
+// pcapngSink writes packets to ./output/pcap/<name>.pcapng
+type pcapngSink struct {
+	out  *outputFile
+	ngw  *pcapgo.NgWriter
+	size int64
+}
+
+func (s *pcapngSink) Open(name string) error {
+	out, ngw, err := openPacketOutput(name)
+	if err != nil {
+		return err
+	}
+	s.out, s.ngw, s.size = out, ngw, 0
+	return nil
+}
+
+// Write adds the packet data, if any, accounting for its size
+func (s *pcapngSink) Write(record config.GenericMap) error {
+	data, ok := record["Data"]
+	if !ok {
+		return nil
+	}
+	str, ok := data.(string)
+	if !ok {
+		return fmt.Errorf("unexpected packet data type %T", data)
+	}
+	diskBefore := s.out.getDiskSize()
+	writePacketData(s.ngw, &record, &data)
+	s.size += countedSize(base64.StdEncoding.DecodedLen(len(str)), diskBefore, s.out.getDiskSize())
+	return nil
+}
+
+func (s *pcapngSink) Size() int64 {
+	return s.size
+}
+
+func (s *pcapngSink) Flush() error {
+	if err := s.ngw.Flush(); err != nil {
+		return err
+	}
+	return s.out.Flush()
+}
+
+func (s *pcapngSink) Close() error {
+	if err := s.ngw.Flush(); err != nil {
+		s.out.Close()
+		return err
 	}
+	return s.out.Close()
 }
 
 func writePacketData(ngw *pcapgo.NgWriter, genericMap *config.GenericMap, data *interface{}) {
@@ -184,6 +288,40 @@ func writePacketData(ngw *pcapgo.NgWriter, genericMap *config.GenericMap, data *
 		}
 	}
 
+	// add calculated columns such as kubernetes objects
+	for _, col := range cfg.Columns {
+		if col.Field != "" || col.Calculated == "" || strings.HasPrefix(col.Calculated, "[") {
+			// skip field columns and src / dst arrays already written
+			continue
+		}
+		value := toColValue(*genericMap, col.ID, 0)
+		if value == emptyText {
+			continue
+		}
+		str := fmt.Sprintf("%s: %s\n", toColName(col.ID, 0), value)
+		switch col.Group {
+		case "Source":
+			srcComment.WriteString(str)
+		case "Destination":
+			dstComment.WriteString(str)
+		default:
+			commonComment.WriteString(str)
+		}
+	}
+
+	// keep raw values for replay since displayed ones are rounded or partial
+	record := make(config.GenericMap, len(*genericMap))
+	for k, v := range *genericMap {
+		if k != "Data" {
+			record[k] = v
+		}
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		log.Error("Error while encoding record", err)
+		return
+	}
+
 	// write enriched data as interface
 	if err := ngw.WritePacketWithOptions(gopacket.CaptureInfo{
 		Timestamp:     ts,
@@ -194,6 +332,7 @@ func writePacketData(ngw *pcapgo.NgWriter, genericMap *config.GenericMap, data *
 			srcComment.String(),
 			dstComment.String(),
 			commonComment.String(),
+			rawCommentTitle + string(raw),
 		},
 	}); err != nil {
 		log.Error("Error while writing packet", err)
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gopacket/gopacket"
+	"github.com/gopacket/gopacket/pcapgo"
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var replayPacketsCmd = &cobra.Command{
+	Use:   "replay-packets <file>",
+	Short: "Replay a packet capture",
+	Long:  "Replay a packet capture from its output/pcap pcapng file",
+	Args:  cobra.ExactArgs(1),
+	Run:   runPacketReplay,
+}
+
+func runPacketReplay(c *cobra.Command, args []string) {
+	s := startSession(c.Context(), Packet)
+
+	packets, err := readPacketsFile(args[0])
+	if err != nil {
+		log.Fatalf("Reading capture failed: %v", err)
+	}
+	log.Infof("Replaying %d packets from %s...", len(packets), args[0])
+	startReplay(s, args[0], packets, "Time")
+}
+
+// rawCommentTitle starts the packet comment holding the record as json
+const rawCommentTitle = "Raw\n"
+
+// readPacketsFile loads packets from a pcapng file written by writePacketData
+func readPacketsFile(path string) ([]config.GenericMap, error) {
+	f, err := openDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ngr, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := getCommentColumns()
+	packets := []config.GenericMap{}
+	for {
+		data, ci, opts, err := ngr.ReadPacketDataWithOptions()
+		if err == io.EOF {
+			break
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			// compressed capture interrupted before its end
+			log.Warnf("Capture ends unexpectedly after packet %d", len(packets))
+			break
+		} else if err != nil {
+			return packets, fmt.Errorf("error reading packet %d: %w", len(packets), err)
+		}
+		packets = append(packets, parsePacket(data, ci, opts.Comments, columns))
+	}
+	return packets, nil
+}
+
+// getCommentColumns returns the columns written in packet comments by name
+func getCommentColumns() map[string]*ColumnConfig {
+	columns := map[string]*ColumnConfig{}
+	for _, col := range cfg.Columns {
+		// writePacketData only use the first column of each field
+		if col.Field == "" || toColID(col.Field) != col.ID {
+			continue
+		}
+		columns[toColName(col.ID, 0)] = col
+	}
+	return columns
+}
+
+// parsePacket rebuilds the generic map of a packet from its data and raw record comment, falling back on
+// the Source / Destination / Common comments of files written without it where some values are lossy
+func parsePacket(data []byte, ci gopacket.CaptureInfo, comments []string, columns map[string]*ColumnConfig) config.GenericMap {
+	for _, comment := range comments {
+		if raw, found := strings.CutPrefix(comment, rawCommentTitle); found {
+			genericMap := config.GenericMap{}
+			if err := json.Unmarshal([]byte(raw), &genericMap); err != nil {
+				log.Warnf("Invalid raw record comment: %v", err)
+				break
+			}
+			genericMap["Data"] = base64.StdEncoding.EncodeToString(data)
+			return genericMap
+		}
+	}
+
+	genericMap := config.GenericMap{
+		"Time": float64(ci.Timestamp.Unix()),
+		"Data": base64.StdEncoding.EncodeToString(data),
+	}
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			// skip comment titles and fields without columns
+			name, value, found := strings.Cut(line, ": ")
+			if !found {
+				continue
+			}
+			col, ok := columns[name]
+			if !ok {
+				continue
+			}
+			if v, ok := parseColValue(value, col.ID); ok {
+				genericMap[col.Field] = v
+			}
+		}
+	}
+	return genericMap
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gopacket/gopacket/layers"
+	"github.com/gopacket/gopacket/pcapgo"
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPacketsFile(t *testing.T) {
+	setup(t)
+
+	var packet config.GenericMap
+	err := json.Unmarshal([]byte(sampleFlow), &packet)
+	assert.Nil(t, err)
+	packet["Time"] = float64(1709742328)
+	packet["Data"] = "AAECAwQFBgcICQ=="
+
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	ngw, err := pcapgo.NewNgWriter(f, layers.LinkTypeEthernet)
+	assert.Nil(t, err)
+	data := packet["Data"]
+	writePacketData(ngw, &packet, &data)
+	assert.Nil(t, ngw.Flush())
+	f.Close()
+
+	// raw record comment gives back the captured record
+	packets, err := readPacketsFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, []config.GenericMap{packet}, packets)
+
+	// files written without it are parsed from the displayed comments
+	f, err = os.Open(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	ngr, err := pcapgo.NewNgReader(f, pcapgo.DefaultNgReaderOptions)
+	assert.Nil(t, err)
+	b, ci, opts, err := ngr.ReadPacketDataWithOptions()
+	assert.Nil(t, err)
+	assert.Len(t, opts.Comments, 4)
+	p := parsePacket(b, ci, opts.Comments[:3], getCommentColumns())
+
+	// packet data and time
+	assert.Equal(t, packet["Data"], p["Data"])
+	assert.Equal(t, packet["Time"], p["Time"])
+
+	// plain fields
+	for _, field := range []string{"SrcAddr", "DstAddr", "SrcPort", "DstPort", "SrcK8S_Name", "DstK8S_Namespace",
+		"SrcK8S_Zone", "Packets", "DnsFlagsResponseCode", "Interfaces"} {
+		assert.Equal(t, packet[field], p[field], field)
+	}
+
+	// formatted fields
+	for _, field := range []string{"Proto", "Dscp", "FlowDirection", "IfDirections", "Bytes", "PktDropBytes",
+		"DnsLatencyMs", "TimeFlowRttNs"} {
+		assert.Equal(t, packet[field], p[field], field)
+	}
+
+	// lossy fields
+	assert.NotContains(t, p, "NetworkEvents")
+	assert.NotContains(t, p, "TimeFlowEndMs")
+	v, ok := parseColValue(toColValue(config.GenericMap{"Bytes": float64(123456)}, "Bytes", 0), "Bytes")
+	assert.True(t, ok)
+	assert.NotEqual(t, float64(123456), v)
+
+	// same table rows as live capture
+	for _, id := range []string{"SrcK8S_Name", "Proto", "Bytes", "IfDirections", "TimeFlowRttMs"} {
+		assert.Equal(t, toColValue(packet, id, toColWidth(id)), toColValue(p, id, toColWidth(id)), id)
+	}
+}
+
+func TestParseColValue(t *testing.T) {
+	setup(t)
+
+	v, ok := parseColValue("UDP", "Proto")
+	assert.True(t, ok)
+	assert.Equal(t, float64(17), v)
+
+	v, ok = parseColValue("Egress,Ingress", "IfDirections")
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{float64(1), float64(0)}, v)
+
+	v, ok = parseColValue("eth0,None", "Interfaces")
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{"eth0", ""}, v)
+
+	v, ok = parseColValue("1.5KB", "Bytes")
+	assert.True(t, ok)
+	assert.Equal(t, float64(1500), v)
+
+	_, ok = parseColValue(emptyText, "SrcAddr")
+	assert.False(t, ok)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const displayQueueSize = 1000 // records waiting to be displayed before dropping new ones
+
+// recordQueue hands decoded records to a single worker through a bounded queue,
+// dropping them when the worker can't keep up instead of growing memory
+type recordQueue struct {
+	input   chan config.GenericMap
+	handler func(config.GenericMap)
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+func newRecordQueue(size int, handler func(config.GenericMap)) *recordQueue {
+	return &recordQueue{
+		input:   make(chan config.GenericMap, size),
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+}
+
+// startDisplayQueue runs the worker appending received records to the table
+func (s *CaptureSession) startDisplayQueue() {
+	s.displayQueue = newRecordQueue(displayQueueSize, s.appendFlow)
+	go s.displayQueue.run()
+}
+
+// displayRecord queues a record for display, or displays it immediately when no queue is running,
+// after sending it to the viewers of the streaming API
+func (s *CaptureSession) displayRecord(flow config.GenericMap) {
+	if s.api != nil {
+		s.api.publishRecord(flow)
+	}
+	if s.displayQueue == nil {
+		s.appendFlow(flow)
+		return
+	}
+	s.displayQueue.push(flow)
+}
+
+// push queues a record without blocking, returning false when it was dropped
+func (q *recordQueue) push(flow config.GenericMap) bool {
+	select {
+	case q.input <- flow:
+		return true
+	default:
+		q.dropped.Add(1)
+		return false
+	}
+}
+
+func (q *recordQueue) run() {
+	defer close(q.done)
+	for flow := range q.input {
+		q.handler(flow)
+	}
+}
+
+// close waits for queued records to be handled; no record must be pushed afterwards
+func (q *recordQueue) close() {
+	close(q.input)
+	<-q.done
+}
+
+// getLag returns the number of records waiting to be handled
+func (q *recordQueue) getLag() int {
+	return len(q.input)
+}
+
+func (q *recordQueue) getDropped() int64 {
+	return q.dropped.Load()
+}
+
+// getStatusText shows lagging and dropped records, if any
+func (q *recordQueue) getStatusText() string {
+	text := ""
+	if lag := q.getLag(); lag > cap(q.input)/10 {
+		text += fmt.Sprintf(" Display lag: %d", lag)
+	}
+	if dropped := q.getDropped(); dropped > 0 {
+		text += fmt.Sprintf(" Dropped: %d", dropped)
+	}
+	return text
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordQueue(t *testing.T) {
+	handled := []config.GenericMap{}
+	block := make(chan struct{})
+	q := newRecordQueue(2, func(flow config.GenericMap) {
+		<-block
+		handled = append(handled, flow)
+	})
+
+	// worker not started yet, queue is full after two records
+	assert.True(t, q.push(config.GenericMap{"Bytes": float64(1)}))
+	assert.True(t, q.push(config.GenericMap{"Bytes": float64(2)}))
+	assert.False(t, q.push(config.GenericMap{"Bytes": float64(3)}))
+	assert.Equal(t, 2, q.getLag())
+	assert.Equal(t, int64(1), q.getDropped())
+	assert.Equal(t, " Display lag: 2 Dropped: 1", q.getStatusText())
+
+	// queued records are handled on close
+	go q.run()
+	close(block)
+	q.close()
+	assert.Equal(t, []config.GenericMap{{"Bytes": float64(1)}, {"Bytes": float64(2)}}, handled)
+	assert.Equal(t, " Dropped: 1", q.getStatusText())
+}
+
+func TestAppendFlowOrder(t *testing.T) {
+	setup(t)
+
+	// flows received out of order are kept sorted by end time
+	for _, end := range []float64{3, 1, 2, 3, 0} {
+		session.displayRecord(config.GenericMap{"TimeFlowEndMs": end})
+	}
+	ends := []float64{}
+	for _, flow := range session.lastFlows {
+		ends = append(ends, flow["TimeFlowEndMs"].(float64))
+	}
+	assert.Equal(t, []float64{0, 1, 2, 3, 3}, ends)
+	// flows with the same time keep their arrival order
+	assert.Less(t, session.lastFlows[3]["Index"].(int), session.lastFlows[4]["Index"].(int))
+}
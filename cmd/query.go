@@ -0,0 +1,417 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+type cannedQuery struct {
+	description string
+	sql         string
+	// parameters and their default values, empty ones being mandatory
+	params map[string]string
+}
+
+var (
+	cannedQueries = map[string]cannedQuery{
+		"top-talkers": {
+			description: "Workloads exchanging the most bytes",
+			sql: `SELECT SrcK8S_Namespace, SrcK8S_OwnerName, DstK8S_Namespace, DstK8S_OwnerName,
+				SUM(Bytes) AS Bytes, SUM(Packets) AS Packets, COUNT(*) AS Flows
+				FROM flow
+				GROUP BY SrcK8S_Namespace, SrcK8S_OwnerName, DstK8S_Namespace, DstK8S_OwnerName
+				ORDER BY Bytes DESC LIMIT :limit`,
+			params: map[string]string{"limit": "10"},
+		},
+		"peers": {
+			description: "Pods and addresses that talked to the given ip",
+			sql: `SELECT
+				CASE WHEN SrcAddr = :ip THEN DstAddr ELSE SrcAddr END AS PeerAddr,
+				CASE WHEN SrcAddr = :ip THEN DstK8S_Namespace ELSE SrcK8S_Namespace END AS PeerNamespace,
+				CASE WHEN SrcAddr = :ip THEN DstK8S_Name ELSE SrcK8S_Name END AS PeerName,
+				SUM(Bytes) AS Bytes, SUM(Packets) AS Packets, COUNT(*) AS Flows
+				FROM flow
+				WHERE SrcAddr = :ip OR DstAddr = :ip
+				GROUP BY PeerAddr, PeerNamespace, PeerName
+				ORDER BY Bytes DESC`,
+			params: map[string]string{"ip": ""},
+		},
+		"drops": {
+			description: "Packet drops per cause and workloads",
+			sql: `SELECT PktDropLatestDropCause, PktDropLatestState,
+				SrcK8S_Namespace, SrcK8S_OwnerName, DstK8S_Namespace, DstK8S_OwnerName,
+				SUM(PktDropPackets) AS DropPackets, SUM(PktDropBytes) AS DropBytes
+				FROM flow
+				WHERE PktDropPackets > 0
+				GROUP BY PktDropLatestDropCause, PktDropLatestState, SrcK8S_Namespace, SrcK8S_OwnerName, DstK8S_Namespace, DstK8S_OwnerName
+				ORDER BY DropPackets DESC LIMIT :limit`,
+			params: map[string]string{"limit": "10"},
+		},
+		"dns-errors": {
+			description: "DNS queries ending with an error response code",
+			sql: `SELECT DnsName, DnsFlagsResponseCode, SrcK8S_Namespace, SrcK8S_Name,
+				COUNT(*) AS Count, MAX(DnsLatencyMs) AS MaxLatencyMs
+				FROM flow
+				WHERE DnsId IS NOT NULL AND ((DnsFlagsResponseCode IS NOT NULL AND DnsFlagsResponseCode != 'NoError') OR DnsErrno > 0)
+				GROUP BY DnsName, DnsFlagsResponseCode, SrcK8S_Namespace, SrcK8S_Name
+				ORDER BY Count DESC LIMIT :limit`,
+			params: map[string]string{"limit": "10"},
+		},
+		"rtt": {
+			description: "Workloads with the highest TCP round trip time",
+			sql: `SELECT SrcK8S_Namespace, SrcK8S_Name, DstK8S_Namespace, DstK8S_Name,
+				MAX(TimeFlowRttNs) AS MaxRttNs, CAST(AVG(TimeFlowRttNs) AS INTEGER) AS AvgRttNs, COUNT(*) AS Flows
+				FROM flow
+				WHERE TimeFlowRttNs > 0
+				GROUP BY SrcK8S_Namespace, SrcK8S_Name, DstK8S_Namespace, DstK8S_Name
+				ORDER BY MaxRttNs DESC LIMIT :limit`,
+			params: map[string]string{"limit": "10"},
+		},
+	}
+
+	querySQL        string
+	queryName       string
+	queryParams     []string
+	queryFormat     = "table"
+	queryCalculated []string
+
+	queryCmd = &cobra.Command{
+		Use:   "query <file.db>...",
+		Short: "Query flow capture databases",
+		Long:  "Run SQL or canned queries against one or more flow capture databases\n\nCanned queries:\n" + getCannedQueriesText(),
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runQuery,
+	}
+)
+
+func runQuery(_ *cobra.Command, args []string) {
+	cols, rows, err := queryFlowsFiles(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cols, rows, err = addCalculatedColumns(cols, rows, queryCalculated)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = writeRecords(os.Stdout, queryFormat, cols, rows); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func getCannedQueriesText() string {
+	names := make([]string, 0, len(cannedQueries))
+	for name := range cannedQueries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		q := cannedQueries[name]
+		params := []string{}
+		for k, v := range q.params {
+			if v == "" {
+				params = append(params, k)
+			} else {
+				params = append(params, fmt.Sprintf("%s=%s", k, v))
+			}
+		}
+		sort.Strings(params)
+		sb.WriteString(fmt.Sprintf("  %-12s %s (params: %s)\n", name, q.description, strings.Join(params, ", ")))
+	}
+	return sb.String()
+}
+
+// getQuery returns the sql to run and its named parameters according to flags
+func getQuery() (string, []interface{}, error) {
+	params := map[string]string{}
+	query := querySQL
+	if queryName != "" {
+		if querySQL != "" {
+			return "", nil, fmt.Errorf("--sql and --name can't be used together")
+		}
+		canned, ok := cannedQueries[queryName]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown query %s", queryName)
+		}
+		query = canned.sql
+		for k, v := range canned.params {
+			params[k] = v
+		}
+	} else if query == "" {
+		return "", nil, fmt.Errorf("either --sql or --name must be provided")
+	}
+
+	for _, p := range queryParams {
+		k, v, found := strings.Cut(p, "=")
+		if !found {
+			return "", nil, fmt.Errorf("invalid param %s, expected key=value", p)
+		}
+		params[k] = v
+	}
+
+	args := []interface{}{}
+	for k, v := range params {
+		if v == "" {
+			return "", nil, fmt.Errorf("missing param %s for query %s", k, queryName)
+		}
+		args = append(args, sqlNamed(k, v))
+	}
+	return query, args, nil
+}
+
+// queryFlowsFiles runs the query on each file, adding a File column when querying multiple files
+// rows are merged by column name since schemas differ between capture configs and versions
+func queryFlowsFiles(files []string) ([]string, [][]interface{}, error) {
+	query, args, err := getQuery()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cols := []string{"File"}
+	// indexes of each column name, as a query can return the same name several times
+	colIndexes := map[string][]int{}
+	rows := [][]interface{}{}
+	for _, file := range files {
+		fileCols, fileRows, err := QueryFlowsDB(query, file, args...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(files) == 1 {
+			return fileCols, fileRows, nil
+		}
+
+		indexes := make([]int, len(fileCols))
+		occurrences := map[string]int{}
+		for i, col := range fileCols {
+			n := occurrences[col]
+			occurrences[col]++
+			if n == len(colIndexes[col]) {
+				colIndexes[col] = append(colIndexes[col], len(cols))
+				cols = append(cols, col)
+			}
+			indexes[i] = colIndexes[col][n]
+		}
+		for _, row := range fileRows {
+			merged := make([]interface{}, len(cols))
+			merged[0] = filepath.Base(file)
+			for i, v := range row {
+				merged[indexes[i]] = v
+			}
+			rows = append(rows, merged)
+		}
+	}
+
+	// pad rows of previous files with columns added afterwards
+	for i, row := range rows {
+		if len(row) < len(cols) {
+			rows[i] = append(row, make([]interface{}, len(cols)-len(row))...)
+		}
+	}
+	return cols, rows, nil
+}
+
+// addCalculatedColumns appends calculated columns such as SrcK8S_Object, evaluated from the columns of each row
+func addCalculatedColumns(cols []string, rows [][]interface{}, ids []string) ([]string, [][]interface{}, error) {
+	for _, id := range ids {
+		if toCalculated(id) == "" {
+			return nil, nil, fmt.Errorf("%s is not a calculated column", id)
+		}
+	}
+	if len(ids) == 0 {
+		return cols, rows, nil
+	}
+
+	for i, row := range rows {
+		// decode numbers as json decoding does for the expressions
+		flow := config.GenericMap{}
+		for j, col := range cols {
+			switch v := row[j].(type) {
+			case nil:
+			case int64:
+				flow[col] = float64(v)
+			default:
+				flow[col] = v
+			}
+		}
+		for _, id := range ids {
+			rows[i] = append(rows[i], evalColumn(flow, id))
+		}
+	}
+	return append(cols, ids...), rows, nil
+}
+
+// writeRecords writes rows in table, json, csv, ndjson or markdown format
+func writeRecords(w io.Writer, format string, cols []string, rows [][]interface{}) error {
+	switch format {
+	case "table":
+		return writeTableRecords(w, cols, rows)
+	case "json":
+		return writeJSONRecords(w, cols, rows)
+	case "ndjson":
+		return writeNDJSONRecords(w, cols, rows)
+	case "csv":
+		return writeCSVRecords(w, cols, rows)
+	case "markdown":
+		return writeMarkdownRecords(w, cols, rows)
+	default:
+		return fmt.Errorf("unknown format %s, expected table, json, csv, ndjson or markdown", format)
+	}
+}
+
+func recordValueText(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func writeTableRecords(w io.Writer, cols []string, rows [][]interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	for _, row := range rows {
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = recordValueText(v)
+			if values[i] == "" {
+				values[i] = emptyText
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// marshalRecord encodes a row as a json object, keeping columns order
+func marshalRecord(cols []string, row []interface{}) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, col := range cols {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		k, err := json.Marshal(col)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, err
+		}
+		sb.Write(k)
+		sb.WriteString(":")
+		sb.Write(v)
+	}
+	sb.WriteString("}")
+	return []byte(sb.String()), nil
+}
+
+func writeJSONRecords(w io.Writer, cols []string, rows [][]interface{}) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		b, err := marshalRecord(cols, row)
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if i == 0 {
+			sep = "\n"
+		}
+		if _, err = io.WriteString(w, sep+string(b)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n]\n")
+	return err
+}
+
+func writeNDJSONRecords(w io.Writer, cols []string, rows [][]interface{}) error {
+	for _, row := range rows {
+		b, err := marshalRecord(cols, row)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSVRecords(w io.Writer, cols []string, rows [][]interface{}) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return writeCSVRows(w, rows)
+}
+
+// writeCSVRows writes rows without header, used to append to a previous csv output
+func writeCSVRows(w io.Writer, rows [][]interface{}) error {
+	cw := csv.NewWriter(w)
+	for _, row := range rows {
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = recordValueText(v)
+		}
+		if err := cw.Write(values); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// markdownReplacer escapes the characters breaking markdown table cells
+var markdownReplacer = strings.NewReplacer("|", "\\|", "\n", " ")
+
+func writeMarkdownRecords(w io.Writer, cols []string, rows [][]interface{}) error {
+	separators := make([]string, len(cols))
+	for i := range cols {
+		separators[i] = "---"
+	}
+	lines := []string{toMarkdownRow(cols), "| " + strings.Join(separators, " | ") + " |"}
+	for _, row := range rows {
+		values := make([]string, len(row))
+		for i, v := range row {
+			values[i] = recordValueText(v)
+		}
+		lines = append(lines, toMarkdownRow(values))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func toMarkdownRow(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = markdownReplacer.Replace(v)
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
+
+// sqlNamed binds numeric parameters as numbers so they can be used in LIMIT or compared to numeric columns
+func sqlNamed(name, value string) interface{} {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return sql.Named(name, i)
+	}
+	return sql.Named(name, value)
+}
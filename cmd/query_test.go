@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createQueryTestDB(t *testing.T, name string, flows ...string) string {
+	path := filepath.Join(t.TempDir(), name)
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Nil(t, createFlowsDBTable(db))
+	for _, flow := range flows {
+		assert.Nil(t, insertFlowToDB(db, []byte(flow)))
+	}
+	return path
+}
+
+func resetQueryFlags() {
+	querySQL = ""
+	queryName = ""
+	queryParams = []string{}
+	queryCalculated = []string{}
+}
+
+func TestQueryPeers(t *testing.T) {
+	setup(t)
+	defer resetQueryFlags()
+
+	first := createQueryTestDB(t, "first.db",
+		`{"SrcAddr":"10.0.3.4","DstAddr":"10.128.0.1","DstK8S_Namespace":"ns","DstK8S_Name":"pod-a","Bytes":100,"Packets":1}`,
+		`{"SrcAddr":"10.128.0.2","DstAddr":"10.0.3.4","SrcK8S_Namespace":"ns","SrcK8S_Name":"pod-b","Bytes":50,"Packets":1}`,
+		`{"SrcAddr":"10.128.0.9","DstAddr":"10.128.0.1","Bytes":10,"Packets":1}`)
+	second := createQueryTestDB(t, "second.db",
+		`{"SrcAddr":"10.128.0.1","DstAddr":"10.0.3.4","SrcK8S_Namespace":"ns","SrcK8S_Name":"pod-a","Bytes":20,"Packets":2}`)
+
+	// missing mandatory param
+	queryName = "peers"
+	_, _, err := queryFlowsFiles([]string{first})
+	assert.NotNil(t, err)
+
+	queryParams = []string{"ip=10.0.3.4"}
+	cols, rows, err := queryFlowsFiles([]string{first})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"PeerAddr", "PeerNamespace", "PeerName", "Bytes", "Packets", "Flows"}, cols)
+	assert.Equal(t, [][]interface{}{
+		{"10.128.0.1", "ns", "pod-a", int64(100), int64(1), int64(1)},
+		{"10.128.0.2", "ns", "pod-b", int64(50), int64(1), int64(1)},
+	}, rows)
+
+	// multiple files add a file column
+	cols, rows, err = queryFlowsFiles([]string{first, second})
+	assert.Nil(t, err)
+	assert.Equal(t, "File", cols[0])
+	assert.Equal(t, 3, len(rows))
+	assert.Equal(t, []interface{}{"second.db", "10.128.0.1", "ns", "pod-a", int64(20), int64(2), int64(1)}, rows[2])
+
+	// missing file is not created
+	_, _, err = queryFlowsFiles([]string{filepath.Join(t.TempDir(), "missing.db")})
+	assert.NotNil(t, err)
+}
+
+func TestQuerySQL(t *testing.T) {
+	setup(t)
+	defer resetQueryFlags()
+
+	db := createQueryTestDB(t, "capture.db",
+		`{"Bytes":1,"Proto":6}`, `{"Bytes":2,"Proto":17}`, `{"Bytes":3,"Proto":17}`)
+
+	querySQL = "SELECT Proto, SUM(Bytes) AS Bytes FROM flow WHERE Proto = :proto GROUP BY Proto"
+	queryParams = []string{"proto=17"}
+	cols, rows, err := queryFlowsFiles([]string{db})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Proto", "Bytes"}, cols)
+	assert.Equal(t, [][]interface{}{{int64(17), int64(5)}}, rows)
+
+	// databases are opened read only
+	querySQL = "DELETE FROM flow"
+	queryParams = []string{}
+	_, _, err = queryFlowsFiles([]string{db})
+	assert.NotNil(t, err)
+
+	// sql and name are exclusive
+	querySQL = "SELECT 1"
+	queryName = "rtt"
+	_, _, err = queryFlowsFiles([]string{db})
+	assert.NotNil(t, err)
+}
+
+func TestQueryCalculated(t *testing.T) {
+	setup(t)
+	defer resetQueryFlags()
+
+	db := createQueryTestDB(t, "capture.db",
+		`{"SrcAddr":"10.0.0.1","SrcPort":443,"SrcK8S_Type":"Pod","SrcK8S_Namespace":"ns","SrcK8S_Name":"pod-a","TimeFlowStartMs":1000,"TimeFlowEndMs":1043}`,
+		`{"SrcAddr":"10.0.0.2","SrcPort":80}`)
+
+	querySQL = "SELECT * FROM flow ORDER BY SrcAddr"
+	cols, rows, err := queryFlowsFiles([]string{db})
+	assert.Nil(t, err)
+	cols, rows, err = addCalculatedColumns(cols, rows, []string{"SrcK8S_Object", "FlowDuration"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"SrcK8S_Object", "FlowDuration"}, cols[len(cols)-2:])
+	assert.Equal(t, []interface{}{"ns.pod-a", float64(43)}, rows[0][len(cols)-2:])
+	// ip and port fallback, missing values being empty
+	assert.Equal(t, []interface{}{"10.0.0.2:80", nil}, rows[1][len(cols)-2:])
+
+	// only calculated columns can be added
+	_, _, err = addCalculatedColumns(cols, rows, []string{"SrcAddr"})
+	assert.EqualError(t, err, "SrcAddr is not a calculated column")
+}
+
+func TestQueryFilesColumns(t *testing.T) {
+	setup(t)
+	defer resetQueryFlags()
+
+	// older captures may miss columns of newer ones
+	older := filepath.Join(t.TempDir(), "older.db")
+	db, err := sql.Open("sqlite3", older)
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE flow (Proto INTEGER, Bytes INTEGER); INSERT INTO flow VALUES (6, 1)")
+	assert.Nil(t, err)
+	db.Close()
+	newer := createQueryTestDB(t, "newer.db", `{"Bytes":2,"Proto":17,"Packets":3}`)
+
+	querySQL = "SELECT * FROM flow WHERE Bytes < 3"
+	cols, rows, err := queryFlowsFiles([]string{older, newer})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"File", "Proto", "Bytes"}, cols[:3])
+	assert.Len(t, rows, 2)
+	for _, row := range rows {
+		assert.Len(t, row, len(cols))
+	}
+	packets := slices.Index(cols, "Packets")
+	assert.Equal(t, []interface{}{"older.db", int64(6), int64(1)}, rows[0][:3])
+	assert.Nil(t, rows[0][packets])
+	assert.Equal(t, []interface{}{"newer.db", int64(17), int64(2)}, rows[1][:3])
+	assert.Equal(t, int64(3), rows[1][packets])
+
+	// repeated column names stay distinct
+	querySQL = "SELECT Bytes, Proto AS Bytes FROM flow"
+	cols, rows, err = queryFlowsFiles([]string{older, newer})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"File", "Bytes", "Bytes"}, cols)
+	assert.Equal(t, [][]interface{}{{"older.db", int64(1), int64(6)}, {"newer.db", int64(2), int64(17)}}, rows)
+}
+
+func TestWriteRecords(t *testing.T) {
+	cols := []string{"Name", "Bytes"}
+	rows := [][]interface{}{{"pod-a", int64(10)}, {nil, int64(2)}}
+
+	var buf bytes.Buffer
+	assert.Nil(t, writeRecords(&buf, "table", cols, rows))
+	assert.Equal(t, "Name   Bytes\npod-a  10\nn/a    2\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeRecords(&buf, "json", cols, rows))
+	assert.Equal(t, "[\n{\"Name\":\"pod-a\",\"Bytes\":10},\n{\"Name\":null,\"Bytes\":2}\n]\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeRecords(&buf, "ndjson", cols, rows))
+	assert.Equal(t, "{\"Name\":\"pod-a\",\"Bytes\":10}\n{\"Name\":null,\"Bytes\":2}\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeRecords(&buf, "csv", cols, rows))
+	assert.Equal(t, "Name,Bytes\npod-a,10\n,2\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(t, writeRecords(&buf, "markdown", cols, [][]interface{}{{"a|b", int64(10)}, {nil, int64(2)}}))
+	assert.Equal(t, "| Name | Bytes |\n| --- | --- |\n| a\\|b | 10 |\n|  | 2 |\n", buf.String())
+
+	assert.NotNil(t, writeRecords(&buf, "xml", cols, rows))
+}
@@ -3,12 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"os/signal"
 	"strings"
-	"sync"
-	"syscall"
 	"time"
 
 	"github.com/netobserv/network-observability-cli/internal/pkg/kubernetes"
@@ -35,11 +33,6 @@ var (
 	maxBytes  int64
 
 	currentTime = time.Now
-	startupTime = currentTime()
-
-	mutex = sync.Mutex{}
-
-	totalBytes = int64(0)
 
 	rootCmd = &cobra.Command{
 		Use:   "network-observability-cli",
@@ -49,13 +42,8 @@ var (
 		},
 	}
 
-	capture          = Flow
-	collectorStarted = false
-	captureStarted   = false
-	captureEnded     = false
-	stopReceived     = false
-	useMocks         = false
-	isBackground     = false
+	useMocks     = false
+	isBackground = false
 )
 
 // Execute executes the root command.
@@ -75,24 +63,92 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "netobserv-cli", "Namespace where agent pods are running")
 	rootCmd.PersistentFlags().BoolVarP(&useMocks, "mock", "", false, "Use mock")
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGTERM)
-	go func() {
-		<-c
-		log.Info("Received SIGTERM; cleaning up...")
-		stopReceived = true
-
-		os.Exit(0)
-	}()
-
 	// flow
+	flowCmd.Flags().IntVarP(&dbBatchSize, "db-batch-size", "", defaultDBBatchSize, "Maximum flows written to the database per transaction")
+	flowCmd.Flags().DurationVarP(&dbFlushInterval, "db-flush-interval", "", defaultDBFlushInterval, "Maximum time before pending flows are written to the database")
+	flowCmd.Flags().StringVarP(&outputFormat, "output-format", "", jsonOutput, "Output file format: json or ndjson")
+	flowCmd.Flags().StringSliceVarP(&sinkNames, "sink", "", []string{}, "Comma separated outputs among file, db and stdout, file and db if empty")
+	flowCmd.Flags().StringSliceVarP(&stdoutFields, "stdout-fields", "", []string{}, "Comma separated fields or calculated columns of the records written by the stdout sink, all fields if empty")
+	flowCmd.Flags().StringVarP(&stdoutTemplate, "stdout-template", "", "", "Go template formatting each record written by the stdout sink, such as '{{.SrcAddr}} {{.Bytes}}'")
+	flowCmd.Flags().StringVarP(&dedupMode, "dedup", "", noDedup, "Flows deduplication: none, mark duplicates or merge them in canonical flows")
+	flowCmd.Flags().DurationVarP(&dedupWindow, "dedup-window", "", defaultDedupWindow, "Time flows are held to merge the observations of the same traffic")
+	flowCmd.Flags().Int64VarP(&rotateSize, "rotate-size", "", 0, "Rotate output files after this amount of bytes, ignoring max bytes and time")
+	flowCmd.Flags().DurationVarP(&rotateTime, "rotate-time", "", 0, "Rotate output files after this duration, ignoring max bytes and time")
+	flowCmd.Flags().IntVarP(&rotateCount, "rotate-count", "", 0, "Number of rotated output files to keep, 0 to keep all")
+	flowCmd.Flags().StringVarP(&compression, "compress", "", noCompression, "Output files compression: none, gzip or zstd")
+	flowCmd.Flags().BoolVarP(&countCompressedSize, "compressed-size", "", false, "Count compressed bytes instead of uncompressed ones for max bytes and rotation")
+	flowCmd.Flags().BoolVarP(&anonymizeEnabled, "anonymize", "", false, "Anonymize addresses and names of captured flows")
+	flowCmd.Flags().StringVarP(&anonymizeKey, "anonymize-key", "", "", "Anonymization key giving the same values across captures, random if empty")
+	flowCmd.Flags().StringVarP(&triggerExpr, "trigger", "", "", "Only write records around the ones matching this filter, such as PktDropPackets>0")
+	flowCmd.Flags().DurationVarP(&triggerPre, "trigger-pre", "", defaultTriggerPre, "Duration of records kept in memory and written before a trigger match")
+	flowCmd.Flags().Int64VarP(&triggerPreSize, "trigger-pre-size", "", defaultTriggerPreSize, "Maximum bytes kept in memory before a trigger match, 0 for no limit")
+	flowCmd.Flags().DurationVarP(&triggerPost, "trigger-post", "", defaultTriggerPost, "Duration of records written after a trigger match")
+	flowCmd.Flags().BoolVarP(&triggerStop, "trigger-stop", "", false, "End the capture once the trigger window is written")
+	flowCmd.Flags().BoolVarP(&batchEnabled, "batch", "", false, "Print the table periodically instead of displaying it, without terminal")
+	flowCmd.Flags().DurationVarP(&batchInterval, "batch-interval", "", defaultBatchInterval, "Time between two batch tables")
+	flowCmd.Flags().IntVarP(&batchIterations, "batch-iterations", "", 0, "Number of batch tables printed before ending the capture, 0 for no limit")
+	flowCmd.Flags().StringSliceVarP(&batchColumns, "batch-columns", "", []string{}, "Comma separated column ids of batch tables, default display columns if empty")
+	flowCmd.Flags().StringVarP(&batchFormat, "batch-format", "", defaultBatchFormat, "Batch tables format: table, csv, json (one array per table), ndjson (one object per row) or markdown")
+	flowCmd.Flags().StringVarP(&batchOutput, "batch-output", "", "", "File receiving batch tables, relative to the output directory, stdout if empty")
+	flowCmd.Flags().IntVarP(&apiPort, "api-port", "", 0, "Loopback port of the streaming API used by attach, such as 9998, disabled if 0")
 	rootCmd.AddCommand(flowCmd)
 
 	// packet
+	pktCmd.Flags().StringSliceVarP(&sinkNames, "sink", "", []string{}, "Comma separated outputs among pcapng and stdout, pcapng if empty")
+	pktCmd.Flags().StringSliceVarP(&stdoutFields, "stdout-fields", "", []string{}, "Comma separated fields or calculated columns of the records written by the stdout sink, all fields if empty")
+	pktCmd.Flags().StringVarP(&stdoutTemplate, "stdout-template", "", "", "Go template formatting each record written by the stdout sink, such as '{{.SrcAddr}} {{.Bytes}}'")
+	pktCmd.Flags().Int64VarP(&rotateSize, "rotate-size", "", 0, "Rotate output files after this amount of bytes, ignoring max bytes and time")
+	pktCmd.Flags().DurationVarP(&rotateTime, "rotate-time", "", 0, "Rotate output files after this duration, ignoring max bytes and time")
+	pktCmd.Flags().IntVarP(&rotateCount, "rotate-count", "", 0, "Number of rotated output files to keep, 0 to keep all")
+	pktCmd.Flags().StringVarP(&compression, "compress", "", noCompression, "Output files compression: none, gzip or zstd")
+	pktCmd.Flags().BoolVarP(&countCompressedSize, "compressed-size", "", false, "Count compressed bytes instead of uncompressed ones for max bytes and rotation")
+	pktCmd.Flags().BoolVarP(&anonymizeEnabled, "anonymize", "", false, "Anonymize addresses and names of captured packets and truncate their payload")
+	pktCmd.Flags().StringVarP(&anonymizeKey, "anonymize-key", "", "", "Anonymization key giving the same values across captures, random if empty")
+	pktCmd.Flags().StringVarP(&triggerExpr, "trigger", "", "", "Only write records around the ones matching this filter, such as PktDropPackets>0")
+	pktCmd.Flags().DurationVarP(&triggerPre, "trigger-pre", "", defaultTriggerPre, "Duration of records kept in memory and written before a trigger match")
+	pktCmd.Flags().Int64VarP(&triggerPreSize, "trigger-pre-size", "", defaultTriggerPreSize, "Maximum bytes kept in memory before a trigger match, 0 for no limit")
+	pktCmd.Flags().DurationVarP(&triggerPost, "trigger-post", "", defaultTriggerPost, "Duration of records written after a trigger match")
+	pktCmd.Flags().BoolVarP(&triggerStop, "trigger-stop", "", false, "End the capture once the trigger window is written")
+	pktCmd.Flags().BoolVarP(&batchEnabled, "batch", "", false, "Print the table periodically instead of displaying it, without terminal")
+	pktCmd.Flags().DurationVarP(&batchInterval, "batch-interval", "", defaultBatchInterval, "Time between two batch tables")
+	pktCmd.Flags().IntVarP(&batchIterations, "batch-iterations", "", 0, "Number of batch tables printed before ending the capture, 0 for no limit")
+	pktCmd.Flags().StringSliceVarP(&batchColumns, "batch-columns", "", []string{}, "Comma separated column ids of batch tables, default display columns if empty")
+	pktCmd.Flags().StringVarP(&batchFormat, "batch-format", "", defaultBatchFormat, "Batch tables format: table, csv, json (one array per table), ndjson (one object per row) or markdown")
+	pktCmd.Flags().StringVarP(&batchOutput, "batch-output", "", "", "File receiving batch tables, relative to the output directory, stdout if empty")
+	pktCmd.Flags().IntVarP(&apiPort, "api-port", "", 0, "Loopback port of the streaming API used by attach, such as 9998, disabled if 0")
 	rootCmd.AddCommand(pktCmd)
 
 	// metrics
+	metricCmd.Flags().IntVarP(&apiPort, "api-port", "", 0, "Loopback port of the streaming API used by attach, such as 9998, disabled if 0")
 	rootCmd.AddCommand(metricCmd)
+
+	// attach
+	attachCmd.Flags().StringVarP(&attachFilter, "filter", "", "", "Only stream records matching this filter, such as PktDropPackets>0")
+	rootCmd.AddCommand(attachCmd)
+
+	// replay
+	replayFlowsCmd.Flags().Float64VarP(&replaySpeed, "speed", "", 1, "Playback speed multiplier, 0 to replay as fast as possible")
+	rootCmd.AddCommand(replayFlowsCmd)
+	replayPacketsCmd.Flags().Float64VarP(&replaySpeed, "speed", "", 1, "Playback speed multiplier, 0 to replay as fast as possible")
+	rootCmd.AddCommand(replayPacketsCmd)
+
+	// query
+	queryCmd.Flags().StringVarP(&querySQL, "sql", "", "", "SQL query to run on the flow table")
+	queryCmd.Flags().StringVarP(&queryName, "name", "", "", "Canned query name")
+	queryCmd.Flags().StringArrayVarP(&queryParams, "param", "p", []string{}, "Query parameter as key=value, used as :key in SQL")
+	queryCmd.Flags().StringVarP(&queryFormat, "format", "o", "table", "Output format: table, json, csv, ndjson or markdown")
+	queryCmd.Flags().StringSliceVarP(&queryCalculated, "calculated", "", []string{}, "Comma separated calculated columns appended to each row, such as SrcK8S_Object,DstK8S_Object")
+	rootCmd.AddCommand(queryCmd)
+
+	// anonymize
+	anonymizeCmd.Flags().StringVarP(&anonymizeKey, "key", "", "", "Anonymization key giving the same values across captures, random if empty")
+	rootCmd.AddCommand(anonymizeCmd)
+
+	// policies
+	suggestPoliciesCmd.Flags().StringVarP(&policySelectorLabel, "selector-label", "", policySelectorLabel, "Pod label set to the owner name, used to select workloads")
+	suggestPoliciesCmd.Flags().BoolVarP(&policyAdmin, "admin", "", false, "Also generate AdminNetworkPolicies")
+	suggestPoliciesCmd.Flags().IntVarP(&policyAdminPriority, "admin-priority", "", policyAdminPriority, "Priority of the first AdminNetworkPolicy, incremented for each workload")
+	rootCmd.AddCommand(suggestPoliciesCmd)
 }
 
 func onInit() {
@@ -104,7 +160,7 @@ func onInit() {
 		log.Fatalf("can't load config from yaml: %v", err)
 	}
 
-	printBanner()
+	printBannerTo(os.Stderr)
 
 	log.Infof("Log level: %s\nOption(s): %s", logLevel, options)
 	if strings.Contains(options, "background") && !strings.Contains(options, "background=false") {
@@ -112,15 +168,15 @@ func onInit() {
 		log.Infof("Running in background mode")
 	}
 	showKernelVersion()
-
-	if useMocks {
-		log.Info("Using mocks...")
-		go mockForever()
-	}
 }
 
 func printBanner() {
-	fmt.Print(`
+	printBannerTo(os.Stdout)
+}
+
+// printBannerTo allows printing the banner on stderr to keep stdout for command output
+func printBannerTo(w io.Writer) {
+	fmt.Fprint(w, `
 ------------------------------------------------------------------------
          _  _     _       _                       ___ _    ___
         | \| |___| |_ ___| |__ ___ ___ _ ___ __  / __| |  |_ _|
@@ -143,52 +199,38 @@ func showKernelVersion() {
 	}
 }
 
-func onLimitReached() bool {
-	shouldExit := false
-	if !captureEnded {
-		captureEnded = true
-		log.Trace("Capture ended")
-		if app != nil && errAdvancedDisplay == nil {
-			app.Stop()
-		}
-		if isBackground {
-			err := kubernetes.DeleteDaemonSet(context.Background(), namespace)
-			if err != nil {
-				log.Error(err)
-			}
-			fmt.Print(`Thank you for using...`)
-			printBanner()
-
-			if capture == "Metric" {
-				fmt.Print(`
+// printCaptureEnd cleans the agents of a background capture and explains how to get its outputs
+func printCaptureEnd(c captureType) {
+	err := kubernetes.DeleteDaemonSet(context.Background(), namespace)
+	if err != nil {
+		log.Error(err)
+	}
+	fmt.Print(`Thank you for using...`)
+	printBanner()
+
+	if c == Metric {
+		fmt.Print(`
 
   - Open NetObserv / On Demand dashboard to see generated metrics
 
 	- Once finished, remove everything using 'oc netobserv cleanup'
 
                                                       See you soon !
-																											
-																											
-		`)
-			} else {
-				fmt.Print(`
+																									
+																									
+`)
+	} else {
+		fmt.Print(`
 
 	- Download the generated output using 'oc netobserv copy' command
 
 	- Once finished, clean the collector pod using 'oc netobserv cleanup'
 
                                                       See you soon !
-																											
-																											
-		`)
-			}
-
-		} else {
-			shouldExit = true
-		}
+																									
+																									
+`)
 	}
-
-	return shouldExit
 }
 
 // Create output file, preventing path traversal
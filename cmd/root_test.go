@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,35 +68,42 @@ const (
 
 var (
 	originalTime  = currentTime
-	simulatedTime = startupTime
+	simulatedTime = session.startupTime
 )
 
 func TestDefaultArguments(t *testing.T) {
 	assert.Equal(t, "info", logLevel)
 	assert.Equal(t, 9999, port)
 	assert.Empty(t, options)
+	// streaming API is opt-in
+	for _, c := range []*cobra.Command{flowCmd, pktCmd, metricCmd} {
+		assert.Equal(t, "0", c.Flags().Lookup("api-port").DefValue, c.Name())
+	}
 }
 
 func setup(t *testing.T) {
-	// reset time to startup time
+	// reset time first so the session doesn't start at a time mocked by a previous test
 	resetTime()
 
-	// clear filters and previous flows
-	regexes = []string{}
-	lastFlows = []config.GenericMap{}
-	showCount = defaultFlowShowCount
+	// start a new session with empty flows and table, simulated time starting with it
+	session = newCaptureSession(t.Context(), Flow)
+	simulatedTime = session.startupTime
 
-	// clear previous table content
-	tableData = &TableData{
-		cols:  []string{},
-		flows: []config.GenericMap{},
-	}
+	// clear filters
+	liveFilters = []string{}
 
 	// load config
 	err := LoadConfig()
 	assert.Equal(t, nil, err)
 }
 
+// parseTestFlow decodes a json flow as the collector does before displaying it
+func parseTestFlow(t *testing.T, value []byte) config.GenericMap {
+	flow := config.GenericMap{}
+	assert.Nil(t, json.Unmarshal(value, &flow))
+	return flow
+}
+
 func resetTime() {
 	// set timezone to Paris time for all tests
 	loc, err := time.LoadLocation("Europe/Paris")
@@ -105,7 +114,7 @@ func resetTime() {
 
 	// reset all timers
 	currentTime = originalTime
-	simulatedTime = startupTime
+	simulatedTime = session.startupTime
 }
 
 func tickTimeAndAddBytes() {
@@ -114,5 +123,5 @@ func tickTimeAndAddBytes() {
 		return simulatedTime
 	}
 
-	totalBytes++
+	session.totalBytes.Add(1)
 }
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	rotateSize  int64
+	rotateTime  time.Duration
+	rotateCount int
+)
+
+// outputRotation names the output files of a continuous capture, switching to a new generation
+// once the current one reaches rotateSize or rotateTime and keeping only the last rotateCount ones
+type outputRotation struct {
+	dir      string
+	base     string
+	index    int
+	bytes    int64
+	openedAt time.Time
+}
+
+func isRotationEnabled() bool {
+	return rotateSize > 0 || rotateTime > 0
+}
+
+func newOutputRotation(kind, base string) *outputRotation {
+	return &outputRotation{
+		dir:      filepath.Join("output", kind),
+		base:     base,
+		openedAt: currentTime(),
+	}
+}
+
+// getName returns the file name without extension of the current generation
+func (r *outputRotation) getName() string {
+	if !isRotationEnabled() {
+		return r.base
+	}
+	return fmt.Sprintf("%s_%04d", r.base, r.index)
+}
+
+// add counts bytes written and returns true when the current generation must be rotated
+func (r *outputRotation) add(bytes int64) bool {
+	if !isRotationEnabled() {
+		return false
+	}
+	r.bytes += bytes
+	if rotateSize > 0 && r.bytes >= rotateSize {
+		return true
+	}
+	return rotateTime > 0 && currentTime().Sub(r.openedAt) >= rotateTime
+}
+
+// next moves to a new generation, removing the files of the ones exceeding rotateCount
+func (r *outputRotation) next() {
+	r.index++
+	r.bytes = 0
+	r.openedAt = currentTime()
+
+	if rotateCount <= 0 {
+		return
+	}
+	oldest := r.index - rotateCount
+	if oldest < 0 {
+		return
+	}
+	name := fmt.Sprintf("%s_%04d", r.base, oldest)
+	files, err := filepath.Glob(filepath.Join(r.dir, name+".*"))
+	if err != nil {
+		log.Errorf("Can't list files of %s: %v", name, err)
+		return
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			log.Errorf("Can't remove %s: %v", file, err)
+		} else {
+			log.Debugf("Removed %s", file)
+		}
+	}
+}
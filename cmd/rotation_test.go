@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listRotationFiles(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join("output", "rotation", "*"))
+	assert.Nil(t, err)
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	return files
+}
+
+func TestRotationDisabled(t *testing.T) {
+	r := newOutputRotation("rotation", "capture")
+	assert.Equal(t, "capture", r.getName())
+	assert.False(t, r.add(1000000000))
+}
+
+func TestRotationSizeAndCount(t *testing.T) {
+	rotateSize = 10
+	rotateCount = 2
+	defer func() {
+		rotateSize = 0
+		rotateCount = 0
+		os.RemoveAll(filepath.Join("output", "rotation"))
+	}()
+
+	r := newOutputRotation("rotation", "capture")
+	for range 4 {
+		for _, ext := range []string{".json", ".db"} {
+			f, err := createOutputFile("rotation", r.getName()+ext)
+			assert.Nil(t, err)
+			f.Close()
+		}
+		assert.False(t, r.add(6))
+		assert.True(t, r.add(6))
+		r.next()
+	}
+
+	// only the last generations are kept, including the current one
+	assert.Equal(t, "capture_0004", r.getName())
+	assert.Equal(t, []string{"capture_0003.db", "capture_0003.json"}, listRotationFiles(t))
+}
+
+func TestRotationTime(t *testing.T) {
+	resetTime()
+	rotateTime = 2 * time.Second
+	defer func() { rotateTime = 0 }()
+
+	tickTimeAndAddBytes()
+	r := newOutputRotation("rotation", "capture")
+	assert.False(t, r.add(1))
+	assert.True(t, r.add(1))
+	r.next()
+	assert.Equal(t, "capture_0001", r.getName())
+	assert.False(t, r.add(1))
+}
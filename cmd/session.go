@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/jpillora/sizestr"
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/rivo/tview"
+)
+
+// CaptureSession owns the state of a capture: its configuration, limits, counters, outputs and display model.
+// Counters and flags are shared between the collector, display and signal goroutines so they are atomic,
+// flows kept in memory being guarded by mutex.
+type CaptureSession struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+
+	capture     captureType
+	filename    string
+	maxTime     time.Duration
+	maxBytes    int64
+	dedupMode   string
+	startupTime time.Time
+
+	received         atomic.Int64
+	written          atomic.Int64
+	totalBytes       atomic.Int64
+	collectorStarted atomic.Bool
+	captureStarted   atomic.Bool
+	captureEnded     atomic.Bool
+	paused           atomic.Bool
+	showDuplicates   atomic.Bool
+
+	// collector stages, created from flags when the collector starts
+	trigger    *captureTrigger
+	anonymizer *flowAnonymizer
+	deduper    *flowDeduper
+
+	sinks        *sinkSet
+	outputs      []string
+	displayQueue *recordQueue
+	api          *apiServer
+
+	// display application, nil when headless or in background
+	app *tview.Application
+
+	// display model
+	mutex           sync.Mutex
+	flowIndex       int
+	lastFlows       []config.GenericMap
+	tableData       *TableData
+	showCount       int
+	selectedColumns []string
+	// column id used to sort the table, flows being sorted by time when empty
+	sortColumn     string
+	sortDescending bool
+	aggregates     *aggregateTracker
+	conversations  *conversationTracker
+	// filter suggestions refreshed with the table, guarded by mutex as they are read by the input field
+	suggestions []string
+	// payload of the selected packet, only used by the display goroutine
+	selectedData []byte
+}
+
+var (
+	errInterrupted    = errors.New("interrupted using ctrl-c")
+	errDisplayClosed  = errors.New("display closed")
+	errCollectorEnded = errors.New("collector ended")
+
+	// session is the capture shown by the display, replaced by each command
+	session = newCaptureSession(context.Background(), Flow)
+)
+
+// newCaptureSession creates a session from flags, stopped when ctx is done
+func newCaptureSession(ctx context.Context, c captureType) *CaptureSession {
+	s := &CaptureSession{
+		done:        make(chan struct{}),
+		capture:     c,
+		filename:    filename,
+		maxTime:     maxTime,
+		maxBytes:    maxBytes,
+		dedupMode:   dedupMode,
+		startupTime: currentTime(),
+		lastFlows:   []config.GenericMap{},
+		tableData: &TableData{
+			cols:  []string{},
+			flows: []config.GenericMap{},
+		},
+		showCount:       1,
+		selectedColumns: []string{},
+		aggregates:      newAggregateTracker(),
+		conversations:   newConversationTracker(),
+		suggestions:     []string{},
+		selectedData:    []byte{},
+	}
+	if c != Metric {
+		s.showCount = defaultFlowShowCount
+	}
+	s.tableData.session = s
+	s.ctx, s.cancel = context.WithCancelCause(ctx)
+	return s
+}
+
+// startSession makes a new session current for the display, stopped on SIGTERM or SIGINT
+func startSession(ctx context.Context, c captureType) *CaptureSession {
+	session = newCaptureSession(ctx, c)
+	go session.stopOnSignal()
+	if useMocks {
+		log.Info("Using mocks...")
+		go mockForever(session)
+	}
+	return session
+}
+
+// stopOnSignal stops the session on the first signal, the next one killing the process
+func (s *CaptureSession) stopOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+	select {
+	case sig := <-signals:
+		log.Infof("Received %s; stopping capture...", sig)
+		s.stop(fmt.Errorf("received %s", sig))
+	case <-s.ctx.Done():
+	}
+}
+
+// run starts the collector along with the display or batch table, or alone in background or headless mode,
+// then stops the session, waits for the collector to close its outputs, ends the streams and logs a summary
+func (s *CaptureSession) run(collect func(), createDisplay func()) {
+	collector := func() {
+		defer close(s.done)
+		defer s.stop(errCollectorEnded)
+		collect()
+	}
+	if s.isBatch() {
+		if err := s.validateBatch(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if apiPort > 0 {
+		s.startAPI(apiPort)
+	}
+	switch {
+	case s.isBatch():
+		// table is printed periodically instead of being displayed
+		go collector()
+		s.runBatch()
+	case s.isHeadless():
+		// records are streamed to stdout, without display
+		collector()
+	case isBackground:
+		go s.backgroundHearbeat() // show table periodically in background
+		collector()
+	default:
+		go collector()
+		createDisplay()
+	}
+	s.stop(errDisplayClosed)
+	<-s.done
+	if s.api != nil {
+		s.api.close()
+	}
+	log.Info(s.getSummaryText())
+}
+
+// stop ends the collector for the given cause, only the first one being kept
+func (s *CaptureSession) stop(cause error) {
+	s.cancel(cause)
+}
+
+func (s *CaptureSession) isStopped() bool {
+	return s.ctx.Err() != nil
+}
+
+// getStopCause returns why the session stopped, nil while running
+func (s *CaptureSession) getStopCause() error {
+	return context.Cause(s.ctx)
+}
+
+// rotateSinks closes the current outputs and opens the next ones
+func (s *CaptureSession) rotateSinks(rotation *outputRotation) error {
+	s.sinks.close()
+	rotation.next()
+	if err := s.sinks.open(rotation.getName()); err != nil {
+		return err
+	}
+	s.outputs = append(s.outputs, rotation.getName())
+	return nil
+}
+
+// getOutputName returns the name of the capture outputs, generated from current time if not provided
+func (s *CaptureSession) getOutputName() string {
+	if len(s.filename) == 0 {
+		s.filename = strings.ReplaceAll(
+			currentTime().UTC().Format(time.RFC3339),
+			":", "") // get rid of offensive colons
+	}
+	return s.filename
+}
+
+// addBytes counts written bytes and returns the capture size
+func (s *CaptureSession) addBytes(bytes int64) int64 {
+	return s.totalBytes.Add(bytes)
+}
+
+// isLimitReached tells if the capture exceeded its max bytes or time
+func (s *CaptureSession) isLimitReached() (bool, string) {
+	if s.totalBytes.Load() > s.maxBytes {
+		return true, sizestr.ToString(s.maxBytes)
+	}
+	if currentTime().Sub(s.startupTime) > s.maxTime {
+		return true, s.maxTime.String()
+	}
+	return false, ""
+}
+
+// newMaxTimeTimer fires when the capture reaches its max time so it ends even when no record is received,
+// returning nil in continuous mode
+func (s *CaptureSession) newMaxTimeTimer() *time.Timer {
+	if isRotationEnabled() {
+		return nil
+	}
+	return time.NewTimer(s.startupTime.Add(s.maxTime).Sub(currentTime()))
+}
+
+// endOnLimit ends the capture when max bytes or time is reached, returning true when the collector must exit
+func (s *CaptureSession) endOnLimit() bool {
+	if reached, limit := s.isLimitReached(); reached {
+		return s.endOnReachedLimit(limit)
+	}
+	return false
+}
+
+func (s *CaptureSession) endOnReachedLimit(limit string) bool {
+	if exit := s.onLimitReached("capture reached " + limit); exit {
+		log.Infof("Capture reached %s, exiting now...", limit)
+		return true
+	}
+	return false
+}
+
+// onLimitReached ends the capture once and returns true when the collector must exit
+func (s *CaptureSession) onLimitReached(reason string) bool {
+	if !s.captureEnded.CompareAndSwap(false, true) {
+		return false
+	}
+	log.Trace("Capture ended")
+	if s.app != nil && errAdvancedDisplay == nil {
+		s.app.Stop()
+	}
+	if isBackground {
+		printCaptureEnd(s.capture)
+		return false
+	}
+	s.stop(errors.New(reason))
+	return true
+}
+
+// getStatusText shows the health of outputs, to be called once the collector started
+func (s *CaptureSession) getStatusText() string {
+	text := ""
+	if s.trigger != nil {
+		text += " " + s.trigger.getStatusText()
+	}
+	if s.displayQueue != nil {
+		text += s.displayQueue.getStatusText()
+	}
+	return text + s.sinks.getStatusText()
+}
+
+// getSummaryText describes the capture once stopped
+func (s *CaptureSession) getSummaryText() string {
+	text := "Capture summary:"
+	if cause := s.getStopCause(); cause != nil {
+		text += fmt.Sprintf("\n  Stopped: %v", cause)
+	}
+	text += fmt.Sprintf("\n  Duration: %s", currentTime().Sub(s.startupTime).Round(time.Second))
+	if s.capture == Metric {
+		return text
+	}
+	kind := "flows"
+	if s.capture == Packet {
+		kind = "packets"
+	}
+	text += fmt.Sprintf("\n  Received: %d %s", s.received.Load(), kind)
+	text += fmt.Sprintf("\n  Written: %d %s, %s", s.written.Load(), kind, sizestr.ToString(s.totalBytes.Load()))
+	if len(s.outputs) > 0 {
+		text += fmt.Sprintf("\n  Outputs: %s", strings.Join(s.outputs, ", "))
+	}
+	if s.displayQueue != nil && s.displayQueue.getDropped() > 0 {
+		text += fmt.Sprintf("\n  Not displayed: %d %s", s.displayQueue.getDropped(), kind)
+	}
+	if s.sinks != nil {
+		if status := s.sinks.getStatusText(); status != "" {
+			text += "\n  Sinks:" + status
+		}
+	}
+	return text
+}
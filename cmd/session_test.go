@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/netobserv/flowlogs-pipeline/pkg/pipeline/write/grpc/genericmap"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestCaptureSessions(t *testing.T) {
+	setup(t)
+	first := newCaptureSession(t.Context(), Flow)
+	second := newCaptureSession(t.Context(), Flow)
+	first.maxBytes = 100
+	second.maxBytes = 100
+
+	// sessions keep their own flows and counters while collecting concurrently
+	wg := sync.WaitGroup{}
+	for _, s := range []*CaptureSession{first, second, first} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				s.appendFlow(config.GenericMap{"TimeFlowEndMs": float64(i)})
+				s.addBytes(1)
+				_ = s.getLastFlows()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, first.getLastFlows(), keepCount)
+	assert.Len(t, second.getLastFlows(), 50)
+	assert.Equal(t, int64(100), first.totalBytes.Load())
+	assert.Empty(t, session.getLastFlows())
+
+	// max bytes then max time end the capture once
+	reached, _ := first.isLimitReached()
+	assert.False(t, reached)
+	first.addBytes(1)
+	reached, limit := first.isLimitReached()
+	assert.True(t, reached)
+	assert.Equal(t, "100B", limit)
+	second.maxTime = time.Second
+	simulatedTime = second.startupTime.Add(2 * time.Second)
+	currentTime = func() time.Time { return simulatedTime }
+	reached, limit = second.isLimitReached()
+	assert.True(t, reached)
+	assert.Equal(t, "1s", limit)
+	assert.True(t, first.onLimitReached("capture reached 100B"))
+	assert.False(t, first.onLimitReached("capture reached 1s"))
+	assert.False(t, second.captureEnded.Load())
+
+	// stopping a session doesn't affect the others and keeps the first cause
+	first.stop(errInterrupted)
+	assert.True(t, first.isStopped())
+	assert.EqualError(t, first.getStopCause(), "capture reached 100B")
+	assert.False(t, second.isStopped())
+	assert.Nil(t, second.getStopCause())
+}
+
+func TestSessionMaxTimeTimer(t *testing.T) {
+	setup(t)
+
+	// the capture ends on time without waiting for a record
+	session.maxTime = 20 * time.Millisecond
+	timer := session.newMaxTimeTimer()
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		assert.Fail(t, "max time timer didn't fire")
+	}
+	assert.True(t, session.endOnReachedLimit(session.maxTime.String()))
+	assert.True(t, session.isStopped())
+	assert.EqualError(t, session.getStopCause(), "capture reached 20ms")
+
+	// continuous capture has no max time
+	rotateTime = time.Hour
+	defer func() { rotateTime = 0 }()
+	assert.Nil(t, session.newMaxTimeTimer())
+}
+
+func TestSessionShutdown(t *testing.T) {
+	setup(t)
+	sink := &testSink{}
+	registerSink("test-shutdown", "shutdown test sink", []captureType{Flow}, func() Sink { return sink })
+	defer delete(sinkRegistry, "test-shutdown")
+
+	s := newCaptureSession(t.Context(), Flow)
+	s.deduper = newFlowDeduper(time.Minute)
+	var err error
+	s.sinks, err = newSinkSet(Flow, []string{"test-shutdown"})
+	assert.Nil(t, err)
+	assert.Nil(t, s.sinks.open("shutdown"))
+
+	// one flow held for deduplication and two others queued by the collector
+	records, err := s.readFlow([]byte(`{"SrcAddr":"10.0.0.1","DstAddr":"10.0.0.2","Bytes":1}`))
+	assert.Nil(t, err)
+	assert.Empty(t, records)
+	flowPackets := make(chan *genericmap.Flow, 10)
+	for _, addr := range []string{"10.0.0.3", "10.0.0.4"} {
+		flowPackets <- &genericmap.Flow{GenericMap: &anypb.Any{
+			Value: []byte(`{"SrcAddr":"` + addr + `","DstAddr":"10.0.0.2","Bytes":1}`),
+		}}
+	}
+
+	// every received flow is written once stopped
+	s.stop(errors.New("received terminated"))
+	records = s.drainFlows(flowPackets)
+	assert.Len(t, records, 3)
+	assert.False(t, s.writeFlows(records, newOutputRotation("flow", "shutdown")))
+	s.sinks.close()
+	assert.Equal(t, []string{"open shutdown", "write", "write", "write", "close"}, sink.calls)
+
+	summary := s.getSummaryText()
+	assert.Contains(t, summary, "Stopped: received terminated")
+	assert.Contains(t, summary, "Received: 3 flows")
+	assert.Contains(t, summary, "Written: 3 flows, 3B")
+}
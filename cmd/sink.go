@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+// Sink is a destination of captured records, registered using registerSink.
+// Its methods are called from the collector goroutine only.
+type Sink interface {
+	// Open starts a new output named after the capture, called again when rotating outputs
+	Open(name string) error
+	// Write adds a record, which must not be modified as it is shared with the other sinks
+	Write(record config.GenericMap) error
+	// Flush makes the written records available to readers
+	Flush() error
+	// Close flushes and ends the current output
+	Close() error
+}
+
+// sizedSink is implemented by sinks whose output size counts toward max bytes and rotation
+type sizedSink interface {
+	// Size returns the size written since the output was opened
+	Size() int64
+}
+
+// statusSink is implemented by sinks showing their health next to the capture status
+type statusSink interface {
+	// getStatusText returns a text starting with a space, empty when there is nothing to show
+	getStatusText() string
+}
+
+// sinks are flushed at least at this interval while receiving records
+const sinkFlushInterval = time.Second
+
+type sinkDefinition struct {
+	description string
+	captures    []captureType
+	create      func() Sink
+	// explicit sinks are only used when selected, never by default
+	explicit bool
+}
+
+var (
+	sinkNames    = []string{}
+	sinkRegistry = map[string]sinkDefinition{}
+)
+
+// registerSink makes a sink available to the --sink flag for the given captures
+func registerSink(name, description string, captures []captureType, create func() Sink) {
+	sinkRegistry[name] = sinkDefinition{description: description, captures: captures, create: create}
+}
+
+// registerExplicitSink makes a sink available to the --sink flag, used only when selected
+func registerExplicitSink(name, description string, captures []captureType, create func() Sink) {
+	registerSink(name, description, captures, create)
+	definition := sinkRegistry[name]
+	definition.explicit = true
+	sinkRegistry[name] = definition
+}
+
+// getSinkNames returns the sinks available for a capture, sorted by name
+func getSinkNames(c captureType) []string {
+	names := []string{}
+	for name, definition := range sinkRegistry {
+		if slices.Contains(definition.captures, c) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getDefaultSinkNames returns the sinks used when none is selected
+func getDefaultSinkNames(c captureType) []string {
+	return slices.DeleteFunc(getSinkNames(c), func(name string) bool {
+		return sinkRegistry[name].explicit
+	})
+}
+
+// isSinkSelected tells if a sink was selected using the --sink flag
+func isSinkSelected(name string) bool {
+	return slices.ContainsFunc(sinkNames, func(n string) bool { return strings.TrimSpace(n) == name })
+}
+
+func getSinksText(c captureType) string {
+	texts := []string{}
+	for _, name := range getSinkNames(c) {
+		texts = append(texts, fmt.Sprintf("%s (%s)", name, sinkRegistry[name].description))
+	}
+	return strings.Join(texts, ", ")
+}
+
+// namedSink counts the errors of a sink so each one reports its own
+type namedSink struct {
+	name   string
+	sink   Sink
+	opened bool
+	errors atomic.Int64
+}
+
+func (s *namedSink) onError(action string, err error) {
+	s.errors.Add(1)
+	log.Errorf("%s sink: %s failed: %v", s.name, action, err)
+}
+
+// sinkSet dispatches the records of a capture to every selected sink
+type sinkSet struct {
+	sinks     []*namedSink
+	lastFlush time.Time
+}
+
+// newSinkSet creates the named sinks, or the default ones for the capture if none
+func newSinkSet(c captureType, names []string) (*sinkSet, error) {
+	if len(names) == 0 {
+		names = getDefaultSinkNames(c)
+	}
+	set := &sinkSet{lastFlush: currentTime()}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		definition, found := sinkRegistry[name]
+		if !found || !slices.Contains(definition.captures, c) {
+			return nil, fmt.Errorf("unknown %s sink %s, expected one of: %s", strings.ToLower(string(c)), name, getSinksText(c))
+		}
+		if slices.ContainsFunc(set.sinks, func(s *namedSink) bool { return s.name == name }) {
+			continue
+		}
+		set.sinks = append(set.sinks, &namedSink{name: name, sink: definition.create()})
+	}
+	return set, nil
+}
+
+// initSinks creates the sinks of the session from flags and opens their first outputs
+func (s *CaptureSession) initSinks(name string) {
+	var err error
+	s.sinks, err = newSinkSet(s.capture, sinkNames)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = s.sinks.open(name); err != nil {
+		log.Fatal(err)
+	}
+	s.outputs = append(s.outputs, name)
+}
+
+// open starts the outputs of every sink, closing the opened ones on error
+func (s *sinkSet) open(name string) error {
+	for _, ns := range s.sinks {
+		if err := ns.sink.Open(name); err != nil {
+			s.close()
+			return fmt.Errorf("%s sink: open failed: %w", ns.name, err)
+		}
+		ns.opened = true
+	}
+	return nil
+}
+
+// write sends a record to every sink and returns the size to account for limits
+func (s *sinkSet) write(record config.GenericMap) int64 {
+	before := s.size()
+	for _, ns := range s.sinks {
+		if !ns.opened {
+			continue
+		}
+		if err := ns.sink.Write(record); err != nil {
+			ns.onError("write", err)
+		}
+	}
+	size := s.size() - before
+	if now := currentTime(); now.Sub(s.lastFlush) >= sinkFlushInterval {
+		s.lastFlush = now
+		s.flush()
+	}
+	return size
+}
+
+func (s *sinkSet) size() int64 {
+	size := int64(0)
+	for _, ns := range s.sinks {
+		if sized, ok := ns.sink.(sizedSink); ok && ns.opened {
+			size += sized.Size()
+		}
+	}
+	return size
+}
+
+func (s *sinkSet) flush() {
+	for _, ns := range s.sinks {
+		if !ns.opened {
+			continue
+		}
+		if err := ns.sink.Flush(); err != nil {
+			ns.onError("flush", err)
+		}
+	}
+}
+
+// close ends the opened outputs, it can be called more than once
+func (s *sinkSet) close() {
+	for _, ns := range s.sinks {
+		if !ns.opened {
+			continue
+		}
+		ns.opened = false
+		if err := ns.sink.Close(); err != nil {
+			ns.onError("close", err)
+		}
+	}
+}
+
+// getStatusText shows the sinks status and errors, if any
+func (s *sinkSet) getStatusText() string {
+	text := ""
+	for _, ns := range s.sinks {
+		if status, ok := ns.sink.(statusSink); ok {
+			text += status.getStatusText()
+		}
+		if errors := ns.errors.Load(); errors > 0 {
+			text += fmt.Sprintf(" %s errors: %d", ns.name, errors)
+		}
+	}
+	return text
+}
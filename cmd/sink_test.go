@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSink records the calls it receives
+type testSink struct {
+	calls   []string
+	openErr error
+	size    int64
+	status  string
+}
+
+func (s *testSink) Open(name string) error {
+	s.calls = append(s.calls, "open "+name)
+	return s.openErr
+}
+
+func (s *testSink) Write(record config.GenericMap) error {
+	s.calls = append(s.calls, "write")
+	s.size += int64(record["Bytes"].(float64))
+	if record["Bytes"] == float64(0) {
+		return errors.New("empty record")
+	}
+	return nil
+}
+
+func (s *testSink) Flush() error {
+	s.calls = append(s.calls, "flush")
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.calls = append(s.calls, "close")
+	return nil
+}
+
+func (s *testSink) Size() int64 {
+	return s.size
+}
+
+func (s *testSink) getStatusText() string {
+	return s.status
+}
+
+func TestSinkSet(t *testing.T) {
+	setup(t)
+	first, second := &testSink{}, &testSink{}
+	registerSink("test-first", "first test sink", []captureType{Flow}, func() Sink { return first })
+	registerSink("test-second", "second test sink", []captureType{Flow}, func() Sink { return second })
+	defer func() {
+		delete(sinkRegistry, "test-first")
+		delete(sinkRegistry, "test-second")
+	}()
+
+	assert.Equal(t, []string{"db", "file", "stdout", "test-first", "test-second"}, getSinkNames(Flow))
+	assert.Equal(t, []string{"pcapng", "stdout"}, getSinkNames(Packet))
+	// explicit sinks are not used by default
+	assert.Equal(t, []string{"pcapng"}, getDefaultSinkNames(Packet))
+	_, err := newSinkSet(Packet, []string{"test-first"})
+	assert.ErrorContains(t, err, "unknown packet sink test-first, expected one of: pcapng (pcapng file with enrichment as packet comments), stdout")
+
+	set, err := newSinkSet(Flow, []string{"test-first", " test-second", "test-first"})
+	assert.Nil(t, err)
+	assert.Len(t, set.sinks, 2)
+	assert.Nil(t, set.open("capture"))
+
+	// sizes of every sink are summed and errors are counted per sink
+	assert.Equal(t, int64(20), set.write(config.GenericMap{"Bytes": float64(10)}))
+	assert.Equal(t, int64(0), set.write(config.GenericMap{"Bytes": float64(0)}))
+	second.status = " Pending: 2"
+	assert.Equal(t, " test-first errors: 1 Pending: 2 test-second errors: 1", set.getStatusText())
+
+	set.close()
+	set.close()
+	assert.Equal(t, []string{"open capture", "write", "write", "close"}, first.calls)
+
+	// opened sinks are closed when another one fails to open
+	second.openErr = errors.New("unavailable")
+	first.calls = []string{}
+	assert.ErrorContains(t, set.open("next"), "test-second sink: open failed: unavailable")
+	assert.Equal(t, []string{"open next", "close"}, first.calls)
+}
+
+func TestFlowSinks(t *testing.T) {
+	setup(t)
+	defer os.RemoveAll("./output")
+
+	set, err := newSinkSet(Flow, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, set.open("sink_test"))
+	flow := config.GenericMap{"SrcAddr": "10.0.0.1", "Bytes": float64(1)}
+	assert.Greater(t, set.write(flow), int64(0))
+	set.close()
+
+	flows, err := readFlowsFile("./output/flow/sink_test.json")
+	assert.Nil(t, err)
+	assert.Equal(t, []config.GenericMap{flow}, flows)
+	flows, err = readFlowsDB("./output/flow/sink_test.db")
+	assert.Nil(t, err)
+	assert.Len(t, flows, 1)
+	assert.Equal(t, "10.0.0.1", flows[0]["SrcAddr"])
+	assert.Empty(t, set.getStatusText())
+
+	// database alone counts the bytes inserted once committed
+	set, err = newSinkSet(Flow, []string{"db"})
+	assert.Nil(t, err)
+	assert.Nil(t, set.open("sink_db_test"))
+	defer set.close()
+	set.write(flow)
+	assert.Eventually(t, func() bool { return set.size() > 0 }, 2*dbFlushInterval, 10*time.Millisecond)
+}
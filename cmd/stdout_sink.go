@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const stdoutSinkName = "stdout"
+
+var (
+	stdoutFields   = []string{}
+	stdoutTemplate = ""
+
+	// records are streamed to this writer, replaced in tests
+	stdout io.Writer = os.Stdout
+)
+
+func init() {
+	registerExplicitSink(stdoutSinkName, "one json object per line on standard output instead of the display",
+		[]captureType{Flow, Packet}, func() Sink { return &stdoutSink{} })
+}
+
+// isHeadless tells if records are streamed to stdout, which is then reserved to them
+func (s *CaptureSession) isHeadless() bool {
+	return s.capture != Metric && isSinkSelected(stdoutSinkName)
+}
+
+// stdoutSink writes each record on its own line, as json restricted to some fields or using a template
+type stdoutSink struct {
+	fields   []string
+	template *template.Template
+	size     int64
+}
+
+func (s *stdoutSink) Open(_ string) error {
+	if len(stdoutFields) > 0 && stdoutTemplate != "" {
+		return errors.New("use either stdout fields or template")
+	}
+	s.size = 0
+	s.fields = []string{}
+	for _, field := range stdoutFields {
+		if field = strings.TrimSpace(field); field != "" {
+			s.fields = append(s.fields, field)
+		}
+	}
+	s.template = nil
+	if stdoutTemplate != "" {
+		t, err := template.New(stdoutSinkName).Parse(stdoutTemplate)
+		if err != nil {
+			return err
+		}
+		s.template = t
+	}
+	return nil
+}
+
+// Write prints the record in a single write so lines are never interleaved
+func (s *stdoutSink) Write(record config.GenericMap) error {
+	var line []byte
+	if s.template != nil {
+		buf := bytes.Buffer{}
+		if err := s.template.Execute(&buf, toTemplateData(record)); err != nil {
+			return err
+		}
+		line = buf.Bytes()
+	} else {
+		var err error
+		line, err = json.Marshal(s.project(record))
+		if err != nil {
+			return err
+		}
+	}
+	n, err := stdout.Write(append(line, '\n'))
+	s.size += int64(n)
+	return err
+}
+
+// Size returns the bytes printed since the sink was opened
+func (s *stdoutSink) Size() int64 {
+	return s.size
+}
+
+// project keeps the selected fields or calculated columns of the record, all of its fields if none
+func (s *stdoutSink) project(record config.GenericMap) config.GenericMap {
+	if len(s.fields) == 0 {
+		return record
+	}
+	calculated := getCalculatedValues(record, s.fields)
+	projected := config.GenericMap{}
+	for _, field := range s.fields {
+		if value, found := record[field]; found {
+			projected[field] = value
+		} else if value, found := calculated[field]; found {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+// Flush does nothing since each record is written at once
+func (s *stdoutSink) Flush() error {
+	return nil
+}
+
+func (s *stdoutSink) Close() error {
+	return nil
+}
+
+// toTemplateData adds calculated columns and prints json integers such as timestamps without exponent
+func toTemplateData(record config.GenericMap) config.GenericMap {
+	data := config.GenericMap{}
+	for _, values := range []config.GenericMap{getCalculatedValues(record, nil), record} {
+		for key, value := range values {
+			if f, ok := value.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+				data[key] = int64(f)
+			} else {
+				data[key] = value
+			}
+		}
+	}
+	return data
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdoutSink(t *testing.T) {
+	buf := bytes.Buffer{}
+	stdout = &buf
+	defer func() {
+		stdout = os.Stdout
+		stdoutFields = []string{}
+		stdoutTemplate = ""
+	}()
+	flow := config.GenericMap{"SrcAddr": "10.0.0.1", "DstAddr": "10.0.0.2", "Bytes": float64(456), "TimeFlowEndMs": float64(1709742328703)}
+
+	// whole records by default
+	sink := &stdoutSink{}
+	assert.Nil(t, sink.Open("capture"))
+	assert.Nil(t, sink.Write(flow))
+	assert.Equal(t, `{"Bytes":456,"DstAddr":"10.0.0.2","SrcAddr":"10.0.0.1","TimeFlowEndMs":1709742328703}`+"\n", buf.String())
+	// printed bytes count toward max bytes
+	assert.Equal(t, int64(buf.Len()), sink.Size())
+
+	// projection skips missing fields
+	buf.Reset()
+	stdoutFields = []string{"SrcAddr", " Bytes", "Proto"}
+	assert.Nil(t, sink.Open("capture"))
+	assert.Nil(t, sink.Write(flow))
+	assert.Nil(t, sink.Write(config.GenericMap{"SrcAddr": "10.0.0.3"}))
+	assert.Equal(t, `{"Bytes":456,"SrcAddr":"10.0.0.1"}`+"\n"+`{"SrcAddr":"10.0.0.3"}`+"\n", buf.String())
+	assert.Equal(t, int64(buf.Len()), sink.Size())
+
+	// template prints integers without exponent
+	buf.Reset()
+	stdoutFields = []string{}
+	stdoutTemplate = "{{.TimeFlowEndMs}} {{.SrcAddr}} -> {{.DstAddr}} {{.Bytes}}B"
+	assert.Nil(t, sink.Open("capture"))
+	assert.Nil(t, sink.Write(flow))
+	assert.Equal(t, "1709742328703 10.0.0.1 -> 10.0.0.2 456B\n", buf.String())
+
+	stdoutTemplate = "{{.SrcAddr"
+	assert.ErrorContains(t, sink.Open("capture"), "unclosed action")
+	stdoutFields = []string{"SrcAddr"}
+	stdoutTemplate = "{{.SrcAddr}}"
+	assert.ErrorContains(t, sink.Open("capture"), "use either stdout fields or template")
+}
+
+func TestStdoutSinkCalculated(t *testing.T) {
+	setup(t)
+	buf := bytes.Buffer{}
+	stdout = &buf
+	defer func() {
+		stdout = os.Stdout
+		stdoutFields = []string{}
+		stdoutTemplate = ""
+	}()
+	flow := config.GenericMap{"SrcAddr": "10.0.0.1", "SrcPort": float64(443), "SrcK8S_Type": "Pod", "SrcK8S_Namespace": "ns", "SrcK8S_Name": "pod-a"}
+
+	// calculated columns are projected like fields
+	stdoutFields = []string{"SrcK8S_Name", "SrcK8S_Object", "SrcAddrPort", "DstAddrPort"}
+	sink := &stdoutSink{}
+	assert.Nil(t, sink.Open("capture"))
+	assert.Nil(t, sink.Write(flow))
+	assert.Equal(t, `{"SrcAddrPort":"10.0.0.1:443","SrcK8S_Name":"pod-a","SrcK8S_Object":"ns.pod-a"}`+"\n", buf.String())
+
+	// and available to templates
+	buf.Reset()
+	stdoutFields = []string{}
+	stdoutTemplate = "{{.SrcK8S_Object}} {{.SrcAddrPort}}"
+	assert.Nil(t, sink.Open("capture"))
+	assert.Nil(t, sink.Write(flow))
+	assert.Equal(t, "ns.pod-a 10.0.0.1:443\n", buf.String())
+}
+
+func TestHeadlessSession(t *testing.T) {
+	setup(t)
+	defer func() { sinkNames = []string{} }()
+
+	assert.False(t, session.isHeadless())
+	sinkNames = []string{"db", " stdout"}
+	assert.True(t, session.isHeadless())
+	assert.False(t, newCaptureSession(t.Context(), Metric).isHeadless())
+}
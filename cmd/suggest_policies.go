@@ -0,0 +1,512 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+	maxANPRules        = 100 // maximum ingress or egress rules of an admin network policy
+)
+
+var (
+	policySelectorLabel = "app.kubernetes.io/name"
+	policyAdmin         bool
+	policyAdminPriority = 10
+
+	// protocols supported by network policies
+	policyProtocols = map[int]corev1.Protocol{
+		6:   corev1.ProtocolTCP,
+		17:  corev1.ProtocolUDP,
+		132: corev1.ProtocolSCTP,
+	}
+
+	suggestPoliciesCmd = &cobra.Command{
+		Use:   "suggest-policies <file>...",
+		Short: "Suggest network policies from captures",
+		Long: "Generate NetworkPolicies, and optionally AdminNetworkPolicies, allowing the traffic observed per workload " +
+			"in flow json / ndjson files, flow databases or pcapng files.\n\n" +
+			"Workloads are selected using the --selector-label label set to their owner name, such as the Deployment name, " +
+			"and the lowest port of each flow is considered as the server port, replies being allowed by policies. " +
+			"External and unresolved peers are reported as comments.",
+		Args: cobra.MinimumNArgs(1),
+		Run:  runSuggestPolicies,
+	}
+)
+
+// policyEndpoint is a flow source or destination as seen by policies
+type policyEndpoint struct {
+	namespace string
+	owner     string
+	// address and node name of peers outside of the pod network
+	addr string
+	node string
+	// reason why the endpoint can't be used in a policy
+	unresolved string
+}
+
+func (e *policyEndpoint) isWorkload() bool {
+	return e.unresolved == "" && e.owner != ""
+}
+
+func (e *policyEndpoint) String() string {
+	switch {
+	case e.isWorkload():
+		return e.namespace + "/" + e.owner
+	case e.node != "":
+		return fmt.Sprintf("%s (node %s)", e.addr, e.node)
+	case e.unresolved != "":
+		return e.unresolved
+	default:
+		return e.addr
+	}
+}
+
+type policyPort struct {
+	protocol corev1.Protocol
+	port     int
+}
+
+func (p policyPort) String() string {
+	return fmt.Sprintf("%s/%d", p.protocol, p.port)
+}
+
+// policyPeer is a workload or a cidr allowed by a rule
+type policyPeer struct {
+	namespace string
+	owner     string
+	cidr      string
+}
+
+type workloadPolicy struct {
+	namespace string
+	owner     string
+	ingress   map[policyPeer]map[policyPort]bool
+	egress    map[policyPeer]map[policyPort]bool
+}
+
+// policySuggestion gathers the observed traffic per workload
+type policySuggestion struct {
+	flows     int
+	workloads map[string]*workloadPolicy
+	// external peers and the workloads they talked to
+	external map[string]map[string]bool
+	// flows that can't be allowed by policies per reason
+	unresolved map[string]int
+}
+
+func newPolicySuggestion() *policySuggestion {
+	return &policySuggestion{
+		workloads:  map[string]*workloadPolicy{},
+		external:   map[string]map[string]bool{},
+		unresolved: map[string]int{},
+	}
+}
+
+func toPolicyEndpoint(flow config.GenericMap, prefix string) *policyEndpoint {
+	get := func(field string) string {
+		if v, ok := flow[prefix+field].(string); ok {
+			return v
+		}
+		return ""
+	}
+	e := &policyEndpoint{
+		namespace: get("K8S_Namespace"),
+		owner:     get("K8S_OwnerName"),
+		addr:      get("Addr"),
+	}
+	if e.owner == "" {
+		e.owner = get("K8S_Name")
+	}
+
+	kind := get("K8S_Type")
+	switch {
+	case kind == "Pod" && e.namespace != "" && e.owner != "":
+		// workload
+	case kind == "Node":
+		e.owner = ""
+		e.node = get("K8S_Name")
+	case kind == "" && e.namespace == "" && e.addr != "":
+		e.owner = ""
+	default:
+		e.unresolved = fmt.Sprintf("%s %s %s/%s", e.addr, kind, e.namespace, get("K8S_Name"))
+		e.owner = ""
+	}
+	return e
+}
+
+func toPolicyPeer(e *policyEndpoint) policyPeer {
+	if e.isWorkload() {
+		return policyPeer{namespace: e.namespace, owner: e.owner}
+	}
+	if strings.Contains(e.addr, ":") {
+		return policyPeer{cidr: e.addr + "/128"}
+	}
+	return policyPeer{cidr: e.addr + "/32"}
+}
+
+func (s *policySuggestion) getWorkload(e *policyEndpoint) *workloadPolicy {
+	key := e.String()
+	w, ok := s.workloads[key]
+	if !ok {
+		w = &workloadPolicy{
+			namespace: e.namespace,
+			owner:     e.owner,
+			ingress:   map[policyPeer]map[policyPort]bool{},
+			egress:    map[policyPeer]map[policyPort]bool{},
+		}
+		s.workloads[key] = w
+	}
+	return w
+}
+
+func addPolicyRule(rules map[policyPeer]map[policyPort]bool, peer policyPeer, port policyPort) {
+	if _, ok := rules[peer]; !ok {
+		rules[peer] = map[policyPort]bool{}
+	}
+	rules[peer][port] = true
+}
+
+// addFlow allows the flow on both source egress and destination ingress
+func (s *policySuggestion) addFlow(flow config.GenericMap) {
+	s.flows++
+	src := toPolicyEndpoint(flow, "Src")
+	dst := toPolicyEndpoint(flow, "Dst")
+	if !src.isWorkload() && !dst.isWorkload() {
+		return
+	}
+
+	// replies are allowed by policies, the lowest port being considered as the server one
+	srcPort, dstPort := int(toNumber(flow, "SrcPort")), int(toNumber(flow, "DstPort"))
+	if srcPort > 0 && srcPort < dstPort {
+		src, dst = dst, src
+		dstPort = srcPort
+	}
+
+	protocol, ok := policyProtocols[int(toNumber(flow, "Proto"))]
+	if !ok || dstPort == 0 {
+		s.unresolved[fmt.Sprintf("%s -> %s: unsupported protocol %v", src, dst, flow["Proto"])]++
+		return
+	}
+	port := policyPort{protocol: protocol, port: dstPort}
+
+	for _, e := range []*policyEndpoint{src, dst} {
+		if e.unresolved != "" {
+			s.unresolved[fmt.Sprintf("%s -> %s %s: unresolved peer", src, dst, port)]++
+			return
+		}
+	}
+	if src.isWorkload() {
+		addPolicyRule(s.getWorkload(src).egress, toPolicyPeer(dst), port)
+	} else {
+		s.addExternal(src, dst)
+	}
+	if dst.isWorkload() {
+		addPolicyRule(s.getWorkload(dst).ingress, toPolicyPeer(src), port)
+	} else {
+		s.addExternal(dst, src)
+	}
+}
+
+func (s *policySuggestion) addExternal(peer, workload *policyEndpoint) {
+	key := peer.String()
+	if _, ok := s.external[key]; !ok {
+		s.external[key] = map[string]bool{}
+	}
+	s.external[key][workload.String()] = true
+}
+
+func (s *policySuggestion) sortedWorkloads() []*workloadPolicy {
+	keys := make([]string, 0, len(s.workloads))
+	for k := range s.workloads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	workloads := make([]*workloadPolicy, len(keys))
+	for i, k := range keys {
+		workloads[i] = s.workloads[k]
+	}
+	return workloads
+}
+
+func sortedPolicyPeers(rules map[policyPeer]map[policyPort]bool) []policyPeer {
+	peers := make([]policyPeer, 0, len(rules))
+	for peer := range rules {
+		peers = append(peers, peer)
+	}
+	slices.SortFunc(peers, func(a, b policyPeer) int {
+		return strings.Compare(a.namespace+"/"+a.owner+"/"+a.cidr, b.namespace+"/"+b.owner+"/"+b.cidr)
+	})
+	return peers
+}
+
+func sortedPolicyPorts(ports map[policyPort]bool) []policyPort {
+	sorted := make([]policyPort, 0, len(ports))
+	for port := range ports {
+		sorted = append(sorted, port)
+	}
+	slices.SortFunc(sorted, func(a, b policyPort) int {
+		if c := strings.Compare(string(a.protocol), string(b.protocol)); c != 0 {
+			return c
+		}
+		return a.port - b.port
+	})
+	return sorted
+}
+
+func getPolicyName(w *workloadPolicy) string {
+	return w.owner + "-observed"
+}
+
+func getWorkloadSelector(owner string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{policySelectorLabel: owner}}
+}
+
+func getNamespaceSelector(namespace string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: namespace}}
+}
+
+func toNetworkPolicyPeer(w *workloadPolicy, peer policyPeer) networkingv1.NetworkPolicyPeer {
+	if peer.cidr != "" {
+		return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: peer.cidr}}
+	}
+	npp := networkingv1.NetworkPolicyPeer{PodSelector: getWorkloadSelector(peer.owner)}
+	if peer.namespace != w.namespace {
+		npp.NamespaceSelector = getNamespaceSelector(peer.namespace)
+	}
+	return npp
+}
+
+func toNetworkPolicyPorts(ports map[policyPort]bool) []networkingv1.NetworkPolicyPort {
+	npps := []networkingv1.NetworkPolicyPort{}
+	for _, p := range sortedPolicyPorts(ports) {
+		port := intstr.FromInt32(int32(p.port))
+		npps = append(npps, networkingv1.NetworkPolicyPort{Protocol: &p.protocol, Port: &port})
+	}
+	return npps
+}
+
+// getNetworkPolicies returns a policy per workload, allowing observed peers only
+func (s *policySuggestion) getNetworkPolicies() []*networkingv1.NetworkPolicy {
+	policies := []*networkingv1.NetworkPolicy{}
+	for _, w := range s.sortedWorkloads() {
+		np := &networkingv1.NetworkPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: metav1.ObjectMeta{Name: getPolicyName(w), Namespace: w.namespace},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: *getWorkloadSelector(w.owner),
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{},
+				Egress:      []networkingv1.NetworkPolicyEgressRule{},
+			},
+		}
+		for _, peer := range sortedPolicyPeers(w.ingress) {
+			np.Spec.Ingress = append(np.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+				From:  []networkingv1.NetworkPolicyPeer{toNetworkPolicyPeer(w, peer)},
+				Ports: toNetworkPolicyPorts(w.ingress[peer]),
+			})
+		}
+		for _, peer := range sortedPolicyPeers(w.egress) {
+			np.Spec.Egress = append(np.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+				To:    []networkingv1.NetworkPolicyPeer{toNetworkPolicyPeer(w, peer)},
+				Ports: toNetworkPolicyPorts(w.egress[peer]),
+			})
+		}
+		policies = append(policies, np)
+	}
+	return policies
+}
+
+// the admin network policy api is not vendored, these are written as unstructured objects
+func toANPPods(namespace, owner string) map[string]interface{} {
+	return map[string]interface{}{
+		"pods": map[string]interface{}{
+			"namespaceSelector": getNamespaceSelector(namespace),
+			"podSelector":       getWorkloadSelector(owner),
+		},
+	}
+}
+
+func toANPPorts(ports map[policyPort]bool) []interface{} {
+	anpPorts := []interface{}{}
+	for _, p := range sortedPolicyPorts(ports) {
+		anpPorts = append(anpPorts, map[string]interface{}{
+			"portNumber": map[string]interface{}{"protocol": p.protocol, "port": p.port},
+		})
+	}
+	return anpPorts
+}
+
+// getAdminNetworkPolicies returns an admin policy per workload allowing observed peers and denying others;
+// external sources can't be selected by admin policies ingress rules and are skipped
+func (s *policySuggestion) getAdminNetworkPolicies() []map[string]interface{} {
+	policies := []map[string]interface{}{}
+	for i, w := range s.sortedWorkloads() {
+		ingress := []interface{}{}
+		for _, peer := range sortedPolicyPeers(w.ingress) {
+			if peer.cidr != "" {
+				continue
+			}
+			ingress = append(ingress, map[string]interface{}{
+				"name":   fmt.Sprintf("allow-%d", len(ingress)),
+				"action": "Allow",
+				"from":   []interface{}{toANPPods(peer.namespace, peer.owner)},
+				"ports":  toANPPorts(w.ingress[peer]),
+			})
+		}
+		egress := []interface{}{}
+		for _, peer := range sortedPolicyPeers(w.egress) {
+			to := toANPPods(peer.namespace, peer.owner)
+			if peer.cidr != "" {
+				to = map[string]interface{}{"networks": []string{peer.cidr}}
+			}
+			egress = append(egress, map[string]interface{}{
+				"name":   fmt.Sprintf("allow-%d", len(egress)),
+				"action": "Allow",
+				"to":     []interface{}{to},
+				"ports":  toANPPorts(w.egress[peer]),
+			})
+		}
+		if len(ingress) >= maxANPRules || len(egress) >= maxANPRules {
+			log.Warnf("%s/%s admin network policy exceeds %d rules", w.namespace, w.owner, maxANPRules)
+		}
+
+		ingress = append(ingress, map[string]interface{}{
+			"name":   "deny-others",
+			"action": "Deny",
+			"from":   []interface{}{map[string]interface{}{"namespaces": map[string]interface{}{}}},
+		})
+		egress = append(egress, map[string]interface{}{
+			"name":   "deny-others",
+			"action": "Deny",
+			"to": []interface{}{
+				map[string]interface{}{"namespaces": map[string]interface{}{}},
+				map[string]interface{}{"networks": []string{"0.0.0.0/0", "::/0"}},
+			},
+		})
+
+		policies = append(policies, map[string]interface{}{
+			"apiVersion": "policy.networking.k8s.io/v1alpha1",
+			"kind":       "AdminNetworkPolicy",
+			"metadata":   map[string]interface{}{"name": w.namespace + "-" + getPolicyName(w)},
+			"spec": map[string]interface{}{
+				"priority": policyAdminPriority + i,
+				"subject":  toANPPods(w.namespace, w.owner),
+				"ingress":  ingress,
+				"egress":   egress,
+			},
+		})
+	}
+	return policies
+}
+
+func (s *policySuggestion) writeReport(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Suggested from %d flows for %d workloads\n", s.flows, len(s.workloads)))
+
+	sb.WriteString("# External peers:\n")
+	if len(s.external) == 0 {
+		sb.WriteString("#   none\n")
+	}
+	peers := make([]string, 0, len(s.external))
+	for peer := range s.external {
+		peers = append(peers, peer)
+	}
+	sort.Strings(peers)
+	for _, peer := range peers {
+		workloads := make([]string, 0, len(s.external[peer]))
+		for workload := range s.external[peer] {
+			workloads = append(workloads, workload)
+		}
+		sort.Strings(workloads)
+		sb.WriteString(fmt.Sprintf("#   %s <-> %s\n", peer, strings.Join(workloads, ", ")))
+	}
+
+	sb.WriteString("# Unresolved peers, not allowed:\n")
+	if len(s.unresolved) == 0 {
+		sb.WriteString("#   none\n")
+	}
+	reasons := make([]string, 0, len(s.unresolved))
+	for reason := range s.unresolved {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		sb.WriteString(fmt.Sprintf("#   %s (%d flows)\n", reason, s.unresolved[reason]))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writePolicies writes the report followed by policies as yaml documents
+func (s *policySuggestion) writePolicies(w io.Writer, admin bool) error {
+	if err := s.writeReport(w); err != nil {
+		return err
+	}
+
+	docs := []interface{}{}
+	for _, np := range s.getNetworkPolicies() {
+		docs = append(docs, np)
+	}
+	if admin {
+		for _, anp := range s.getAdminNetworkPolicies() {
+			docs = append(docs, anp)
+		}
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		// kubernetes objects only have json tags
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		var obj interface{}
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return err
+		}
+		if err := encoder.Encode(obj); err != nil {
+			return err
+		}
+	}
+	return encoder.Close()
+}
+
+func readCaptureFile(path string) ([]config.GenericMap, error) {
+	if uncompressed, _ := splitCompressionExt(path); filepath.Ext(uncompressed) == ".pcapng" {
+		return readPacketsFile(path)
+	}
+	return readFlowsFile(path)
+}
+
+func runSuggestPolicies(_ *cobra.Command, args []string) {
+	s := newPolicySuggestion()
+	for _, path := range args {
+		flows, err := readCaptureFile(path)
+		if err != nil {
+			log.Fatalf("Reading %s failed: %v", path, err)
+		}
+		for _, flow := range flows {
+			s.addFlow(flow)
+		}
+	}
+	if err := s.writePolicies(os.Stdout, policyAdmin); err != nil {
+		log.Fatal(err)
+	}
+}
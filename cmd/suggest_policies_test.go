@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func getPolicyTestSuggestion(t *testing.T) *policySuggestion {
+	s := newPolicySuggestion()
+	for _, str := range []string{
+		// client to server and its reply
+		`{"SrcAddr":"10.128.0.1","SrcPort":43210,"SrcK8S_Type":"Pod","SrcK8S_Namespace":"front","SrcK8S_Name":"web-abc","SrcK8S_OwnerName":"web",
+			"DstAddr":"10.129.0.1","DstPort":8080,"DstK8S_Type":"Pod","DstK8S_Namespace":"back","DstK8S_Name":"api-def","DstK8S_OwnerName":"api","Proto":6}`,
+		`{"SrcAddr":"10.129.0.1","SrcPort":8080,"SrcK8S_Type":"Pod","SrcK8S_Namespace":"back","SrcK8S_Name":"api-def","SrcK8S_OwnerName":"api",
+			"DstAddr":"10.128.0.1","DstPort":43210,"DstK8S_Type":"Pod","DstK8S_Namespace":"front","DstK8S_Name":"web-abc","DstK8S_OwnerName":"web","Proto":6}`,
+		// same namespace peer
+		`{"SrcAddr":"10.129.0.1","SrcPort":50000,"SrcK8S_Type":"Pod","SrcK8S_Namespace":"back","SrcK8S_Name":"api-def","SrcK8S_OwnerName":"api",
+			"DstAddr":"10.129.0.2","DstPort":5432,"DstK8S_Type":"Pod","DstK8S_Namespace":"back","DstK8S_Name":"db-0","DstK8S_OwnerName":"db","Proto":6}`,
+		// external peer
+		`{"SrcAddr":"10.128.0.1","SrcPort":43211,"SrcK8S_Type":"Pod","SrcK8S_Namespace":"front","SrcK8S_Name":"web-abc","SrcK8S_OwnerName":"web",
+			"DstAddr":"1.2.3.4","DstPort":443,"Proto":6}`,
+		// unresolved service and unsupported protocol
+		`{"SrcAddr":"10.128.0.1","SrcPort":43212,"SrcK8S_Type":"Pod","SrcK8S_Namespace":"front","SrcK8S_Name":"web-abc","SrcK8S_OwnerName":"web",
+			"DstAddr":"172.30.0.1","DstPort":443,"DstK8S_Type":"Service","DstK8S_Namespace":"default","DstK8S_Name":"kubernetes","Proto":6}`,
+		`{"SrcAddr":"10.128.0.1","SrcK8S_Type":"Pod","SrcK8S_Namespace":"front","SrcK8S_Name":"web-abc","SrcK8S_OwnerName":"web",
+			"DstAddr":"1.2.3.4","Proto":1}`,
+	} {
+		var flow config.GenericMap
+		assert.Nil(t, json.Unmarshal([]byte(str), &flow))
+		s.addFlow(flow)
+	}
+	return s
+}
+
+func TestSuggestNetworkPolicies(t *testing.T) {
+	s := getPolicyTestSuggestion(t)
+	policies := s.getNetworkPolicies()
+	assert.Len(t, policies, 3)
+
+	api := policies[0]
+	assert.Equal(t, "api-observed", api.Name)
+	assert.Equal(t, "back", api.Namespace)
+	assert.Equal(t, map[string]string{"app.kubernetes.io/name": "api"}, api.Spec.PodSelector.MatchLabels)
+
+	// reply didn't add any rule
+	assert.Len(t, api.Spec.Ingress, 1)
+	assert.Equal(t, map[string]string{"kubernetes.io/metadata.name": "front"}, api.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels)
+	assert.Equal(t, map[string]string{"app.kubernetes.io/name": "web"}, api.Spec.Ingress[0].From[0].PodSelector.MatchLabels)
+	assert.Equal(t, "TCP", string(*api.Spec.Ingress[0].Ports[0].Protocol))
+	assert.Equal(t, 8080, api.Spec.Ingress[0].Ports[0].Port.IntValue())
+
+	// same namespace peer has no namespace selector
+	assert.Len(t, api.Spec.Egress, 1)
+	assert.Nil(t, api.Spec.Egress[0].To[0].NamespaceSelector)
+	assert.Equal(t, map[string]string{"app.kubernetes.io/name": "db"}, api.Spec.Egress[0].To[0].PodSelector.MatchLabels)
+	assert.Equal(t, 5432, api.Spec.Egress[0].Ports[0].Port.IntValue())
+
+	web := policies[2]
+	assert.Equal(t, "web-observed", web.Name)
+	assert.Empty(t, web.Spec.Ingress)
+	assert.Len(t, web.Spec.Egress, 2)
+	assert.Equal(t, "1.2.3.4/32", web.Spec.Egress[0].To[0].IPBlock.CIDR)
+	assert.Equal(t, 443, web.Spec.Egress[0].Ports[0].Port.IntValue())
+}
+
+func TestSuggestPoliciesOutput(t *testing.T) {
+	s := getPolicyTestSuggestion(t)
+
+	var buf bytes.Buffer
+	assert.Nil(t, s.writePolicies(&buf, true))
+	out := buf.String()
+
+	// report
+	assert.Contains(t, out, "# Suggested from 6 flows for 3 workloads\n")
+	assert.Contains(t, out, "#   1.2.3.4 <-> front/web\n")
+	assert.Contains(t, out, "#   front/web -> 172.30.0.1 Service default/kubernetes TCP/443: unresolved peer (1 flows)\n")
+	assert.Contains(t, out, "unsupported protocol 1 (1 flows)\n")
+
+	// documents
+	kinds := []string{}
+	decoder := yaml.NewDecoder(&buf)
+	for {
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			break
+		}
+		kinds = append(kinds, doc["kind"].(string))
+	}
+	assert.Equal(t, []string{"NetworkPolicy", "NetworkPolicy", "NetworkPolicy",
+		"AdminNetworkPolicy", "AdminNetworkPolicy", "AdminNetworkPolicy"}, kinds)
+
+	anps := s.getAdminNetworkPolicies()
+	spec := anps[2]["spec"].(map[string]interface{})
+	assert.Equal(t, 12, spec["priority"])
+	egress := spec["egress"].([]interface{})
+	assert.Len(t, egress, 3)
+	assert.Equal(t, "Allow", egress[0].(map[string]interface{})["action"])
+	assert.Equal(t, map[string]interface{}{"networks": []string{"1.2.3.4/32"}}, egress[0].(map[string]interface{})["to"].([]interface{})[0])
+	assert.Equal(t, "Deny", egress[2].(map[string]interface{})["action"])
+}
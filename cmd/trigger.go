@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jpillora/sizestr"
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+)
+
+const (
+	defaultTriggerPre     = 30 * time.Second
+	defaultTriggerPreSize = 10000000
+	defaultTriggerPost    = 30 * time.Second
+)
+
+var (
+	triggerExpr    string
+	triggerPre     = defaultTriggerPre
+	triggerPreSize = int64(defaultTriggerPreSize)
+	triggerPost    = defaultTriggerPost
+	triggerStop    bool
+)
+
+type triggerRecord struct {
+	at    time.Time
+	value []byte
+	flow  config.GenericMap
+}
+
+// captureTrigger keeps the latest records in memory and only releases them to be written
+// when a record matches the trigger expression, followed by the records of the post window
+type captureTrigger struct {
+	predicate flowPredicate
+	pre       time.Duration
+	preSize   int64
+	post      time.Duration
+	stop      bool
+
+	ring      []triggerRecord
+	ringBytes int64
+	postUntil time.Time
+	count     int
+	ended     bool
+	mutex     sync.Mutex
+}
+
+func newCaptureTrigger(expr string, pre time.Duration, preSize int64, post time.Duration, stop bool) (*captureTrigger, error) {
+	// unlike live filters, regexes are not allowed here to avoid unexpected matches
+	predicate, err := parseFilter(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trigger %s: %w", expr, err)
+	}
+	return &captureTrigger{
+		predicate: predicate,
+		pre:       pre,
+		preSize:   preSize,
+		post:      post,
+		stop:      stop,
+	}, nil
+}
+
+// initTrigger creates the trigger of the session from flags, if any
+func (s *CaptureSession) initTrigger() {
+	if triggerExpr == "" {
+		return
+	}
+	var err error
+	s.trigger, err = newCaptureTrigger(triggerExpr, triggerPre, triggerPreSize, triggerPost, triggerStop)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Waiting for a record matching %s to write the capture", triggerExpr)
+}
+
+// process returns the records to write, which are empty until the trigger matches, and true
+// when the capture must end
+func (t *captureTrigger) process(value []byte, flow config.GenericMap) ([]triggerRecord, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.ended {
+		return nil, true
+	}
+
+	record := triggerRecord{at: currentTime(), value: value, flow: flow}
+	if t.expire(record.at) {
+		return nil, true
+	}
+	if !t.postUntil.IsZero() {
+		// write post window records, extending it on new matches
+		if t.predicate(flow) {
+			t.postUntil = record.at.Add(t.post)
+		}
+		return []triggerRecord{record}, false
+	}
+
+	if t.predicate(flow) {
+		t.count++
+		log.Infof("Trigger matched, writing %d buffered records", len(t.ring))
+		records := append(t.ring, record)
+		t.ring = []triggerRecord{}
+		t.ringBytes = 0
+		t.postUntil = record.at.Add(t.post)
+		return records, false
+	}
+
+	// keep record in memory, dropping the ones outside of the pre window
+	t.ring = append(t.ring, record)
+	t.ringBytes += int64(len(value))
+	drop := 0
+	for drop < len(t.ring) &&
+		(record.at.Sub(t.ring[drop].at) > t.pre || (t.preSize > 0 && t.ringBytes > t.preSize)) {
+		t.ringBytes -= int64(len(t.ring[drop].value))
+		drop++
+	}
+	t.ring = t.ring[drop:]
+	return nil, false
+}
+
+// expireAt ends the post window when expired, even when no record is received,
+// returning true when the capture must end
+func (t *captureTrigger) expireAt(now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.expire(now)
+}
+
+func (t *captureTrigger) expire(now time.Time) bool {
+	if t.postUntil.IsZero() || now.Before(t.postUntil) {
+		return false
+	}
+	log.Infof("Trigger window ended after %d match(es)", t.count)
+	t.postUntil = time.Time{}
+	if t.stop {
+		t.ended = true
+	}
+	return t.ended
+}
+
+// getTickInterval returns how often the post window expiration is checked
+func (t *captureTrigger) getTickInterval() time.Duration {
+	if t.post > 0 && t.post/2 < time.Second {
+		return t.post / 2
+	}
+	return time.Second
+}
+
+// expireTrigger ends the post window of the trigger when expired, returning true when the collector must exit
+func (s *CaptureSession) expireTrigger() bool {
+	if !s.trigger.expireAt(currentTime()) {
+		return false
+	}
+	return s.endOnTriggerWindow()
+}
+
+// endOnTriggerWindow ends the capture after the trigger window, returning true when the collector must exit
+func (s *CaptureSession) endOnTriggerWindow() bool {
+	if exit := s.onLimitReached("trigger window ended"); exit {
+		log.Info("Trigger window ended, exiting now...")
+		return true
+	}
+	return false
+}
+
+func (t *captureTrigger) getStatusText() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.ended {
+		return "Trigger: ended"
+	} else if !t.postUntil.IsZero() {
+		return fmt.Sprintf("Trigger: writing until %s", t.postUntil.Format(time.TimeOnly))
+	}
+	return fmt.Sprintf("Trigger: armed, %d matches, %s buffered", t.count, sizestr.ToString(t.ringBytes))
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/netobserv/flowlogs-pipeline/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func processTriggerFlows(t *testing.T, trig *captureTrigger, drops ...int) ([]int, bool) {
+	written := []int{}
+	for _, d := range drops {
+		tickTimeAndAddBytes()
+		value := []byte(fmt.Sprintf(`{"PktDropPackets":%d}`, d))
+		records, stop := trig.process(value, config.GenericMap{"PktDropPackets": float64(d)})
+		if stop {
+			return written, true
+		}
+		for _, record := range records {
+			written = append(written, int(record.flow["PktDropPackets"].(float64)))
+		}
+	}
+	assert.NotEmpty(t, trig.getStatusText())
+	return written, false
+}
+
+func TestTriggerPrePostWindows(t *testing.T) {
+	setup(t)
+	trig, err := newCaptureTrigger("PktDropPackets>0", 2*time.Second, 0, 2*time.Second, false)
+	assert.Nil(t, err)
+
+	// nothing is written until a match, keeping only the pre window in memory
+	written, stop := processTriggerFlows(t, trig, 0, 0, 0, 0)
+	assert.False(t, stop)
+	assert.Empty(t, written)
+	assert.Equal(t, 3, len(trig.ring))
+
+	// match writes the buffer and the post window
+	written, stop = processTriggerFlows(t, trig, 5, 0, 0, 0, 0)
+	assert.False(t, stop)
+	assert.Equal(t, []int{0, 0, 0, 5, 0}, written)
+
+	// then the trigger is armed again
+	written, _ = processTriggerFlows(t, trig, 0, 7)
+	assert.Equal(t, []int{0, 0, 0, 7}, written)
+	assert.Equal(t, 2, trig.count)
+}
+
+func TestTriggerPreSizeAndStop(t *testing.T) {
+	setup(t)
+	trig, err := newCaptureTrigger("PktDropPackets>0", time.Hour, 40, time.Second, true)
+	assert.Nil(t, err)
+
+	// each record is 20 bytes so only the 2 last ones are kept
+	written, stop := processTriggerFlows(t, trig, 0, 0, 0, 1)
+	assert.False(t, stop)
+	assert.Equal(t, []int{0, 0, 1}, written)
+
+	_, stop = processTriggerFlows(t, trig, 0, 2)
+	assert.True(t, stop)
+	assert.Equal(t, "Trigger: ended", trig.getStatusText())
+}
+
+func TestTriggerInvalid(t *testing.T) {
+	setup(t)
+	_, err := newCaptureTrigger("PktDropPackets>", time.Second, 0, time.Second, false)
+	assert.NotNil(t, err)
+}
+
+func TestTriggerWindowExpiration(t *testing.T) {
+	setup(t)
+	trig, err := newCaptureTrigger("PktDropPackets>0", time.Hour, 0, 2*time.Second, true)
+	assert.Nil(t, err)
+	session.trigger = trig
+	assert.Equal(t, time.Second, trig.getTickInterval())
+
+	written, stop := processTriggerFlows(t, trig, 0, 1)
+	assert.False(t, stop)
+	assert.Equal(t, []int{0, 1}, written)
+
+	// the window ends on time without waiting for a record
+	currentTime = func() time.Time { return simulatedTime }
+	assert.False(t, session.expireTrigger())
+	assert.False(t, session.isStopped())
+	simulatedTime = simulatedTime.Add(2 * time.Second)
+	assert.True(t, session.expireTrigger())
+	assert.True(t, session.isStopped())
+	assert.EqualError(t, session.getStopCause(), "trigger window ended")
+	assert.Equal(t, "Trigger: ended", trig.getStatusText())
+}
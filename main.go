@@ -16,7 +16,7 @@ var (
 
 func main() {
 	// Initial log message
-	fmt.Printf("Starting %s:\n=====\nBuild version: %s\nBuild date: %s\n\n",
+	fmt.Fprintf(os.Stderr, "Starting %s:\n=====\nBuild version: %s\nBuild date: %s\n\n",
 		filepath.Base(os.Args[0]), buildVersion, buildDate)
 
 	err := cmd.Execute()
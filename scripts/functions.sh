@@ -385,31 +385,35 @@ function follow() {
   ${K8S_CLI_BIN} logs collector -n "$namespace" -f
 }
 
+function attach() {
+  cliBin="${NETOBSERV_CLI_BIN:-network-observability-cli}"
+  if ! command -v "$cliBin" >/dev/null 2>&1; then
+    echo "$cliBin not found: build it using 'make compile' and add it to your PATH or set NETOBSERV_CLI_BIN"
+    exit 1
+  fi
+  # forward the port given to the capture using --api-port
+  remotePort="9998"
+  args=()
+  for arg in "$@"; do
+    if [[ "$arg" == --api-port=* ]]; then
+      remotePort="${arg#--api-port=}"
+    else
+      args+=("$arg")
+    fi
+  done
+  localPort="${NETOBSERV_ATTACH_PORT:-$remotePort}"
+  ${K8S_CLI_BIN} port-forward -n "$namespace" pod/collector "$localPort:$remotePort" >/dev/null &
+  portForwardPid=$!
+  trap 'kill $portForwardPid 2>/dev/null' EXIT
+  "$cliBin" attach "localhost:$localPort" "${args[@]}"
+}
+
 function copyOutput() {
   echo "Copying collector files to ${OUTPUT_PATH}..."
   if [[ ! -d ${OUTPUT_PATH} ]]; then
     mkdir -p ${OUTPUT_PATH} >/dev/null
   fi
   ${K8S_CLI_BIN} cp -n "$namespace" collector:output ./output
-  flowFile=$(find ./output -name "*txt" | sort | tail -1)
-  if [[ -n "$flowFile" ]] ; then
-    buildJSON "$flowFile"
-    rm "$flowFile"
-  fi
-}
-
-function buildJSON() {
-  file=$1
-  filename=$(basename "$file")
-  dirpath=$(dirname "$file")
-  filenamePrefix=$(echo "$filename" | sed -E 's/(.*)\..*/\1/')
-  UPDATED_JSON_FILE="$dirpath/$filenamePrefix.json"
-  { 
-    echo "["
-    # remove last line and "," (last character) of the last flowlog for valid json
-    sed '$d' "$file" | sed '$ s/.$//'
-    echo "]"
-  } >> "$UPDATED_JSON_FILE"
 }
 
 function deleteServiceMonitor() {
@@ -1111,6 +1115,14 @@ function check_args_and_apply() {
         echo "invalid value for --log-level"
       fi
       ;;
+    *api-port) # Streaming API port used by attach
+      if [[ ! "$value" =~ ^[0-9]+$ ]]; then
+        echo "invalid value for --api-port"
+        exit 1
+      fi
+      apiPort=$value
+      filter=${filter/$key=$value/}
+      ;;
     *max-time) # Max time
       maxTime=$value
       filter=${filter/$key=$maxTime/}
@@ -1124,6 +1136,145 @@ function check_args_and_apply() {
         exit 1
       fi
       ;;
+    *rotate-size|*rotate-time|*rotate-count) # Output rotation
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      elif [[ "$value" == "" || "$value" == "$key" ]]; then
+        echo "missing value for ${key}"
+        exit 1
+      fi
+      case "$key" in
+      *rotate-size) rotateSize=$value ;;
+      *rotate-time) rotateTime=$value ;;
+      *rotate-count) rotateCount=$value ;;
+      esac
+      filter=${filter/$key=$value/}
+      ;;
+    *sink) # Output sinks
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      elif [[ "$value" == "" || "$value" == "$key" ]]; then
+        echo "missing value for ${key}"
+        exit 1
+      fi
+      sink=$value
+      filter=${filter/$key=$value/}
+      ;;
+    *stdout-fields|*stdout-template) # Stdout sink format
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      elif [[ "$value" == "" || "$value" == "$key" ]]; then
+        echo "missing value for ${key}"
+        exit 1
+      fi
+      stdoutArgs="$stdoutArgs ${key}='${value}'"
+      filter=${filter/$key=$value/}
+      ;;
+    *batch) # Print table periodically
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      fi
+      defaultValue "true"
+      if [[ "$value" == "true" || "$value" == "false" ]]; then
+        batchArgs="$batchArgs --batch=$value"
+      else
+        echo "invalid value for --batch"
+        exit 1
+      fi
+      filter=${filter/$key=$value/}
+      ;;
+    *batch-interval|*batch-iterations|*batch-columns|*batch-format|*batch-output) # Batch table options
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      elif [[ "$value" == "" || "$value" == "$key" ]]; then
+        echo "missing value for ${key}"
+        exit 1
+      fi
+      batchArgs="$batchArgs ${key}='${value}'"
+      filter=${filter/$key=$value/}
+      ;;
+    *compress|*compressed-size) # Output files compression
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      fi
+      if [[ "$key" == *compressed-size ]]; then
+        defaultValue "true"
+        compressedSize=$value
+      elif [[ "$value" == "gzip" || "$value" == "zstd" || "$value" == "none" ]]; then
+        compress=$value
+      else
+        echo "invalid value for --compress"
+        exit 1
+      fi
+      filter=${filter/$key=$value/}
+      ;;
+    *anonymize|*anonymize-key) # Anonymization of captured records
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      fi
+      if [[ "$key" == *anonymize-key ]]; then
+        if [[ "$value" == "" || "$value" == "$key" ]]; then
+          echo "missing value for ${key}"
+          exit 1
+        fi
+        anonymizeKey=$value
+      else
+        defaultValue "true"
+        anonymize=$value
+      fi
+      filter=${filter/$key=$value/}
+      ;;
+    *trigger|*trigger-pre|*trigger-pre-size|*trigger-post|*trigger-stop) # Trigger mode
+      if [[ "$command" != "flows" && "$command" != "packets" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      fi
+      if [[ "$key" == *trigger-stop ]]; then
+        defaultValue "true"
+      elif [[ "$value" == "" || "$value" == "$key" ]]; then
+        echo "missing value for ${key}"
+        exit 1
+      fi
+      triggerArgs="$triggerArgs ${key}='${value}'"
+      filter=${filter/$key=$value/}
+      ;;
+    *dedup|*dedup-window) # Flows deduplication
+      if [[ "$command" != "flows" ]]; then
+        echo "${key} is invalid option for $command"
+        exit 1
+      elif [[ "$value" == "" || "$value" == "$key" ]]; then
+        echo "missing value for ${key}"
+        exit 1
+      fi
+      if [[ "$key" == *dedup-window ]]; then
+        dedupWindow=$value
+      elif [[ "$value" == "none" || "$value" == "mark" || "$value" == "merge" ]]; then
+        dedup=$value
+      else
+        echo "invalid value for --dedup"
+        exit 1
+      fi
+      filter=${filter/$key=$value/}
+      ;;
+    *output-format) # Flows output file format
+      if [[ "$command" != "flows" ]]; then
+        echo "--output-format is invalid option for $command"
+        exit 1
+      elif [[ "$value" == "json" || "$value" == "ndjson" ]]; then
+        outputFormat=$value
+        filter=${filter/$key=$outputFormat/}
+      else
+        echo "invalid value for --output-format"
+        exit 1
+      fi
+      ;;
     *node-selector) # Node selector
       if [[ $value == *":"* ]]; then
         edit_manifest "node_selector" "$value"
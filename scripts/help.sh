@@ -9,7 +9,7 @@ function help {
   echo "NetObserv allows you to capture flows, packets and metrics from your cluster."
   echo "Find more information at: https://github.com/netobserv/network-observability-cli/"
   echo
-  echo "Syntax: netobserv [flows|packets|metrics|follow|stop|copy|cleanup|version] [options]"
+  echo "Syntax: netobserv [flows|packets|metrics|attach|follow|stop|copy|cleanup|version] [options]"
   echo
   echo "Main commands:"
   echo "  flows      Capture flows information in JSON format using collector pod."
@@ -17,6 +17,7 @@ function help {
   echo "  packets    Capture packets information in pcap format using collector pod."
   echo
   echo "Extra commands:"
+  echo "  attach     Display a running capture locally, several users being able to watch it at once."
   echo "  cleanup    Remove netobserv components and configurations."
   echo "  copy       Copy collector generated files locally."
   echo "  follow     Follow collector logs when running in background."
@@ -91,14 +92,45 @@ function flowsAndPackets_collector_usage {
   echo "  --background:                 run in background                                     (default: false)"
   echo "  --copy:                       copy the output files locally                         (default: prompt)"
   echo "  --log-level:                  components logs                                       (default: info)"
+  echo "  --api-port:                   streaming API port used by attach, such as 9998       (default: disabled)"
   echo "  --max-time:                   maximum capture time                                  (default: 5m)"
   echo "  --max-bytes:                  maximum capture bytes                                 (default: 50000000 = 50MB)"
+  echo "  --rotate-size:                rotate output files after bytes, ignoring max limits  (default: n/a)"
+  echo "  --rotate-time:                rotate output files after time, ignoring max limits   (default: n/a)"
+  echo "  --rotate-count:               number of rotated output files to keep                (default: all)"
+  echo "  --sink:                       comma separated outputs: file, db for flows, pcapng   (default: all)"
+  echo "                                 and stdout to stream records instead of the display"
+  echo "  --stdout-fields:              fields or calculated columns of the stdout sink       (default: all)"
+  echo "  --stdout-template:            go template formatting records of the stdout sink     (default: none)"
+  echo "  --batch:                      print the table periodically instead of the display   (default: false)"
+  echo "  --batch-interval:             time between two batch tables                         (default: 5s)"
+  echo "  --batch-iterations:           batch tables printed before ending the capture        (default: no limit)"
+  echo "  --batch-columns:              comma separated column ids of batch tables            (default: display columns)"
+  echo "  --batch-format:               batch format: table, csv, json, ndjson or markdown    (default: table)"
+  echo "  --batch-output:               file copied back in output/batch with capture files   (default: stdout)"
+  echo "  --compress:                   output files compression: none, gzip or zstd          (default: none)"
+  echo "  --compressed-size:            count compressed bytes for max bytes and rotation     (default: false)"
+  echo "  --anonymize:                  anonymize addresses and names, truncate payloads      (default: false)"
+  echo "  --anonymize-key:              key giving the same anonymized values across captures (default: random)"
+  echo "  --trigger:                    only write data around records matching this filter   (default: n/a)"
+  echo "  --trigger-pre:                duration kept in memory before a trigger match        (default: 30s)"
+  echo "  --trigger-pre-size:           maximum bytes kept in memory before a trigger match   (default: 10000000 = 10MB)"
+  echo "  --trigger-post:               duration written after a trigger match                (default: 30s)"
+  echo "  --trigger-stop:               end the capture once the trigger window is written    (default: false)"
+}
+
+# flows only collector options
+function flows_collector_usage {
+  echo "  --output-format:              flows output file format: json or ndjson              (default: json)"
+  echo "  --dedup:                      flows deduplication: none, mark or merge              (default: none)"
+  echo "  --dedup-window:               time observations of the same flow are merged within  (default: 2s)"
 }
 
 # fmetrics collector options
 function metrics_collector_usage {
   echo "  --background:                 run in background                                     (default: false)"
   echo "  --log-level:                  components logs                                       (default: info)"
+  echo "  --api-port:                   streaming API port used by attach, such as 9998       (default: disabled)"
   echo "  --max-time:                   maximum capture time                                  (default: 1h)"
 }
 
@@ -159,6 +191,7 @@ function flows_usage {
   echo
   echo "Options:"
   flowsAndPackets_collector_usage
+  flows_collector_usage
   script_usage
   echo
   echo "Examples:"
@@ -218,6 +251,23 @@ function follow_usage {
   echo
 }
 
+function attach_usage {
+  echo
+  echo "NetObserv allows you to display a running capture from your machine, even when it runs in background."
+  echo "The attach command forwards the collector streaming API port and renders the same display locally,"
+  echo "reconnecting when the connection drops. Several users can attach to the same capture at once."
+  echo "The capture must be started with --api-port, such as 'netobserv flows --api-port=9998'."
+  echo "It requires the network-observability-cli binary in your PATH, or set NETOBSERV_CLI_BIN."
+  echo "Find more information at: https://github.com/netobserv/network-observability-cli/"
+  echo
+  echo "Syntax: netobserv attach [options]"
+  echo
+  echo "options:"
+  echo "  --filter:                     only stream records matching this filter              (default: all)"
+  echo "  --api-port:                   port given to the capture using --api-port            (default: 9998)"
+  echo
+}
+
 function stop_usage {
   echo
   echo "NetObserv allows you stop the collection and keep collector or dashboard for post analysis."